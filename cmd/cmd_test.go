@@ -18,10 +18,13 @@ package cmd
 
 import (
 	"bytes"
+	"net"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -97,7 +100,18 @@ func TestMetadataCommandJsonPretty(t *testing.T) {
 	assert.Contains(t, output, "    ")
 }
 
+// resetInspectInputs clears the repeatable input flag of the inspect command,
+// which keeps its values across executions.
+func resetInspectInputs(t *testing.T) {
+	t.Helper()
+	require.NoError(t, inspectCmd.Flags().Lookup("input").Value.(pflag.SliceValue).Replace(nil))
+	t.Cleanup(func() {
+		require.NoError(t, inspectCmd.Flags().Lookup("input").Value.(pflag.SliceValue).Replace(nil))
+	})
+}
+
 func TestInspectCommandIPv4(t *testing.T) {
+	resetInspectInputs(t)
 	output, err := captureAndExecute(t, "inspect", "-i", "../test/inspect.mmdb", "-f", "json", "1.1.1.1")
 	assert.NoError(t, err)
 	assert.Contains(t, output, "1.1.1.1")
@@ -106,6 +120,7 @@ func TestInspectCommandIPv4(t *testing.T) {
 }
 
 func TestInspectCommandMultipleIPs(t *testing.T) {
+	resetInspectInputs(t)
 	output, err := captureAndExecute(t, "inspect", "-i", "../test/inspect.mmdb", "-f", "json", "1.1.1.1", "1.0.0.1")
 	assert.NoError(t, err)
 	assert.Contains(t, output, "1.1.1.1")
@@ -113,16 +128,28 @@ func TestInspectCommandMultipleIPs(t *testing.T) {
 }
 
 func TestInspectCommandCIDR(t *testing.T) {
+	resetInspectInputs(t)
 	output, err := captureAndExecute(t, "inspect", "-i", "../test/inspect.mmdb", "-f", "json", "1.0.0.0/8")
 	assert.NoError(t, err)
 	assert.Contains(t, output, "1.0.0.0/8")
 }
 
 func TestInspectCommandMissingArgs(t *testing.T) {
+	resetInspectInputs(t)
 	_, err := captureAndExecute(t, "inspect", "-i", "../test/inspect.mmdb")
 	assert.Error(t, err)
 }
 
+func TestInspectCommandAliasedDatabases(t *testing.T) {
+	resetInspectInputs(t)
+	t.Cleanup(func() { require.NoError(t, inspectCmd.Flags().Set("mode", "within")) })
+	output, err := captureAndExecute(t, "inspect", "-i", "geo=../test/inspect.mmdb", "-i", "meta=../test/metadata.mmdb", "-f", "json", "--mode", "lookup", "1.0.0.1")
+	assert.NoError(t, err)
+	assert.Contains(t, output, `"geo":{`)
+	assert.Contains(t, output, `"meta":{`)
+	assert.Contains(t, output, `"prefix_len":24`)
+}
+
 func TestDumpCommand(t *testing.T) {
 	dir := t.TempDir()
 	outFile := filepath.Join(dir, "dump.json")
@@ -168,12 +195,35 @@ func TestGenerateCommand(t *testing.T) {
 	require.NoError(t, os.WriteFile(inputPath, []byte(inputJSON), 0644))
 	outputPath := filepath.Join(dir, "output.mmdb")
 
+	// The repeatable input flag keeps its values across executions
+	resetInputs := func() {
+		require.NoError(t, generateCmd.Flags().Lookup("input").Value.(pflag.SliceValue).Replace(nil))
+	}
+	resetInputs()
+	defer resetInputs()
+
 	output, err := captureAndExecute(t, "generate", "-i", inputPath, "-o", outputPath)
 	assert.NoError(t, err)
 	assert.Contains(t, output, "Generated successfully")
 
 	_, statErr := os.Stat(outputPath)
 	assert.NoError(t, statErr)
+
+	layerPath := filepath.Join(dir, "layer.json")
+	require.NoError(t, os.WriteFile(layerPath, []byte(`{"dataset": [{"network": "1.1.1.0/24", "record": {"asn": 13335}}]}`), 0644))
+	layeredPath := filepath.Join(dir, "layered.mmdb")
+
+	resetInputs()
+	output, err = captureAndExecute(t, "generate", "-i", inputPath, "-i", layerPath, "-o", layeredPath)
+	assert.NoError(t, err)
+	assert.Contains(t, output, "Generating from dataset layers")
+
+	db, err := maxminddb.Open(layeredPath)
+	require.NoError(t, err)
+	defer db.Close()
+	var record map[string]interface{}
+	require.NoError(t, db.Lookup(net.ParseIP("1.1.1.1"), &record))
+	assert.Equal(t, map[string]interface{}{"country": "AU", "asn": 13335.0}, record)
 }
 
 func TestUpdateCommand(t *testing.T) {
@@ -200,7 +250,7 @@ func TestUpdateCommand(t *testing.T) {
 }
 
 func TestSubcommandRegistration(t *testing.T) {
-	subcommands := []string{"version", "metadata", "inspect", "update", "dump", "generate", "verify"}
+	subcommands := []string{"version", "metadata", "inspect", "update", "dump", "generate", "verify", "schema", "import", "validate-dataset", "serve"}
 	registeredCmds := rootCmd.Commands()
 
 	registeredNames := make(map[string]bool)
@@ -223,7 +273,9 @@ func TestRequiredFlagsAreMarked(t *testing.T) {
 		{"inspect", []string{"input"}},
 		{"dump", []string{"input", "output"}},
 		{"generate", []string{"input", "output"}},
-		{"update", []string{"input", "dataset", "output"}},
+		{"update", []string{"input", "dataset"}},
+		{"validate-dataset", []string{"input"}},
+		{"serve", []string{"input"}},
 	}
 
 	for _, tt := range tests {
@@ -244,3 +296,38 @@ func TestRequiredFlagsAreMarked(t *testing.T) {
 		})
 	}
 }
+
+func TestSchemaInferCommand(t *testing.T) {
+	output, err := captureAndExecute(t, "schema", "infer", "-i", "../test/inspect.mmdb", "-f", "json")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "registered_country.geoname_id")
+	assert.Contains(t, output, "presence")
+}
+
+func TestValidateDatasetCommand(t *testing.T) {
+	output, err := captureAndExecute(t, "validate-dataset", "-i", "../example/generate.json")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "The dataset is valid")
+
+	output, err = captureAndExecute(t, "validate-dataset", "-i", "../example/update.json", "--kind", "update")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "The dataset is valid")
+}
+
+func TestLogFormatJSON(t *testing.T) {
+	defer rootCmd.PersistentFlags().Set("log-format", "text")
+
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "dump.json")
+
+	output, err := captureAndExecute(t, "--log-format", "json", "dump", "-i", "../test/inspect.mmdb", "-o", outFile)
+	assert.NoError(t, err)
+	assert.Contains(t, output, `"level":"INFO","msg":"MMDB Dumped successfully"`)
+}
+
+func TestInvalidLogLevel(t *testing.T) {
+	defer rootCmd.PersistentFlags().Set("log-level", "info")
+
+	_, err := captureAndExecute(t, "--log-level", "verbose", "version")
+	assert.Error(t, err)
+}
@@ -17,8 +17,6 @@ limitations under the License.
 package cmd
 
 import (
-	"log"
-
 	"github.com/InfraZ/mmdb-cli/pkg/dump"
 	"github.com/spf13/cobra"
 )
@@ -39,16 +37,17 @@ var dumpCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		err := dump.DumpMMMDB(&cmdDumpConfig)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 	},
 }
 
 func init() {
 	// Add flags to the update command
-	dumpCmd.Flags().StringVarP(&cmdDumpConfig.InputDatabase, "input", "i", "", "Input path of the MMDB file")
-	dumpCmd.Flags().StringVarP(&cmdDumpConfig.OutputFile, "output", "o", "", "Output path of the output JSON dataset file (must have a .json extension)")
+	dumpCmd.Flags().StringVarP(&cmdDumpConfig.InputDatabase, "input", "i", "", "Input path of the MMDB file (.mmdb, .mmdb.gz or .mmdb.zst), or - to read it from stdin")
+	dumpCmd.Flags().StringVarP(&cmdDumpConfig.OutputFile, "output", "o", "", "Output path of the output JSON dataset file (.json, or .ndjson/.jsonl for JSON Lines, optionally followed by .gz or .zst), or - to write a JSON dataset to stdout")
 	dumpCmd.Flags().BoolVarP(&cmdDumpConfig.Verbose, "verbose", "v", false, "Enable verbose mode")
+	dumpCmd.Flags().BoolVar(&cmdDumpConfig.NoSchema, "no-schema", false, "Do not write a schema block describing the MMDB types of the records")
 	dumpCmd.Flags().StringVarP(&cmdDumpConfig.JSONPath, "jsonpath", "j", "", `JSONPath filter applied to each record (e.g. '{[?(@.country.iso_code=="US")]}')`)
 
 	// Mark required flags
@@ -17,18 +17,27 @@ limitations under the License.
 package cmd
 
 import (
-	"log"
-
 	"github.com/InfraZ/mmdb-cli/pkg/generate"
 	"github.com/spf13/cobra"
 )
 
 var cmdGenerateConfig generate.CmdGenerateConfig
 
+// generateInputs are the dataset files and directories of the repeatable
+// input flag, the first one holding the metadata of the database
+var generateInputs []string
+
 const (
 	generateCmdName      = "generate"
 	generateCmdShortDesc = "Generate a MMDB database from a JSON dataset"
-	generateCmdLongDesc  = `This command generates a MMDB database from a JSON dataset.`
+	generateCmdLongDesc  = `This command generates a MMDB database from a JSON dataset.
+
+Several datasets can be layered by repeating --input or by passing a directory,
+whose dataset files are applied in the order of their names. The first dataset
+holds the metadata of the database, which the following datasets can override
+field by field. The records of every following dataset are merged into the
+networks inserted before them with the "method" of their header (remove,
+replace, top_level_merge or deep_merge), or with --layer-method.`
 )
 
 // generateCmd represents the generate command
@@ -37,21 +46,31 @@ var generateCmd = &cobra.Command{
 	Short: generateCmdShortDesc,
 	Long:  generateCmdLongDesc,
 	Run: func(cmd *cobra.Command, args []string) {
+		cmdGenerateConfig.InputDataset = generateInputs[0]
+		cmdGenerateConfig.Layers = generateInputs[1:]
+
 		err := generate.GenerateMMDB(&cmdGenerateConfig)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 	},
 }
 
 func init() {
 	// Add flags to the update command
-	generateCmd.Flags().StringVarP(&cmdGenerateConfig.InputDataset, "input", "i", "", "Input path of the JSON dataset file (must have a .json extension)")
-	generateCmd.Flags().StringVarP(&cmdGenerateConfig.OutputDatabase, "output", "o", "", "Output path of the MMDB database file (must have a .mmdb extension)")
+	generateCmd.Flags().StringArrayVarP(&generateInputs, "input", "i", nil, "Input path of the dataset file (.json, .ndjson/.jsonl for JSON Lines, optionally followed by .gz or .zst, or .csv with --mapping), a directory of dataset files, or - to read it from stdin (repeat to layer datasets)")
+	generateCmd.Flags().StringVarP(&cmdGenerateConfig.OutputDatabase, "output", "o", "", "Output path of the MMDB database file (must have a .mmdb extension), or - to write it to stdout")
 	generateCmd.Flags().BoolVarP(&cmdGenerateConfig.Verbose, "verbose", "v", false, "Enable verbose mode")
+	generateCmd.Flags().StringVarP(&cmdGenerateConfig.Mapping, "mapping", "m", "", "Mapping file of the CSV columns to record fields (required for .csv datasets)")
 
 	generateCmd.Flags().BoolVar(&cmdGenerateConfig.DisableIPv4Aliasing, "disable-ipv4-aliasing", false, "Disable IPv4 aliasing")
 	generateCmd.Flags().BoolVar(&cmdGenerateConfig.IncludeReservedNetworks, "include-reserved-networks", false, "Include reserved networks")
+	generateCmd.Flags().BoolVar(&cmdGenerateConfig.Strict, "strict", false, "Fail on values that do not match the dataset schema instead of writing zero values")
+	generateCmd.Flags().BoolVar(&cmdGenerateConfig.Verify, "verify", false, "Verify the written database before moving it to the output path")
+	generateCmd.Flags().BoolVar(&cmdGenerateConfig.SkipValidation, "skip-validation", false, "Skip the validation of the dataset before processing it")
+	generateCmd.Flags().Int64Var(&cmdGenerateConfig.BuildEpoch, "build-epoch", 0, "Build timestamp of the database as a Unix epoch (defaults to BuildEpoch in metadata, then SOURCE_DATE_EPOCH, then the current time)")
+	generateCmd.Flags().StringVar(&cmdGenerateConfig.LayerMethod, "layer-method", generate.MethodDeepMerge, "Method merging the records of the layered datasets whose header has no method (remove, replace, top_level_merge, deep_merge)")
+	generateCmd.Flags().StringVar(&cmdGenerateConfig.OnConflict, "on-conflict", generate.OnConflictReplace, "Policy for records overlapping networks inserted earlier (replace, keep-existing, deep-merge, top-level-merge, error)")
 
 	// Mark required flags
 	generateCmd.MarkFlagRequired("input")
@@ -0,0 +1,74 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"github.com/InfraZ/mmdb-cli/pkg/importer"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	importCmdName      = "import"
+	importCmdShortDesc = "Import third-party data distributions into MMDB"
+	importCmdLongDesc  = `This command groups the subcommands that build MMDB files from third-party data distributions`
+
+	importGeoIP2CSVCmdName      = "geoip2-csv"
+	importGeoIP2CSVCmdShortDesc = "Import a GeoIP2 or GeoLite2 CSV distribution into MMDB"
+	importGeoIP2CSVCmdLongDesc  = `This command joins the blocks and locations CSV files of a GeoIP2 or GeoLite2 City, Country or ASN distribution on geoname_id and writes the networks with the nested GeoIP2 record layout`
+)
+
+var cmdImportGeoIP2CSVConfig importer.CmdImportGeoIP2CSVConfig
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   importCmdName,
+	Short: importCmdShortDesc,
+	Long:  importCmdLongDesc,
+}
+
+// importGeoIP2CSVCmd represents the import geoip2-csv command
+var importGeoIP2CSVCmd = &cobra.Command{
+	Use:   importGeoIP2CSVCmdName,
+	Short: importGeoIP2CSVCmdShortDesc,
+	Long:  importGeoIP2CSVCmdLongDesc,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := importer.ImportGeoIP2CSV(&cmdImportGeoIP2CSVConfig)
+		if err != nil {
+			fatal(err)
+		}
+	},
+}
+
+func init() {
+	// Add flags to the import geoip2-csv command
+	importGeoIP2CSVCmd.Flags().StringArrayVarP(&cmdImportGeoIP2CSVConfig.BlocksFiles, "blocks", "b", nil, "Input path of a blocks CSV file (repeatable, e.g. for the IPv4 and IPv6 blocks)")
+	importGeoIP2CSVCmd.Flags().StringArrayVarP(&cmdImportGeoIP2CSVConfig.LocationsFiles, "locations", "l", nil, "Input path of a locations CSV file (repeatable, one per locale, not needed for ASN)")
+	importGeoIP2CSVCmd.Flags().StringVarP(&cmdImportGeoIP2CSVConfig.OutputDatabase, "output", "o", "", "Output path of the MMDB database file (must have a .mmdb extension)")
+	importGeoIP2CSVCmd.Flags().StringVar(&cmdImportGeoIP2CSVConfig.DatabaseType, "database-type", "", "Database type written to the metadata (default GeoIP2-City, GeoIP2-Country or GeoIP2-ASN, detected from the first blocks file)")
+	importGeoIP2CSVCmd.Flags().StringVar(&cmdImportGeoIP2CSVConfig.Description, "description", "GeoIP2 database imported from CSV", "English description written to the metadata")
+	importGeoIP2CSVCmd.Flags().BoolVarP(&cmdImportGeoIP2CSVConfig.Verbose, "verbose", "v", false, "Enable verbose mode")
+
+	importGeoIP2CSVCmd.Flags().BoolVar(&cmdImportGeoIP2CSVConfig.DisableIPv4Aliasing, "disable-ipv4-aliasing", false, "Disable IPv4 aliasing")
+	importGeoIP2CSVCmd.Flags().BoolVar(&cmdImportGeoIP2CSVConfig.IncludeReservedNetworks, "include-reserved-networks", false, "Include reserved networks")
+
+	// Mark required flags
+	importGeoIP2CSVCmd.MarkFlagRequired("blocks")
+	importGeoIP2CSVCmd.MarkFlagRequired("output")
+
+	importCmd.AddCommand(importGeoIP2CSVCmd)
+}
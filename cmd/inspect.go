@@ -17,7 +17,9 @@ limitations under the License.
 package cmd
 
 import (
-	"log"
+	"fmt"
+	"os"
+	"runtime"
 
 	"github.com/InfraZ/mmdb-cli/pkg/inspect"
 	"github.com/InfraZ/mmdb-cli/pkg/output"
@@ -39,28 +41,55 @@ var inspectCmd = &cobra.Command{
 	Short: inspectCmdShortDesc,
 	Long:  inspectCmdLongDesc + "\n\nArgs:\n  [IP/CIDR]  IP address or CIDR to inspect in the MMDB file, It can be a single or multiple IP addresses or CIDRs",
 	Run: func(cmd *cobra.Command, args []string) {
+		if cmdInspectConfig.InputList != "" {
+			// The results of a list are streamed as NDJSON unless a list
+			// format is asked for
+			cmdInspectConfig.ListFormat = inspect.FormatNDJSON
+			if cmd.Flags().Changed("format") {
+				cmdInspectConfig.ListFormat = outputOptions.Format
+			}
+
+			if err := inspect.InspectListInMMDB(cmdInspectConfig, os.Stdout); err != nil {
+				fatal(err)
+			}
+			return
+		}
+
 		// Set the inputs
 		cmdInspectConfig.Inputs = cmd.Flags().Args()
 
 		inspectResult, err := inspect.InspectInMMDB(cmdInspectConfig)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 
 		err = output.Output(inspectResult, outputOptions)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 	},
 }
 
 func init() {
 	// Add flags to the inspect command
-	inspectCmd.Flags().StringVarP(&cmdInspectConfig.InputFile, "input", "i", "", "Input path of the MMDB file")
-	inspectCmd.Flags().StringVarP(&outputOptions.Format, "format", "f", "yaml", "Output format (yaml, json, json-pretty, xml)")
+	inspectCmd.Flags().StringArrayVarP(&cmdInspectConfig.InputFiles, "input", "i", nil, "Input path of the MMDB file (.mmdb, .mmdb.gz or .mmdb.zst), optionally prefixed by an alias (asn=GeoLite2-ASN.mmdb). Repeat to merge the records of several databases, keyed by alias or deep-merged without one")
+	inspectCmd.Flags().StringVarP(&outputOptions.Format, "format", "f", "yaml", "Output format (yaml, json, json-pretty, xml), or ndjson (default) or csv with --input-list")
 	inspectCmd.Flags().StringVarP(&cmdInspectConfig.JSONPath, "jsonpath", "j", "", `JSONPath filter applied to each record (e.g. '{[?(@.country.iso_code=="US")]}')`)
 
-	inspectCmd.Args = cobra.MinimumNArgs(1)
+	inspectCmd.Flags().StringVar(&cmdInspectConfig.Mode, "mode", inspect.ModeWithin, "Inspect mode: within lists the networks within each IP/CIDR, lookup returns the network holding each IP with its prefix length")
+	inspectCmd.Flags().StringVar(&cmdInspectConfig.InputList, "input-list", "", "File holding one IP address or CIDR per line to inspect, or - for stdin, streaming the results")
+	inspectCmd.Flags().IntVar(&cmdInspectConfig.Workers, "workers", runtime.NumCPU(), "Number of queries of --input-list inspected concurrently")
+	inspectCmd.Flags().BoolVar(&cmdInspectConfig.Ordered, "ordered", false, "Write the results of --input-list in the order of the queries")
+
+	inspectCmd.Args = func(cmd *cobra.Command, args []string) error {
+		if cmdInspectConfig.InputList != "" {
+			if len(args) > 0 {
+				return fmt.Errorf("IP addresses or CIDRs cannot be passed with --input-list")
+			}
+			return nil
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	}
 
 	// Mark required flags
 	inspectCmd.MarkFlagRequired("input")
@@ -17,8 +17,6 @@ limitations under the License.
 package cmd
 
 import (
-	"log"
-
 	"github.com/InfraZ/mmdb-cli/pkg/metadata"
 	"github.com/InfraZ/mmdb-cli/pkg/output"
 
@@ -41,19 +39,19 @@ var metadataCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		metadataJson, err := metadata.MetadataMMDB(cmdMetadataConfig)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 
 		err = output.Output(metadataJson, outputOptions)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 	},
 }
 
 func init() {
 	// Add flags to the metadata command
-	metadataCmd.Flags().StringVarP(&cmdMetadataConfig.InputFile, "input", "i", "", "Input path of the MMDB file")
+	metadataCmd.Flags().StringVarP(&cmdMetadataConfig.InputFile, "input", "i", "", "Input path of the MMDB file (.mmdb, .mmdb.gz or .mmdb.zst)")
 	metadataCmd.Flags().StringVarP(&outputOptions.Format, "format", "f", "yaml", "Output format (yaml, json, json-pretty, xml)")
 
 	// Mark required flags
@@ -17,8 +17,10 @@ limitations under the License.
 package cmd
 
 import (
+	"log/slog"
 	"os"
 
+	"github.com/InfraZ/mmdb-cli/internal/logging"
 	"github.com/InfraZ/mmdb-cli/pkg/output"
 
 	"github.com/spf13/cobra"
@@ -26,6 +28,8 @@ import (
 
 var outputOptions output.OutputOptions
 
+var logOptions logging.Options
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "mmdb-cli",
@@ -33,6 +37,15 @@ var rootCmd = &cobra.Command{
 	Long: `
 InfraZ MMDB CLI is a command line toolkit for working with MMDB
 Complete documentation is available at https://docs.infraz.io/mmdb-cli`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Logs go to stderr, keeping stdout for the output of the commands
+		logger, err := logging.New(cmd.ErrOrStderr(), logOptions)
+		if err != nil {
+			return err
+		}
+		slog.SetDefault(logger)
+		return nil
+	},
 }
 
 func Execute() {
@@ -42,7 +55,16 @@ func Execute() {
 	}
 }
 
+// fatal logs the error of a command and exits.
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
+
 func init() {
+	rootCmd.PersistentFlags().StringVar(&logOptions.Level, "log-level", "info", "Minimum level of the logs (debug, info, warn, error)")
+	rootCmd.PersistentFlags().StringVar(&logOptions.Format, "log-format", logging.FormatText, "Format of the logs written to stderr (text, json)")
+
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(metadataCmd)
 	rootCmd.AddCommand(inspectCmd)
@@ -50,4 +72,8 @@ func init() {
 	rootCmd.AddCommand(dumpCmd)
 	rootCmd.AddCommand(generateCmd)
 	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(schemaCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(validateDatasetCmd)
+	rootCmd.AddCommand(serveCmd)
 }
@@ -0,0 +1,74 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"github.com/InfraZ/mmdb-cli/pkg/output"
+	"github.com/InfraZ/mmdb-cli/pkg/schema"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	schemaCmdName      = "schema"
+	schemaCmdShortDesc = "Work with dataset schemas"
+	schemaCmdLongDesc  = `This command groups the subcommands that work with dataset schemas`
+
+	schemaInferCmdName      = "infer"
+	schemaInferCmdShortDesc = "Infer a dataset schema from a MMDB file or a JSON dataset"
+	schemaInferCmdLongDesc  = `This command walks every record of a MMDB file or a v1 JSON dataset and reports the widest consistent type of each key path, the types that conflict and the percentage of records containing each key`
+)
+
+var cmdSchemaInferConfig schema.CmdSchemaInferConfig
+
+// schemaCmd represents the schema command
+var schemaCmd = &cobra.Command{
+	Use:   schemaCmdName,
+	Short: schemaCmdShortDesc,
+	Long:  schemaCmdLongDesc,
+}
+
+// schemaInferCmd represents the schema infer command
+var schemaInferCmd = &cobra.Command{
+	Use:   schemaInferCmdName,
+	Short: schemaInferCmdShortDesc,
+	Long:  schemaInferCmdLongDesc,
+	Run: func(cmd *cobra.Command, args []string) {
+		inferResult, err := schema.InferSchema(cmdSchemaInferConfig)
+		if err != nil {
+			fatal(err)
+		}
+
+		err = output.Output(inferResult, outputOptions)
+		if err != nil {
+			fatal(err)
+		}
+	},
+}
+
+func init() {
+	// Add flags to the schema infer command
+	schemaInferCmd.Flags().StringVarP(&cmdSchemaInferConfig.InputFile, "input", "i", "", "Input path of the MMDB file or JSON dataset")
+	schemaInferCmd.Flags().StringVarP(&outputOptions.Format, "format", "f", "yaml", "Output format (yaml, json, json-pretty, xml)")
+	schemaInferCmd.Flags().StringVar(&cmdSchemaInferConfig.SchemaFormat, "schema-format", schema.FormatDataset, "Format of the inferred schema (dataset, json-schema)")
+	schemaInferCmd.Flags().BoolVar(&cmdSchemaInferConfig.SchemaOnly, "schema-only", false, "Only print the inferred schema without the per key report")
+
+	// Mark required flags
+	schemaInferCmd.MarkFlagRequired("input")
+
+	schemaCmd.AddCommand(schemaInferCmd)
+}
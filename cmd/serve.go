@@ -0,0 +1,82 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"github.com/InfraZ/mmdb-cli/pkg/serve"
+	"github.com/spf13/cobra"
+)
+
+const (
+	serveCmdName      = "serve"
+	serveCmdShortDesc = "Serve lookups in MMDB files over HTTP"
+	serveCmdLongDesc  = `This command serves lookups in one or more MMDB files over HTTP:
+
+  GET  /lookup/{ip}      Record of the network holding an IP address
+  POST /lookup           Records of a JSON array of IP addresses
+  GET  /networks/{cidr}  Networks of the database within a CIDR (up to --max-networks)
+  GET  /metadata         Metadata of the database
+  GET  /healthz          Health of the server
+  GET  /readyz           Readiness of the server
+
+Every database is served under its file name without extensions, or under the
+alias prefixing its path (-i asn=GeoLite2-ASN.mmdb), the "database" query
+parameter selecting it (default the first database). The lookups and networks
+of several databases, whose names are separated by commas, merge their
+records, keyed by alias or deep-merged for the databases without one. The
+"format" query parameter sets the output format and "jsonpath" filters the
+records. GET /status reports the build epoch and the reload count of every
+database.
+
+The database files are checked for changes every --watch-interval and reloaded
+when they are replaced, as well as on SIGHUP. A new file is verified before it
+replaces the served one, and the requests in flight complete on the previous
+file.
+
+With --grpc, the lookup service of pkg/serve/lookuppb/lookup.proto is served
+instead (Lookup, BatchLookup, NetworksWithin, Metadata and Status), together with the
+standard gRPC health service.`
+)
+
+var cmdServeConfig serve.CmdServeConfig
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   serveCmdName,
+	Short: serveCmdShortDesc,
+	Long:  serveCmdLongDesc,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := serve.ServeMMDB(cmdServeConfig)
+		if err != nil {
+			fatal(err)
+		}
+	},
+}
+
+func init() {
+	// Add flags to the serve command
+	serveCmd.Flags().StringArrayVarP(&cmdServeConfig.InputFiles, "input", "i", nil, "Input path of the MMDB file (.mmdb, .mmdb.gz or .mmdb.zst), optionally prefixed by an alias (asn=GeoLite2-ASN.mmdb), repeat to serve several databases")
+	serveCmd.Flags().StringVarP(&cmdServeConfig.Address, "address", "a", ":8080", "Address to listen on")
+	serveCmd.Flags().StringVarP(&cmdServeConfig.Format, "format", "f", "json", "Default output format of the responses (yaml, json, json-pretty, xml)")
+	serveCmd.Flags().BoolVar(&cmdServeConfig.GRPC, "grpc", false, "Serve the gRPC lookup service instead of the HTTP endpoints")
+	serveCmd.Flags().DurationVar(&cmdServeConfig.WatchInterval, "watch-interval", serve.DefaultWatchInterval, "Interval at which the database files are checked for changes (0 to only reload them on SIGHUP)")
+	serveCmd.Flags().IntVar(&cmdServeConfig.MaxBatch, "max-batch", serve.DefaultMaxBatch, "Maximum number of IP addresses of a batch lookup (0 uses the default)")
+	serveCmd.Flags().IntVar(&cmdServeConfig.MaxNetworks, "max-networks", serve.DefaultMaxNetworks, "Maximum number of networks of a networks request, the larger ones failing with 413 (0 uses the default)")
+
+	// Mark required flags
+	serveCmd.MarkFlagRequired("input")
+}
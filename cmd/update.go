@@ -17,8 +17,6 @@ limitations under the License.
 package cmd
 
 import (
-	"log"
-
 	"github.com/spf13/cobra"
 
 	"github.com/InfraZ/mmdb-cli/pkg/update"
@@ -40,23 +38,29 @@ var updateCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		err := update.UpdateMMDB(cmdUpdateConfig)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 	},
 }
 
 func init() {
 	// Add flags to the update command
-	updateCmd.Flags().StringVarP(&cmdUpdateConfig.InputDatabase, "input", "i", "", "Input path of the MMDB file")
-	updateCmd.Flags().StringVarP(&cmdUpdateConfig.InputDataSet, "dataset", "d", "", "Input path of the dataset file")
-	updateCmd.Flags().StringVarP(&cmdUpdateConfig.OutputDatabase, "output", "o", "", "Output path of the MMDB file")
+	updateCmd.Flags().StringVarP(&cmdUpdateConfig.InputDatabase, "input", "i", "", "Input path of the MMDB file (.mmdb, .mmdb.gz or .mmdb.zst)")
+	updateCmd.Flags().StringVarP(&cmdUpdateConfig.InputDataSet, "dataset", "d", "", "Input path of the dataset file (.json, .ndjson/.jsonl, optionally followed by .gz or .zst), or - to read it from stdin")
+	updateCmd.Flags().StringVarP(&cmdUpdateConfig.OutputDatabase, "output", "o", "", "Output path of the MMDB file, or - to write it to stdout")
+	updateCmd.Flags().BoolVar(&cmdUpdateConfig.InPlace, "in-place", false, "Replace the input MMDB file with the updated one instead of writing to --output")
+	updateCmd.Flags().BoolVar(&cmdUpdateConfig.Backup, "backup", false, "Keep the replaced input MMDB file as <input>.bak when updating in place")
 	updateCmd.Flags().BoolVarP(&cmdUpdateConfig.Verbose, "verbose", "v", false, "Enable verbose mode")
 
 	updateCmd.Flags().BoolVar(&cmdUpdateConfig.DisableIPv4Aliasing, "disable-ipv4-aliasing", false, "Disable IPv4 aliasing")
 	updateCmd.Flags().BoolVar(&cmdUpdateConfig.IncludeReservedNetworks, "include-reserved-networks", false, "Include reserved networks")
+	updateCmd.Flags().BoolVar(&cmdUpdateConfig.Strict, "strict", false, "Fail on values that do not match the dataset schema instead of writing zero values")
+	updateCmd.Flags().BoolVar(&cmdUpdateConfig.Verify, "verify", false, "Verify the written database before moving it to the output path")
+	updateCmd.Flags().BoolVar(&cmdUpdateConfig.SkipValidation, "skip-validation", false, "Skip the validation of the dataset before processing it")
 
 	// Mark required flags
 	updateCmd.MarkFlagRequired("input")
 	updateCmd.MarkFlagRequired("dataset")
-	updateCmd.MarkFlagRequired("output")
+	updateCmd.MarkFlagsOneRequired("output", "in-place")
+	updateCmd.MarkFlagsMutuallyExclusive("output", "in-place")
 }
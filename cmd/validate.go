@@ -0,0 +1,60 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/InfraZ/mmdb-cli/pkg/validate"
+)
+
+var cmdValidateDatasetConfig validate.CmdValidateDatasetConfig
+
+const (
+	validateDatasetCmdName      = "validate-dataset"
+	validateDatasetCmdShortDesc = "Validate a dataset before generating or updating a MMDB file"
+	validateDatasetCmdLongDesc  = `This command checks the structure of a generate or update dataset and reports
+every problem found with its JSON pointer. The same validation runs before the
+generate and update commands.`
+)
+
+// validateDatasetCmd represents the validate-dataset command
+var validateDatasetCmd = &cobra.Command{
+	Use:   validateDatasetCmdName,
+	Short: validateDatasetCmdShortDesc,
+	Long:  validateDatasetCmdLongDesc,
+	Run: func(cmd *cobra.Command, args []string) {
+		entries, err := validate.ValidateDataset(cmdValidateDatasetConfig)
+		if err != nil {
+			fatal(err)
+		}
+
+		fmt.Printf("The dataset is valid (%d entries)\n", entries)
+	},
+}
+
+func init() {
+	// Add flags to the validate-dataset command
+	validateDatasetCmd.Flags().StringVarP(&cmdValidateDatasetConfig.InputDataset, "input", "i", "", "Input path of the dataset file (.json, .ndjson/.jsonl for JSON Lines, or .csv with --mapping)")
+	validateDatasetCmd.Flags().StringVarP(&cmdValidateDatasetConfig.Mapping, "mapping", "m", "", "Mapping file of the CSV columns to record fields (required for .csv datasets)")
+	validateDatasetCmd.Flags().StringVar(&cmdValidateDatasetConfig.Kind, "kind", validate.KindGenerate, "Kind of dataset to validate (generate, update, or layer for the datasets applied over the first one by generate)")
+
+	// Mark required flags
+	validateDatasetCmd.MarkFlagRequired("input")
+}
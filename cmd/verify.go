@@ -18,7 +18,6 @@ package cmd
 
 import (
 	"fmt"
-	"log"
 
 	"github.com/InfraZ/mmdb-cli/pkg/verify"
 
@@ -41,7 +40,7 @@ var verifyCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		verifyResult, err := verify.VerifyMMDB(cmdVerifyConfig)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 
 		if verifyResult {
@@ -54,7 +53,7 @@ var verifyCmd = &cobra.Command{
 
 func init() {
 	// Add flags to the inspect command
-	verifyCmd.Flags().StringVarP(&cmdVerifyConfig.InputFile, "input", "i", "", "Input path of the MMDB file")
+	verifyCmd.Flags().StringVarP(&cmdVerifyConfig.InputFile, "input", "i", "", "Input path of the MMDB file (.mmdb, .mmdb.gz or .mmdb.zst)")
 
 	// Mark required flags
 	verifyCmd.MarkFlagRequired("input")
@@ -0,0 +1,155 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package files
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// AtomicFile is written to a temporary file next to its destination and only
+// replaces the destination once committed, so that readers of the destination
+// never see a partially written file.
+type AtomicFile struct {
+	*os.File
+
+	path string
+	done bool
+}
+
+// CreateAtomic creates the temporary file of an AtomicFile for path, in the
+// same directory so that it can be renamed into place.
+func CreateAtomic(path string) (*AtomicFile, error) {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	// Temporary files are only readable by their owner, the destination is
+	// created with the usual permissions or keeps its own
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := temp.Chmod(mode); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return nil, err
+	}
+
+	return &AtomicFile{File: temp, path: path}, nil
+}
+
+// Path returns the destination of the file.
+func (f *AtomicFile) Path() string {
+	return f.path
+}
+
+// Commit flushes the file to disk and renames it to its destination. When
+// beforeRename is set, it is called with the path of the flushed temporary
+// file, to verify it for instance, and the destination is left untouched if
+// it fails.
+func (f *AtomicFile) Commit(beforeRename func(tempPath string) error) error {
+	if f.done {
+		return fmt.Errorf("file %s is already committed or aborted", f.path)
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Abort()
+		return fmt.Errorf("failed to sync %s: %w", f.Name(), err)
+	}
+	if err := f.File.Close(); err != nil {
+		f.Abort()
+		return fmt.Errorf("failed to close %s: %w", f.Name(), err)
+	}
+
+	if beforeRename != nil {
+		if err := beforeRename(f.Name()); err != nil {
+			f.Abort()
+			return err
+		}
+	}
+
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		f.Abort()
+		return fmt.Errorf("failed to move %s to %s: %w", f.Name(), f.path, err)
+	}
+	f.done = true
+
+	return syncDir(filepath.Dir(f.path))
+}
+
+// Abort removes the temporary file, leaving the destination untouched. It
+// does nothing once the file is committed, so it can be deferred right after
+// CreateAtomic.
+func (f *AtomicFile) Abort() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+	f.File.Close()
+	return os.Remove(f.Name())
+}
+
+// Backup keeps a copy of the file at path in path.bak, replacing any previous
+// backup. The backup is a hard link when the file system allows it.
+func Backup(path string) (string, error) {
+	backupPath := path + ".bak"
+	if err := os.Remove(backupPath); err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to remove previous backup %s: %w", backupPath, err)
+	}
+	if err := os.Link(path, backupPath); err == nil {
+		return backupPath, nil
+	}
+
+	source, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+
+	backup, err := CreateAtomic(backupPath)
+	if err != nil {
+		return "", err
+	}
+	defer backup.Abort()
+
+	if _, err := io.Copy(backup, source); err != nil {
+		return "", fmt.Errorf("failed to copy %s to %s: %w", path, backupPath, err)
+	}
+	if err := backup.Commit(nil); err != nil {
+		return "", err
+	}
+
+	return backupPath, nil
+}
+
+// syncDir flushes the directory entry of a renamed file to disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	// Not every platform supports syncing directories, and the file is in
+	// place either way
+	d.Sync()
+	return nil
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package files
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// dirEntries returns the names of the files in dir.
+func dirEntries(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestAtomicFileCommit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "output.mmdb")
+	if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	file, err := CreateAtomic(path)
+	if err != nil {
+		t.Fatalf("Failed to create atomic file: %v", err)
+	}
+	defer file.Abort()
+
+	if _, err := file.WriteString("new"); err != nil {
+		t.Fatalf("Failed to write atomic file: %v", err)
+	}
+
+	// The destination is untouched until the file is committed
+	if content, _ := os.ReadFile(path); string(content) != "old" {
+		t.Errorf("Expected destination to hold 'old' before commit, got %q", content)
+	}
+
+	var checkedPath string
+	err = file.Commit(func(tempPath string) error {
+		checkedPath = tempPath
+		content, err := os.ReadFile(tempPath)
+		if err != nil || string(content) != "new" {
+			t.Errorf("Expected temporary file to hold 'new', got %q (%v)", content, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to commit atomic file: %v", err)
+	}
+
+	if content, _ := os.ReadFile(path); string(content) != "new" {
+		t.Errorf("Expected destination to hold 'new' after commit, got %q", content)
+	}
+	if filepath.Dir(checkedPath) != dir {
+		t.Errorf("Expected temporary file in %s, got %s", dir, checkedPath)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected destination to keep mode 0600, got %v", info.Mode().Perm())
+	}
+	if names := dirEntries(t, dir); len(names) != 1 {
+		t.Errorf("Expected only the destination in the directory, got %v", names)
+	}
+}
+
+func TestAtomicFileFailedCheck(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "output.mmdb")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	file, err := CreateAtomic(path)
+	if err != nil {
+		t.Fatalf("Failed to create atomic file: %v", err)
+	}
+	file.WriteString("truncated")
+
+	errInvalid := errors.New("invalid database")
+	if err := file.Commit(func(string) error { return errInvalid }); !errors.Is(err, errInvalid) {
+		t.Errorf("Expected the check error, got %v", err)
+	}
+
+	if content, _ := os.ReadFile(path); string(content) != "old" {
+		t.Errorf("Expected destination to hold 'old', got %q", content)
+	}
+	if names := dirEntries(t, dir); len(names) != 1 {
+		t.Errorf("Expected the temporary file to be removed, got %v", names)
+	}
+}
+
+func TestAtomicFileAbort(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "output.json")
+
+	file, err := CreateAtomic(path)
+	if err != nil {
+		t.Fatalf("Failed to create atomic file: %v", err)
+	}
+	file.WriteString("partial")
+
+	if err := file.Abort(); err != nil {
+		t.Errorf("Failed to abort atomic file: %v", err)
+	}
+	if err := file.Abort(); err != nil {
+		t.Errorf("Expected a second abort to do nothing, got %v", err)
+	}
+	if names := dirEntries(t, dir); len(names) != 0 {
+		t.Errorf("Expected an empty directory, got %v", names)
+	}
+	if err := file.Commit(nil); err == nil {
+		t.Errorf("Expected commit of an aborted file to fail")
+	}
+}
+
+func TestBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "input.mmdb")
+	if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.WriteFile(path+".bak", []byte("previous backup"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	backupPath, err := Backup(path)
+	if err != nil {
+		t.Fatalf("Failed to back up file: %v", err)
+	}
+	if backupPath != path+".bak" {
+		t.Errorf("Expected backup at %s.bak, got %s", path, backupPath)
+	}
+	if content, _ := os.ReadFile(backupPath); string(content) != "original" {
+		t.Errorf("Expected backup to hold 'original', got %q", content)
+	}
+
+	// Replacing the original keeps the backup
+	file, err := CreateAtomic(path)
+	if err != nil {
+		t.Fatalf("Failed to create atomic file: %v", err)
+	}
+	file.WriteString("updated")
+	if err := file.Commit(nil); err != nil {
+		t.Fatalf("Failed to commit atomic file: %v", err)
+	}
+	if content, _ := os.ReadFile(backupPath); string(content) != "original" {
+		t.Errorf("Expected backup to still hold 'original', got %q", content)
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package files
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression extensions, which follow the extension of the compressed file
+// as in .json.gz.
+const (
+	ExtensionGzip = ".gz"
+	ExtensionZstd = ".zst"
+)
+
+var compressionExtensions = []string{ExtensionGzip, ExtensionZstd}
+
+// Compression returns the compression extension of the file at path, or an
+// empty string when the file is not compressed.
+func Compression(path string) string {
+	for _, extension := range compressionExtensions {
+		if strings.HasSuffix(path, extension) {
+			return extension
+		}
+	}
+	return ""
+}
+
+// TrimCompression returns path without its compression extension.
+func TrimCompression(path string) string {
+	return strings.TrimSuffix(path, Compression(path))
+}
+
+// Extension returns the extension of the file at path, which is a compound
+// extension such as .json.gz for compressed files.
+func Extension(path string) string {
+	compression := Compression(path)
+	return filepath.Ext(strings.TrimSuffix(path, compression)) + compression
+}
+
+// WithCompression returns extensions followed by their compressed variants.
+func WithCompression(extensions ...string) []string {
+	withCompression := append([]string{}, extensions...)
+	for _, compression := range compressionExtensions {
+		for _, extension := range extensions {
+			withCompression = append(withCompression, extension+compression)
+		}
+	}
+	return withCompression
+}
+
+// decompressingReader closes both the decompressor and the underlying file.
+type decompressingReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *decompressingReader) Close() error {
+	var firstErr error
+	for _, closer := range r.closers {
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// OpenDecompressed opens the file at path, or the standard input when path is
+// Stdio, and decompresses its content according to its compression
+// extension.
+func OpenDecompressed(path string) (io.ReadCloser, error) {
+	var file io.ReadCloser
+	if IsStdio(path) {
+		file = io.NopCloser(os.Stdin)
+	} else {
+		opened, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		file = opened
+	}
+
+	reader, err := Decompress(file, Compression(path))
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+	}
+	return &decompressingReader{Reader: reader, closers: []io.Closer{reader, file}}, nil
+}
+
+// Decompress returns a reader decompressing source with the compression of
+// the given extension, or reading it as is when extension is empty. Closing
+// the reader does not close source.
+func Decompress(source io.Reader, extension string) (io.ReadCloser, error) {
+	switch extension {
+	case "":
+		return io.NopCloser(source), nil
+	case ExtensionGzip:
+		return gzip.NewReader(source)
+	case ExtensionZstd:
+		decoder, err := zstd.NewReader(source)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %s", extension)
+	}
+}
+
+// Compress returns a writer compressing to w with the compression of the
+// given extension, or writing to w as is when extension is empty. The writer
+// must be closed to flush the compressed data, which does not close w.
+func Compress(w io.Writer, extension string) (io.WriteCloser, error) {
+	switch extension {
+	case "":
+		return &nopWriteCloser{w}, nil
+	case ExtensionGzip:
+		return gzip.NewWriter(w), nil
+	case ExtensionZstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported compression %s", extension)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// DecompressToTemp decompresses the file at path to a temporary file, for
+// the libraries that can only read plain files. The caller removes the
+// temporary file.
+func DecompressToTemp(path string) (string, error) {
+	source, err := OpenDecompressed(path)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+
+	temp, err := os.CreateTemp("", "mmdb-cli-*"+filepath.Ext(TrimCompression(path)))
+	if err != nil {
+		return "", err
+	}
+	defer temp.Close()
+
+	if _, err := io.Copy(temp, source); err != nil {
+		os.Remove(temp.Name())
+		return "", fmt.Errorf("failed to decompress %s: %w", path, err)
+	}
+
+	return temp.Name(), nil
+}
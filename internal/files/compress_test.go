@@ -0,0 +1,92 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package files
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExtension(t *testing.T) {
+	tests := map[string]string{
+		"dataset.json":          ".json",
+		"dataset.json.gz":       ".json.gz",
+		"dir.d/dataset.ndjson":  ".ndjson",
+		"GeoLite2-City.mmdb.gz": ".mmdb.gz",
+		"dataset.json.zst":      ".json.zst",
+		"dataset":               "",
+	}
+	for path, want := range tests {
+		if got := Extension(path); got != want {
+			t.Errorf("Extension(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	if !CheckFileExtension("dataset.json.gz", ".json.gz") || CheckFileExtension("dataset.json.gz", ".json") {
+		t.Error("Expected compound extensions to be matched as a whole")
+	}
+}
+
+func TestWithCompression(t *testing.T) {
+	got := WithCompression(".json", ".mmdb")
+	want := []string{".json", ".mmdb", ".json.gz", ".mmdb.gz", ".json.zst", ".mmdb.zst"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WithCompression() = %v, want %v", got, want)
+	}
+}
+
+func TestCompressRoundTrip(t *testing.T) {
+	for _, compression := range []string{"", ExtensionGzip, ExtensionZstd} {
+		path := filepath.Join(t.TempDir(), "dataset.json"+compression)
+
+		var compressed bytes.Buffer
+		writer, err := Compress(&compressed, compression)
+		if err != nil {
+			t.Fatalf("Failed to create compressor for %q: %v", compression, err)
+		}
+		writer.Write([]byte(`{"dataset":[]}`))
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Failed to close compressor for %q: %v", compression, err)
+		}
+		if err := os.WriteFile(path, compressed.Bytes(), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		reader, err := OpenDecompressed(path)
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", path, err)
+		}
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil || string(content) != `{"dataset":[]}` {
+			t.Errorf("Expected decompressed content of %s, got %q (%v)", path, content, err)
+		}
+
+		tempPath, err := DecompressToTemp(path)
+		if err != nil {
+			t.Fatalf("Failed to decompress %s: %v", path, err)
+		}
+		defer os.Remove(tempPath)
+		if filepath.Ext(tempPath) != ".json" {
+			t.Errorf("Expected the temporary file to keep the .json extension, got %s", tempPath)
+		}
+	}
+}
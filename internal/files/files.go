@@ -26,6 +26,13 @@ type FilesListValidation struct {
 	FilePath          string
 	ExpectedExtension string
 	ShouldExist       bool
+
+	// ExpectedExtensions accepts any of several extensions and takes
+	// precedence over ExpectedExtension when set
+	ExpectedExtensions []string
+
+	// AllowStdio accepts Stdio in place of a file path
+	AllowStdio bool
 }
 
 // CheckFileExists checks if a file exists
@@ -49,17 +56,40 @@ func CheckFileSizeMb(filePath string) (float64, error) {
 	return float64(fileInfo.Size()) / 1024 / 1024, nil
 }
 
-// CheckFileExtension checks if a file has a specific extension
+// CheckFileExtension checks if a file has a specific extension. Compound
+// extensions are matched as a whole, so a .json.gz file has the .json.gz
+// extension but not the .json one, see WithCompression.
 func CheckFileExtension(filePath string, extension string) bool {
 	return strings.HasSuffix(filePath, extension)
 }
 
+// CheckFileExtensions checks if a file has one of the given extensions
+func CheckFileExtensions(filePath string, extensions []string) bool {
+	for _, extension := range extensions {
+		if CheckFileExtension(filePath, extension) {
+			return true
+		}
+	}
+	return false
+}
+
 func FilesValidation(filesList []FilesListValidation) error {
 	for _, file := range filesList {
+		if file.AllowStdio && IsStdio(file.FilePath) {
+			continue
+		}
+
 		if file.ShouldExist && !CheckFileExists(file.FilePath) {
 			return fmt.Errorf("[!] File %s does not exist", file.FilePath)
 		}
 
+		if len(file.ExpectedExtensions) > 0 {
+			if !CheckFileExtensions(file.FilePath, file.ExpectedExtensions) {
+				return fmt.Errorf("[!] File %s must have one of the extensions %s", file.FilePath, strings.Join(file.ExpectedExtensions, ", "))
+			}
+			continue
+		}
+
 		if !CheckFileExtension(file.FilePath, file.ExpectedExtension) {
 			return fmt.Errorf("[!] File %s must have a %s extension", file.FilePath, file.ExpectedExtension)
 		}
@@ -151,6 +151,20 @@ func TestFilesValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "File has one of the expected extensions",
+			filesList: []FilesListValidation{
+				{FilePath: tmpFile.Name(), ExpectedExtensions: []string{".pdf", ".txt"}, ShouldExist: true},
+			},
+			wantErr: false,
+		},
+		{
+			name: "File has none of the expected extensions",
+			filesList: []FilesListValidation{
+				{FilePath: tmpFile.Name(), ExpectedExtensions: []string{".pdf", ".doc"}, ShouldExist: true},
+			},
+			wantErr: true,
+		},
 		{
 			name: "File exists and should not exist",
 			filesList: []FilesListValidation{
@@ -158,6 +172,20 @@ func TestFilesValidation(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Standard input allowed",
+			filesList: []FilesListValidation{
+				{FilePath: Stdio, ExpectedExtension: ".json", ShouldExist: true, AllowStdio: true},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Standard input not allowed",
+			filesList: []FilesListValidation{
+				{FilePath: Stdio, ExpectedExtension: ".json", ShouldExist: true},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,67 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package files
+
+import (
+	"io"
+	"os"
+)
+
+// Stdio is the path standing for the standard input of the input files and
+// for the standard output of the output files.
+const Stdio = "-"
+
+// IsStdio reports whether path stands for the standard input or output.
+func IsStdio(path string) bool {
+	return path == Stdio
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// WriteOutput writes src to the file at path through an AtomicFile committed
+// with beforeRename, or to the standard output when path is Stdio, in which
+// case beforeRename is not called. It returns the number of bytes written.
+func WriteOutput(path string, src io.WriterTo, beforeRename func(tempPath string) error) (int64, error) {
+	if IsStdio(path) {
+		output := &countingWriter{w: os.Stdout}
+		_, err := src.WriteTo(output)
+		return output.n, err
+	}
+
+	outputFile, err := CreateAtomic(path)
+	if err != nil {
+		return 0, err
+	}
+	defer outputFile.Abort()
+
+	output := &countingWriter{w: outputFile}
+	if _, err := src.WriteTo(output); err != nil {
+		return output.n, err
+	}
+
+	return output.n, outputFile.Commit(beforeRename)
+}
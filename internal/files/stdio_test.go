@@ -0,0 +1,65 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package files
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.mmdb")
+
+	n, err := WriteOutput(path, bytes.NewBufferString("content"), nil)
+	if err != nil {
+		t.Fatalf("Failed to write output: %v", err)
+	}
+	if n != 7 {
+		t.Errorf("Expected 7 bytes written, got %d", n)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "content" {
+		t.Errorf("Expected output to hold 'content', got %q", content)
+	}
+}
+
+func TestWriteOutputStdout(t *testing.T) {
+	oldStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+
+	n, err := WriteOutput(Stdio, bytes.NewBufferString("content"), func(string) error {
+		t.Error("Expected beforeRename not to be called for the standard output")
+		return nil
+	})
+
+	w.Close()
+	os.Stdout = oldStdout
+
+	if err != nil {
+		t.Fatalf("Failed to write output: %v", err)
+	}
+	var stdout bytes.Buffer
+	stdout.ReadFrom(r)
+	if stdout.String() != "content" || n != 7 {
+		t.Errorf("Expected 7 bytes of content on stdout, got %d bytes: %q", n, stdout.String())
+	}
+}
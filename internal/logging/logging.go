@@ -0,0 +1,66 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logging configures the slog logger of the command line.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Formats of the logs.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type Options struct {
+	// Level is the minimum level logged: debug, info, warn or error
+	Level string
+
+	// Format is FormatText or FormatJSON
+	Format string
+}
+
+// ParseLevel returns the slog level named level.
+func ParseLevel(level string) (slog.Level, error) {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(level)); err != nil {
+		return 0, fmt.Errorf("unsupported log level '%s' (supported: debug, info, warn, error)", level)
+	}
+	return parsed, nil
+}
+
+// New returns a logger writing to w with the level and the format of opts.
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, err
+	}
+	handlerOptions := &slog.HandlerOptions{Level: level}
+
+	switch strings.ToLower(opts.Format) {
+	case FormatText, "":
+		return slog.New(slog.NewTextHandler(w, handlerOptions)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, handlerOptions)), nil
+	default:
+		return nil, fmt.Errorf("unsupported log format '%s' (supported: %s, %s)", opts.Format, FormatText, FormatJSON)
+	}
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Options{Level: "warn", Format: FormatJSON})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	logger.Info("hidden")
+	logger.Warn("shown", "records", 2)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 log line, got %d: %q", len(lines), buf.String())
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Expected a JSON log line: %v", err)
+	}
+	if entry["msg"] != "shown" || entry["level"] != "WARN" || entry["records"] != float64(2) {
+		t.Errorf("Unexpected log entry: %v", entry)
+	}
+
+	buf.Reset()
+	logger, err = New(&buf, Options{Level: "debug", Format: FormatText})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.Debug("details")
+	if !strings.Contains(buf.String(), "level=DEBUG msg=details") {
+		t.Errorf("Unexpected text log: %q", buf.String())
+	}
+}
+
+func TestNewInvalidOptions(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, Options{Level: "verbose", Format: FormatText}); err == nil {
+		t.Error("Expected an error for an unsupported level")
+	}
+	if _, err := New(&bytes.Buffer{}, Options{Level: "info", Format: "xml"}); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+}
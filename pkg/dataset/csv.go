@@ -0,0 +1,313 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataset
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
+)
+
+/*
+CSVMapping describes how the columns of a CSV file, whose first row holds the
+column names, map to dataset entries:
+
+	{
+		"network": "<NETWORK COLUMN>",
+		"start_ip": "<FIRST IP COLUMN>",
+		"end_ip": "<LAST IP COLUMN>",
+		"delimiter": ",",
+		"metadata": {
+			<METADATA>
+		},
+		"columns": {
+			"<COLUMN>": {"path": "<DOTTED RECORD PATH>", "type": "<SCHEMA TYPE>"}
+		}
+	}
+
+Either the network column or both IP columns must be set. Each mapped column
+is written to the record at its dotted path, so "country.iso_code" ends up as
+{"country": {"iso_code": ...}}, and the types of the columns make up the
+dataset schema. Columns without a type are strings, and empty cells are left
+out of the record.
+*/
+type CSVMapping struct {
+	Network   string                 `json:"network"`
+	StartIP   string                 `json:"start_ip"`
+	EndIP     string                 `json:"end_ip"`
+	Delimiter string                 `json:"delimiter"`
+	Metadata  map[string]interface{} `json:"metadata"`
+	Columns   map[string]CSVColumn   `json:"columns"`
+}
+
+type CSVColumn struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+}
+
+// csvRows reads the rows of a CSV dataset and turns them into entries.
+type csvRows struct {
+	reader  *csv.Reader
+	mapping *CSVMapping
+	index   map[string]int
+}
+
+// ReadCSVMapping reads and validates the mapping file at path.
+func ReadCSVMapping(path string) (*CSVMapping, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping file: %w", err)
+	}
+
+	var mapping CSVMapping
+	if err := json.Unmarshal(content, &mapping); err != nil {
+		return nil, fmt.Errorf("invalid mapping file %s: %w", path, err)
+	}
+
+	if err := mapping.validate(); err != nil {
+		return nil, fmt.Errorf("invalid mapping file %s: %w", path, err)
+	}
+
+	return &mapping, nil
+}
+
+func (m *CSVMapping) validate() error {
+	if m.Network == "" && (m.StartIP == "" || m.EndIP == "") {
+		return fmt.Errorf("either 'network' or both 'start_ip' and 'end_ip' must name a column")
+	}
+	if m.Network != "" && (m.StartIP != "" || m.EndIP != "") {
+		return fmt.Errorf("'network' cannot be combined with 'start_ip' and 'end_ip'")
+	}
+	if m.Delimiter != "" && utf8.RuneCountInString(m.Delimiter) != 1 {
+		return fmt.Errorf("delimiter must be a single character")
+	}
+	if len(m.Columns) == 0 {
+		return fmt.Errorf("no columns are mapped to record fields")
+	}
+
+	for column, mappedColumn := range m.Columns {
+		if mappedColumn.Path == "" {
+			return fmt.Errorf("column %s has no record path", column)
+		}
+		if mappedColumn.Type != "" && !mmdb.IsSchemaType(mappedColumn.Type) {
+			return fmt.Errorf("column %s has unsupported type %s", column, mappedColumn.Type)
+		}
+	}
+
+	_, err := m.Schema()
+	return err
+}
+
+// Schema returns the dataset schema described by the mapped columns.
+func (m *CSVMapping) Schema() (map[string]interface{}, error) {
+	schema := map[string]interface{}{}
+
+	// Sort the columns so that conflicts are reported consistently
+	columns := make([]string, 0, len(m.Columns))
+	for column := range m.Columns {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	for _, column := range columns {
+		mappedColumn := m.Columns[column]
+		columnType := mappedColumn.Type
+		if columnType == "" {
+			columnType = "string"
+		}
+		if err := setPath(schema, mappedColumn.Path, columnType); err != nil {
+			return nil, fmt.Errorf("column %s: %w", column, err)
+		}
+	}
+
+	return schema, nil
+}
+
+// setPath sets value at the dotted path of target, creating the nested
+// objects on the way.
+func setPath(target map[string]interface{}, path string, value interface{}) error {
+	keys := strings.Split(path, ".")
+	current := target
+
+	for i, key := range keys[:len(keys)-1] {
+		next, exists := current[key]
+		if !exists {
+			nested := map[string]interface{}{}
+			current[key] = nested
+			current = nested
+			continue
+		}
+		nested, ok := next.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("path %s is already set to a value", strings.Join(keys[:i+1], "."))
+		}
+		current = nested
+	}
+
+	leaf := keys[len(keys)-1]
+	if _, exists := current[leaf]; exists {
+		return fmt.Errorf("path %s is already in use", path)
+	}
+	current[leaf] = value
+
+	return nil
+}
+
+// OpenCSV opens the CSV dataset at path for streaming, using the mapping
+// file at mappingPath to build the entries.
+func OpenCSV(path string, mappingPath string) (*Reader, error) {
+	mapping, err := ReadCSVMapping(mappingPath)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("file %s does not exist", path)
+	}
+
+	datasetFile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := NewCSVReader(datasetFile, mapping)
+	if err != nil {
+		datasetFile.Close()
+		return nil, err
+	}
+	reader.closer = datasetFile
+
+	return reader, nil
+}
+
+// NewCSVReader reads the column names from the first row of source and
+// returns a reader whose header holds the schema and metadata of mapping.
+func NewCSVReader(source io.Reader, mapping *CSVMapping) (*Reader, error) {
+	schema, err := mapping.Schema()
+	if err != nil {
+		return nil, err
+	}
+
+	csvReader := csv.NewReader(source)
+	if mapping.Delimiter != "" {
+		csvReader.Comma, _ = utf8.DecodeRuneInString(mapping.Delimiter)
+	}
+
+	columnNames, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV column names: %w", err)
+	}
+
+	index := make(map[string]int, len(columnNames))
+	for i, columnName := range columnNames {
+		index[strings.TrimSpace(columnName)] = i
+	}
+
+	required := []string{mapping.Network, mapping.StartIP, mapping.EndIP}
+	for column := range mapping.Columns {
+		required = append(required, column)
+	}
+	for _, column := range required {
+		if _, exists := index[column]; column != "" && !exists {
+			return nil, fmt.Errorf("column %s is not in the CSV file", column)
+		}
+	}
+
+	header := map[string]interface{}{
+		"version": "v1",
+		"schema":  schema,
+	}
+	if mapping.Metadata != nil {
+		header["metadata"] = mapping.Metadata
+	}
+
+	return &Reader{
+		Header: header,
+		rows: &csvRows{
+			reader:  csvReader,
+			mapping: mapping,
+			index:   index,
+		},
+	}, nil
+}
+
+// nextRow turns the next CSV row into a dataset entry. Rows mapped with IP
+// columns hold their "first-last" range as network.
+func (r *Reader) nextRow() (map[string]interface{}, error) {
+	row, err := r.rows.reader.Read()
+	if err == io.EOF {
+		r.done = true
+		return nil, io.EOF
+	}
+
+	r.position++
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV row %d: %w", r.position, err)
+	}
+
+	mapping := r.rows.mapping
+	entry := map[string]interface{}{}
+	if mapping.Network != "" {
+		entry["network"] = strings.TrimSpace(row[r.rows.index[mapping.Network]])
+	} else {
+		entry["network"] = strings.TrimSpace(row[r.rows.index[mapping.StartIP]]) + "-" + strings.TrimSpace(row[r.rows.index[mapping.EndIP]])
+	}
+
+	record := map[string]interface{}{}
+	for column, mappedColumn := range mapping.Columns {
+		cell := row[r.rows.index[column]]
+		if cell == "" {
+			continue
+		}
+		if err := setPath(record, mappedColumn.Path, csvValue(cell, mappedColumn.Type)); err != nil {
+			return nil, fmt.Errorf("CSV row %d: %w", r.position, err)
+		}
+	}
+	entry["record"] = record
+
+	return entry, nil
+}
+
+// csvValue converts a cell to the value a JSON dataset would hold for the
+// column type, so that the record goes through the same conversion as JSON
+// records. Cells that do not parse are kept as strings and reported by the
+// conversion like any other mismatched value.
+func csvValue(cell string, columnType string) interface{} {
+	switch columnType {
+	case "bool", "boolean":
+		if b, err := strconv.ParseBool(strings.TrimSpace(cell)); err == nil {
+			return b
+		}
+	case "float", "float64", "float32", "uint16", "int", "int32", "uint", "uint32":
+		if f, err := strconv.ParseFloat(strings.TrimSpace(cell), 64); err == nil {
+			return f
+		}
+	case "uint64", "uint128":
+		// Kept as strings to preserve precision beyond float64
+		return strings.TrimSpace(cell)
+	}
+	return cell
+}
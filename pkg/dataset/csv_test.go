@@ -0,0 +1,228 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataset
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSVMappingValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		mapping     CSVMapping
+		errContains string
+	}{
+		{
+			name: "network column",
+			mapping: CSVMapping{
+				Network: "network",
+				Columns: map[string]CSVColumn{"cc": {Path: "country.iso_code"}},
+			},
+		},
+		{
+			name: "IP columns",
+			mapping: CSVMapping{
+				StartIP: "start",
+				EndIP:   "end",
+				Columns: map[string]CSVColumn{"asn": {Path: "asn", Type: "uint32"}},
+			},
+		},
+		{
+			name:        "no network column",
+			mapping:     CSVMapping{Columns: map[string]CSVColumn{"cc": {Path: "cc"}}},
+			errContains: "either 'network' or both",
+		},
+		{
+			name: "network and IP columns",
+			mapping: CSVMapping{
+				Network: "network",
+				StartIP: "start",
+				EndIP:   "end",
+				Columns: map[string]CSVColumn{"cc": {Path: "cc"}},
+			},
+			errContains: "cannot be combined",
+		},
+		{
+			name:        "no columns",
+			mapping:     CSVMapping{Network: "network"},
+			errContains: "no columns",
+		},
+		{
+			name: "unsupported type",
+			mapping: CSVMapping{
+				Network: "network",
+				Columns: map[string]CSVColumn{"cc": {Path: "cc", Type: "char"}},
+			},
+			errContains: "unsupported type char",
+		},
+		{
+			name: "conflicting paths",
+			mapping: CSVMapping{
+				Network: "network",
+				Columns: map[string]CSVColumn{
+					"a": {Path: "country"},
+					"b": {Path: "country.iso_code"},
+				},
+			},
+			errContains: "path country is already set",
+		},
+		{
+			name: "multi-character delimiter",
+			mapping: CSVMapping{
+				Network:   "network",
+				Delimiter: "::",
+				Columns:   map[string]CSVColumn{"cc": {Path: "cc"}},
+			},
+			errContains: "single character",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.mapping.validate()
+			if tt.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errContains)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNewCSVReader(t *testing.T) {
+	t.Parallel()
+
+	mapping := &CSVMapping{
+		Network:  "network",
+		Metadata: map[string]interface{}{"DatabaseType": "Test"},
+		Columns: map[string]CSVColumn{
+			"cc":      {Path: "country.iso_code"},
+			"asn":     {Path: "asn", Type: "uint32"},
+			"anycast": {Path: "is_anycast", Type: "bool"},
+			"big":     {Path: "big", Type: "uint64"},
+		},
+	}
+
+	content := "network,cc,asn,anycast,big\n" +
+		"1.0.0.0/24,AU,13335,true,18446744073709551615\n" +
+		"2.0.0.0/24,,not-a-number,,\n"
+
+	reader, err := NewCSVReader(strings.NewReader(content), mapping)
+	require.NoError(t, err)
+
+	assert.Equal(t, "v1", reader.Header["version"])
+	assert.Equal(t, map[string]interface{}{"DatabaseType": "Test"}, reader.Header["metadata"])
+	assert.Equal(t, map[string]interface{}{
+		"country":    map[string]interface{}{"iso_code": "string"},
+		"asn":        "uint32",
+		"is_anycast": "bool",
+		"big":        "uint64",
+	}, reader.Header["schema"])
+
+	entries := readAll(t, reader)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, map[string]interface{}{
+		"network": "1.0.0.0/24",
+		"record": map[string]interface{}{
+			"country":    map[string]interface{}{"iso_code": "AU"},
+			"asn":        float64(13335),
+			"is_anycast": true,
+			"big":        "18446744073709551615",
+		},
+	}, entries[0])
+
+	// Empty cells are left out and unparsable cells kept for the conversion
+	// to report
+	assert.Equal(t, map[string]interface{}{
+		"network": "2.0.0.0/24",
+		"record":  map[string]interface{}{"asn": "not-a-number"},
+	}, entries[1])
+
+	t.Run("IP columns and delimiter", func(t *testing.T) {
+		t.Parallel()
+		mapping := &CSVMapping{
+			StartIP:   "first",
+			EndIP:     "last",
+			Delimiter: ";",
+			Columns:   map[string]CSVColumn{"name": {Path: "name"}},
+		}
+
+		reader, err := NewCSVReader(strings.NewReader("first;last;name\n1.0.0.0;1.0.0.255;a\n"), mapping)
+		require.NoError(t, err)
+
+		entries := readAll(t, reader)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "1.0.0.0-1.0.0.255", entries[0]["network"])
+	})
+
+	t.Run("missing column", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewCSVReader(strings.NewReader("network,cc\n"), mapping)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is not in the CSV file")
+	})
+
+	t.Run("row with wrong number of fields", func(t *testing.T) {
+		t.Parallel()
+		reader, err := NewCSVReader(strings.NewReader("network,cc,asn,anycast,big\n1.0.0.0/24,AU\n"), mapping)
+		require.NoError(t, err)
+
+		_, err = reader.Next()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid CSV row 1")
+	})
+}
+
+func TestOpenCSV(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "data.csv")
+	require.NoError(t, os.WriteFile(csvPath, []byte("network,cc\n1.0.0.0/24,AU\n"), 0644))
+	mappingPath := filepath.Join(dir, "mapping.json")
+	require.NoError(t, os.WriteFile(mappingPath, []byte(`{"network":"network","columns":{"cc":{"path":"country.iso_code"}}}`), 0644))
+
+	reader, err := OpenCSV(csvPath, mappingPath)
+	require.NoError(t, err)
+	defer reader.Close()
+	assert.Len(t, readAll(t, reader), 1)
+
+	t.Run("invalid mapping", func(t *testing.T) {
+		t.Parallel()
+		badMapping := filepath.Join(t.TempDir(), "mapping.json")
+		require.NoError(t, os.WriteFile(badMapping, []byte(`{"columns":{}}`), 0644))
+		_, err := OpenCSV(csvPath, badMapping)
+		assert.Error(t, err)
+	})
+
+	t.Run("non-existent file", func(t *testing.T) {
+		t.Parallel()
+		_, err := OpenCSV("/nonexistent/file.csv", mappingPath)
+		assert.Error(t, err)
+	})
+}
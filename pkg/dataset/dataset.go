@@ -0,0 +1,474 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataset
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/InfraZ/mmdb-cli/internal/files"
+)
+
+// Extensions of the supported dataset files, which can be compressed with
+// gzip or zstd. Files ending in one of LinesExtensions, before their
+// compression extension, are read as JSON Lines, anything else as a v1 JSON
+// document.
+var (
+	Extensions      = files.WithCompression(".json", ".ndjson", ".jsonl")
+	LinesExtensions = []string{".ndjson", ".jsonl"}
+)
+
+// ErrNotObject is returned by Next for an entry that is valid JSON but not an
+// object. The reader can carry on with the following entries.
+var ErrNotObject = errors.New("not a valid object")
+
+// IsLines reports whether the dataset file at path uses the JSON Lines
+// format, based on its extension.
+func IsLines(path string) bool {
+	path = files.TrimCompression(path)
+	for _, extension := range LinesExtensions {
+		if strings.HasSuffix(path, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Reader streams a v1 JSON dataset:
+
+	{
+		"version": "v1",
+		"schema": {
+			<SCHEMA>
+		},
+		"metadata": {
+			<METADATA>
+		},
+		"dataset": [
+			<ENTRY>,
+			...
+		]
+	}
+
+All top-level fields except "dataset" are decoded into Header when the reader
+is created, and the entries of "dataset" are then decoded one at a time by
+Next, so memory use does not grow with the size of the dataset. When the
+dataset array comes before version, schema or metadata, as in documents whose
+keys are sorted, the reader skips over it to read the remaining fields and
+then reads the document again from the start to stream the entries. A source
+that cannot be read again, such as the standard input, needs the header fields
+before the dataset array, and Next fails when one of them follows it.
+
+The JSON Lines variant of the format, read by NewLinesReader, holds one JSON
+object per line. The first line is an optional header with the same top-level
+fields as the document, and every following line is a dataset entry:
+
+	{"version":"v1","schema":{<SCHEMA>},"metadata":{<METADATA>}}
+	{"network":"<NETWORK>","record":{<RECORD>}}
+	...
+*/
+type Reader struct {
+	Header map[string]interface{}
+
+	closer   io.Closer
+	decoder  *json.Decoder
+	position int
+	done     bool
+
+	// rewound is set when the fields following the dataset array were read
+	// before the source was read again
+	rewound bool
+
+	// lines is set for JSON Lines datasets, where pending holds the first
+	// entry when the dataset has no header line
+	lines   bool
+	pending map[string]interface{}
+
+	// rows is set for CSV datasets read with a mapping
+	rows *csvRows
+}
+
+// headerFields are the fields that have to be known before the first entry
+// is processed.
+var headerFields = []string{"version", "schema", "metadata"}
+
+// Open opens the dataset file at path for streaming, as JSON Lines when it
+// has one of LinesExtensions, and decompresses it when it has a compression
+// extension.
+func Open(path string) (*Reader, error) {
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("file %s does not exist", path)
+	}
+
+	datasetFile, err := files.OpenDecompressed(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The file is opened again to read a dataset array that came before some
+	// of the header fields, which the standard input cannot do
+	var reopen func() (io.Reader, error)
+	if !files.IsStdio(path) {
+		reopen = func() (io.Reader, error) {
+			datasetFile.Close()
+			reopened, err := files.OpenDecompressed(path)
+			if err != nil {
+				datasetFile = nil
+				return nil, err
+			}
+			datasetFile = reopened
+			return reopened, nil
+		}
+	}
+
+	var reader *Reader
+	if IsLines(path) {
+		reader, err = NewLinesReader(datasetFile)
+	} else {
+		reader, err = newReader(datasetFile, reopen)
+	}
+	if err != nil {
+		if datasetFile != nil {
+			datasetFile.Close()
+		}
+		return nil, err
+	}
+	reader.closer = datasetFile
+
+	return reader, nil
+}
+
+// NewReader reads the header of the dataset from source and positions the
+// reader at the first dataset entry. When source can seek, it is read again
+// from the start if some header fields follow the dataset array.
+func NewReader(source io.Reader) (*Reader, error) {
+	var rewind func() (io.Reader, error)
+	if seeker, ok := source.(io.ReadSeeker); ok {
+		rewind = func() (io.Reader, error) {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+			return seeker, nil
+		}
+	}
+
+	return newReader(source, rewind)
+}
+
+// newReader reads the header of the dataset from source, calling rewind to
+// read it again from the start when some header fields follow the dataset
+// array. rewind is nil for a source that cannot be read again.
+func newReader(source io.Reader, rewind func() (io.Reader, error)) (*Reader, error) {
+	r := &Reader{
+		Header:  map[string]interface{}{},
+		decoder: json.NewDecoder(source),
+	}
+
+	if err := r.readHeader(); err != nil {
+		return nil, err
+	}
+	if rewind == nil || r.headerComplete() {
+		return r, nil
+	}
+
+	// The rest of the document may hold header fields, which are read
+	// before the dataset array is read again
+	if err := skipNested(r.decoder, 1); err != nil {
+		return nil, err
+	}
+	for r.decoder.More() {
+		if err := r.readField(); err != nil {
+			return nil, err
+		}
+	}
+	if err := expectDelim(r.decoder, '}'); err != nil {
+		return nil, err
+	}
+
+	source, err := rewind()
+	if err != nil {
+		return nil, fmt.Errorf("failed to rewind dataset: %w", err)
+	}
+	r.decoder = json.NewDecoder(source)
+	r.rewound = true
+	if err := r.seekDataset(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// NewLinesReader reads the optional header line of a JSON Lines dataset from
+// source. The first line is taken as the header when it has no "network"
+// field.
+func NewLinesReader(source io.Reader) (*Reader, error) {
+	r := &Reader{
+		Header:  map[string]interface{}{},
+		decoder: json.NewDecoder(source),
+		lines:   true,
+	}
+
+	if !r.decoder.More() {
+		return r, nil
+	}
+
+	var first interface{}
+	if err := r.decoder.Decode(&first); err != nil {
+		return nil, fmt.Errorf("invalid first line: %w", err)
+	}
+
+	firstMap, ok := first.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("dataset item 1 is not a valid object")
+	}
+
+	if _, isEntry := firstMap["network"]; isEntry {
+		r.pending = firstMap
+	} else {
+		r.Header = firstMap
+	}
+
+	return r, nil
+}
+
+// readHeader decodes the top-level fields up to the opening bracket of the
+// dataset array.
+func (r *Reader) readHeader() error {
+	if err := expectDelim(r.decoder, '{'); err != nil {
+		return err
+	}
+
+	for r.decoder.More() {
+		key, err := readKey(r.decoder)
+		if err != nil {
+			return err
+		}
+
+		if key == "dataset" {
+			if err := expectDelim(r.decoder, '['); err != nil {
+				return fmt.Errorf("dataset field is not an array")
+			}
+			return nil
+		}
+
+		if err := r.readValue(key); err != nil {
+			return err
+		}
+	}
+
+	return fmt.Errorf("no 'dataset' field found in input data")
+}
+
+func (r *Reader) headerComplete() bool {
+	for _, field := range headerFields {
+		if _, exists := r.Header[field]; !exists {
+			return false
+		}
+	}
+	return true
+}
+
+// readField decodes the next top-level field into Header.
+func (r *Reader) readField() error {
+	key, err := readKey(r.decoder)
+	if err != nil {
+		return err
+	}
+	return r.readValue(key)
+}
+
+func (r *Reader) readValue(key string) error {
+	var value interface{}
+	if err := r.decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid value for field %q: %w", key, err)
+	}
+	r.Header[key] = value
+	return nil
+}
+
+// seekDataset positions the decoder of a source read again right after the
+// opening bracket of the dataset array, skipping the fields before it.
+func (r *Reader) seekDataset() error {
+	if err := expectDelim(r.decoder, '{'); err != nil {
+		return err
+	}
+
+	for r.decoder.More() {
+		key, err := readKey(r.decoder)
+		if err != nil {
+			return err
+		}
+		if key == "dataset" {
+			return expectDelim(r.decoder, '[')
+		}
+		if err := skipValue(r.decoder); err != nil {
+			return err
+		}
+	}
+
+	return fmt.Errorf("no 'dataset' field found in input data")
+}
+
+// readTrailer decodes the top-level fields after the dataset array. They were
+// already read when the source was rewound, otherwise they cannot be header
+// fields since the entries were already processed.
+func (r *Reader) readTrailer() error {
+	for r.decoder.More() {
+		key, err := readKey(r.decoder)
+		if err != nil {
+			return err
+		}
+		if r.rewound {
+			if err := skipValue(r.decoder); err != nil {
+				return err
+			}
+			continue
+		}
+		if slices.Contains(headerFields, key) {
+			return fmt.Errorf("field %q must come before the dataset when the dataset cannot be read again", key)
+		}
+
+		if err := r.readValue(key); err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(r.decoder, '}')
+}
+
+// Next returns the next dataset entry, or io.EOF after the last one.
+func (r *Reader) Next() (map[string]interface{}, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+
+	if r.rows != nil {
+		return r.nextRow()
+	}
+
+	if r.pending != nil {
+		entry := r.pending
+		r.pending = nil
+		r.position++
+		return entry, nil
+	}
+
+	if !r.decoder.More() {
+		if !r.lines {
+			if err := expectDelim(r.decoder, ']'); err != nil {
+				return nil, err
+			}
+			if err := r.readTrailer(); err != nil {
+				return nil, err
+			}
+		}
+		r.done = true
+		return nil, io.EOF
+	}
+
+	r.position++
+
+	var entry interface{}
+	if err := r.decoder.Decode(&entry); err != nil {
+		return nil, fmt.Errorf("invalid dataset item %d: %w", r.position, err)
+	}
+
+	entryMap, ok := entry.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("dataset item %d is %w", r.position, ErrNotObject)
+	}
+
+	return entryMap, nil
+}
+
+// Position returns the 1-based position of the entry last returned by Next.
+func (r *Reader) Position() int {
+	return r.position
+}
+
+// Close closes the underlying file when the reader was created by Open.
+func (r *Reader) Close() error {
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+	return nil
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("expected %q but found %v", delim, token)
+	}
+	return nil
+}
+
+func readKey(decoder *json.Decoder) (string, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return "", err
+	}
+	key, ok := token.(string)
+	if !ok {
+		return "", fmt.Errorf("expected an object key but found %v", token)
+	}
+	return key, nil
+}
+
+// skipValue reads past the next value without keeping it in memory.
+func skipValue(decoder *json.Decoder) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); ok && (delim == '{' || delim == '[') {
+		return skipNested(decoder, 1)
+	}
+	return nil
+}
+
+// skipNested reads past the rest of depth nested objects or arrays whose
+// opening delimiters were already consumed.
+func skipNested(decoder *json.Decoder, depth int) error {
+	for depth > 0 {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if delim, ok := token.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+	}
+	return nil
+}
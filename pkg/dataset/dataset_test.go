@@ -0,0 +1,355 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataset
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, reader *Reader) []map[string]interface{} {
+	t.Helper()
+	var entries []map[string]interface{}
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			return entries
+		}
+		require.NoError(t, err)
+		entries = append(entries, entry)
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		content     string
+		wantHeader  map[string]interface{}
+		wantEntries int
+		errContains string
+	}{
+		{
+			name:        "header before dataset",
+			content:     `{"version":"v1","schema":{"a":"string"},"metadata":{"DatabaseType":"Test"},"dataset":[{"network":"1.0.0.0/24"},{"network":"2.0.0.0/24"}]}`,
+			wantHeader:  map[string]interface{}{"version": "v1", "schema": map[string]interface{}{"a": "string"}, "metadata": map[string]interface{}{"DatabaseType": "Test"}},
+			wantEntries: 2,
+		},
+		{
+			name:        "header without schema",
+			content:     `{"version":"v1","metadata":{"DatabaseType":"Test"},"dataset":[{"network":"1.0.0.0/24","record":{"tags":["a",{"b":[1]}]}}]}`,
+			wantHeader:  map[string]interface{}{"version": "v1", "metadata": map[string]interface{}{"DatabaseType": "Test"}},
+			wantEntries: 1,
+		},
+		{
+			name:        "header after dataset",
+			content:     `{"dataset":[{"network":"1.0.0.0/24"}],"metadata":{"DatabaseType":"Test"},"version":"v1"}`,
+			wantHeader:  map[string]interface{}{"version": "v1", "metadata": map[string]interface{}{"DatabaseType": "Test"}},
+			wantEntries: 1,
+		},
+		{
+			name:        "sorted keys",
+			content:     `{"dataset":[{"network":"1.0.0.0/24","record":{"a":[1,{"b":"]"}]}},{"network":"2.0.0.0/24"}],"metadata":{"DatabaseType":"Test"},"schema":{"a":"string"},"version":"v1"}`,
+			wantHeader:  map[string]interface{}{"version": "v1", "schema": map[string]interface{}{"a": "string"}, "metadata": map[string]interface{}{"DatabaseType": "Test"}},
+			wantEntries: 2,
+		},
+		{
+			name:        "other field after dataset",
+			content:     `{"version":"v1","dataset":[{"network":"1.0.0.0/24"}],"comment":"dumped"}`,
+			wantHeader:  map[string]interface{}{"version": "v1", "comment": "dumped"},
+			wantEntries: 1,
+		},
+		{
+			name:        "empty dataset",
+			content:     `{"metadata":{"DatabaseType":"Test"},"dataset":[]}`,
+			wantHeader:  map[string]interface{}{"metadata": map[string]interface{}{"DatabaseType": "Test"}},
+			wantEntries: 0,
+		},
+		{
+			name:        "missing dataset",
+			content:     `{"version":"v1"}`,
+			errContains: "no 'dataset' field found in input data",
+		},
+		{
+			name:        "dataset is not an array",
+			content:     `{"dataset":{"network":"1.0.0.0/24"}}`,
+			errContains: "dataset field is not an array",
+		},
+		{
+			name:        "invalid JSON",
+			content:     `{invalid`,
+			errContains: "invalid character",
+		},
+		{
+			name:        "truncated dataset",
+			content:     `{"dataset":[{"network":"1.0.0.0/24"}`,
+			errContains: "unexpected EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			reader, err := NewReader(strings.NewReader(tt.content))
+			if tt.errContains != "" {
+				// The errors past the header are returned by Next
+				for err == nil {
+					_, err = reader.Next()
+				}
+				assert.ErrorContains(t, err, tt.errContains)
+				return
+			}
+			require.NoError(t, err)
+			entries := readAll(t, reader)
+			assert.Equal(t, tt.wantHeader, reader.Header)
+			assert.Len(t, entries, tt.wantEntries)
+		})
+	}
+}
+
+// onlyReader hides the Seek method of the reader it wraps.
+type onlyReader struct {
+	io.Reader
+}
+
+func TestNewReaderNotSeekable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name:    "header before dataset",
+			content: `{"version":"v1","metadata":{"DatabaseType":"Test"},"schema":{},"dataset":[{"network":"1.0.0.0/24"},{"network":"2.0.0.0/24"}]}`,
+		},
+		{
+			name:    "header without schema",
+			content: `{"version":"v1","metadata":{"DatabaseType":"Test"},"dataset":[{"network":"1.0.0.0/24"},{"network":"2.0.0.0/24"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			reader, err := NewReader(onlyReader{strings.NewReader(tt.content)})
+			require.NoError(t, err)
+			assert.Equal(t, "v1", reader.Header["version"])
+			assert.Equal(t, map[string]interface{}{"DatabaseType": "Test"}, reader.Header["metadata"])
+
+			entries := readAll(t, reader)
+			require.Len(t, entries, 2)
+			assert.Equal(t, "2.0.0.0/24", entries[1]["network"])
+		})
+	}
+
+	t.Run("header after dataset", func(t *testing.T) {
+		t.Parallel()
+
+		// The entries were streamed before the header fields were reached
+		reader, err := NewReader(onlyReader{strings.NewReader(`{"dataset":[{"network":"1.0.0.0/24"}],"metadata":{"DatabaseType":"Test"},"version":"v1"}`)})
+		require.NoError(t, err)
+		_, err = reader.Next()
+		require.NoError(t, err)
+		_, err = reader.Next()
+		assert.ErrorContains(t, err, `field "metadata" must come before the dataset`)
+	})
+}
+
+func TestReaderNext(t *testing.T) {
+	t.Parallel()
+
+	t.Run("entries in order", func(t *testing.T) {
+		t.Parallel()
+		reader, err := NewReader(strings.NewReader(`{"dataset":[{"network":"1.0.0.0/24"},{"network":"2.0.0.0/24"}]}`))
+		require.NoError(t, err)
+
+		entry, err := reader.Next()
+		require.NoError(t, err)
+		assert.Equal(t, "1.0.0.0/24", entry["network"])
+		assert.Equal(t, 1, reader.Position())
+
+		entry, err = reader.Next()
+		require.NoError(t, err)
+		assert.Equal(t, "2.0.0.0/24", entry["network"])
+		assert.Equal(t, 2, reader.Position())
+
+		_, err = reader.Next()
+		assert.Equal(t, io.EOF, err)
+		_, err = reader.Next()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("item is not an object", func(t *testing.T) {
+		t.Parallel()
+		reader, err := NewReader(strings.NewReader(`{"dataset":[{"network":"1.0.0.0/24"},"not_an_object"]}`))
+		require.NoError(t, err)
+
+		_, err = reader.Next()
+		require.NoError(t, err)
+		_, err = reader.Next()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "dataset item 2 is not a valid object")
+	})
+
+	t.Run("invalid item", func(t *testing.T) {
+		t.Parallel()
+		reader, err := NewReader(strings.NewReader(`{"version":"v1","schema":{},"metadata":{},"dataset":[{"network":}]}`))
+		require.NoError(t, err)
+
+		_, err = reader.Next()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid dataset item 1")
+	})
+}
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid file", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "data.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"version":"v1","dataset":[{"network":"1.0.0.0/24"}]}`), 0644))
+
+		reader, err := Open(path)
+		require.NoError(t, err)
+		defer reader.Close()
+
+		assert.Equal(t, "v1", reader.Header["version"])
+		assert.Len(t, readAll(t, reader), 1)
+	})
+
+	t.Run("compressed file with sorted keys", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "data.json.gz")
+		var content bytes.Buffer
+		compressor := gzip.NewWriter(&content)
+		_, err := compressor.Write([]byte(`{"dataset":[{"network":"1.0.0.0/24"}],"metadata":{"DatabaseType":"Test"},"version":"v1"}`))
+		require.NoError(t, err)
+		require.NoError(t, compressor.Close())
+		require.NoError(t, os.WriteFile(path, content.Bytes(), 0644))
+
+		// The file is opened again to read the dataset after the header
+		reader, err := Open(path)
+		require.NoError(t, err)
+		defer reader.Close()
+
+		assert.Equal(t, "v1", reader.Header["version"])
+		assert.Len(t, readAll(t, reader), 1)
+	})
+
+	t.Run("non-existent file", func(t *testing.T) {
+		t.Parallel()
+		_, err := Open("/nonexistent/file.json")
+		assert.Error(t, err)
+	})
+}
+
+func TestNewLinesReader(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		content     string
+		wantHeader  map[string]interface{}
+		wantEntries int
+		errContains string
+	}{
+		{
+			name:        "with header",
+			content:     "{\"version\":\"v1\",\"metadata\":{\"DatabaseType\":\"Test\"}}\n{\"network\":\"1.0.0.0/24\",\"record\":{}}\n{\"network\":\"2.0.0.0/24\",\"record\":{}}\n",
+			wantHeader:  map[string]interface{}{"version": "v1", "metadata": map[string]interface{}{"DatabaseType": "Test"}},
+			wantEntries: 2,
+		},
+		{
+			name:        "without header",
+			content:     "{\"network\":\"1.0.0.0/24\",\"record\":{}}\n\n{\"network\":\"2.0.0.0/24\",\"record\":{}}",
+			wantHeader:  map[string]interface{}{},
+			wantEntries: 2,
+		},
+		{
+			name:        "header only",
+			content:     "{\"version\":\"v1\"}\n",
+			wantHeader:  map[string]interface{}{"version": "v1"},
+			wantEntries: 0,
+		},
+		{
+			name:        "empty file",
+			content:     "",
+			wantHeader:  map[string]interface{}{},
+			wantEntries: 0,
+		},
+		{
+			name:        "first line is not an object",
+			content:     "[1, 2]\n",
+			errContains: "dataset item 1 is not a valid object",
+		},
+		{
+			name:        "invalid first line",
+			content:     "{invalid\n",
+			errContains: "invalid first line",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			reader, err := NewLinesReader(strings.NewReader(tt.content))
+			if tt.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errContains)
+				return
+			}
+			require.NoError(t, err)
+			entries := readAll(t, reader)
+			assert.Equal(t, tt.wantHeader, reader.Header)
+			assert.Len(t, entries, tt.wantEntries)
+		})
+	}
+
+	t.Run("invalid line", func(t *testing.T) {
+		t.Parallel()
+		reader, err := NewLinesReader(strings.NewReader("{\"network\":\"1.0.0.0/24\"}\n\"not_an_object\"\n"))
+		require.NoError(t, err)
+
+		_, err = reader.Next()
+		require.NoError(t, err)
+		_, err = reader.Next()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "dataset item 2 is not a valid object")
+	})
+}
+
+func TestIsLines(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, IsLines("dataset.ndjson"))
+	assert.True(t, IsLines("dataset.jsonl"))
+	assert.False(t, IsLines("dataset.json"))
+}
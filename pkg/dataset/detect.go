@@ -0,0 +1,135 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Formats of the datasets detected from their content, named after the
+// extension of their files.
+const (
+	FormatJSON  = ".json"
+	FormatLines = ".ndjson"
+	FormatCSV   = ".csv"
+)
+
+// detectWindow is the most DetectFormat reads ahead to find the first line of
+// a dataset.
+const detectWindow = 1 << 20
+
+// DetectFormat returns the format of the dataset read from source without
+// consuming it. JSON datasets whose first line holds a whole object without a
+// "dataset" field use JSON Lines, other JSON datasets are v1 documents, and
+// anything else is CSV when csvAllowed is set.
+func DetectFormat(source *bufio.Reader, csvAllowed bool) (string, error) {
+	for size := 512; ; size *= 2 {
+		if size > source.Size() {
+			size = source.Size()
+		}
+
+		content, err := source.Peek(size)
+		content = bytes.TrimLeft(content, " \t\r\n")
+
+		if len(content) > 0 && content[0] != '{' {
+			if csvAllowed {
+				return FormatCSV, nil
+			}
+			return "", fmt.Errorf("unrecognized dataset format, expected a JSON document or JSON Lines")
+		}
+
+		if end := bytes.IndexByte(content, '\n'); end >= 0 {
+			return jsonFormat(content[:end]), nil
+		}
+
+		switch {
+		case err == io.EOF && len(content) == 0:
+			return "", fmt.Errorf("empty dataset")
+		case err == io.EOF:
+			return jsonFormat(content), nil
+		case err != nil && err != bufio.ErrBufferFull:
+			return "", err
+		case size == source.Size():
+			// A first line this long is a v1 document written on one line
+			return FormatJSON, nil
+		}
+	}
+}
+
+// jsonFormat returns the format of a JSON dataset from its first line.
+func jsonFormat(line []byte) string {
+	var first map[string]json.RawMessage
+	if err := json.Unmarshal(line, &first); err != nil {
+		return FormatJSON
+	}
+	if _, isDocument := first["dataset"]; isDocument {
+		return FormatJSON
+	}
+	return FormatLines
+}
+
+// NewDetectedReader reads the dataset from source in the format detected from
+// its content, see DetectFormat. CSV datasets are only detected when mapping
+// is set.
+func NewDetectedReader(source io.Reader, mapping *CSVMapping) (*Reader, error) {
+	buffered := bufio.NewReaderSize(source, detectWindow)
+
+	format, err := DetectFormat(buffered, mapping != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatCSV:
+		return NewCSVReader(buffered, mapping)
+	case FormatLines:
+		return NewLinesReader(buffered)
+	default:
+		return NewReader(buffered)
+	}
+}
+
+// Spool copies the dataset read from source to a temporary file whose
+// extension is the format detected from its content, so that a dataset read
+// from the standard input can be read more than once. The caller removes the
+// file.
+func Spool(source io.Reader, csvAllowed bool) (string, error) {
+	buffered := bufio.NewReaderSize(source, detectWindow)
+
+	format, err := DetectFormat(buffered, csvAllowed)
+	if err != nil {
+		return "", err
+	}
+
+	spoolFile, err := os.CreateTemp("", "mmdb-cli-dataset-*"+format)
+	if err != nil {
+		return "", err
+	}
+	defer spoolFile.Close()
+
+	if _, err := io.Copy(spoolFile, buffered); err != nil {
+		os.Remove(spoolFile.Name())
+		return "", fmt.Errorf("failed to read dataset: %w", err)
+	}
+
+	return spoolFile.Name(), nil
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataset
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		content     string
+		csvAllowed  bool
+		want        string
+		errContains string
+	}{
+		{
+			name:    "pretty printed document",
+			content: "{\n  \"version\": \"v1\",\n  \"dataset\": []\n}\n",
+			want:    FormatJSON,
+		},
+		{
+			name:    "single line document",
+			content: `{"version":"v1","metadata":{},"dataset":[{"network":"1.0.0.0/24","record":{}}]}`,
+			want:    FormatJSON,
+		},
+		{
+			name:    "JSON Lines with header",
+			content: "{\"version\":\"v1\",\"metadata\":{}}\n{\"network\":\"1.0.0.0/24\",\"record\":{}}\n",
+			want:    FormatLines,
+		},
+		{
+			name:    "JSON Lines without trailing newline",
+			content: `{"network":"1.0.0.0/24","record":{}}`,
+			want:    FormatLines,
+		},
+		{
+			name:    "leading whitespace",
+			content: "\n\n  {\"network\":\"1.0.0.0/24\",\"record\":{}}\n",
+			want:    FormatLines,
+		},
+		{
+			name:       "CSV",
+			content:    "network,country\n1.0.0.0/24,AU\n",
+			csvAllowed: true,
+			want:       FormatCSV,
+		},
+		{
+			name:        "CSV without mapping",
+			content:     "network,country\n1.0.0.0/24,AU\n",
+			errContains: "unrecognized dataset format",
+		},
+		{
+			name:        "empty",
+			content:     "  \n",
+			errContains: "empty dataset",
+		},
+		{
+			name:    "long first line",
+			content: `{"version":"v1","metadata":{"Description":{"en":"` + strings.Repeat("a", 4096) + `"}},"dataset":[]}`,
+			want:    FormatJSON,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			format, err := DetectFormat(bufio.NewReaderSize(strings.NewReader(tt.content), 1024), tt.csvAllowed)
+			if tt.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errContains)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, format)
+		})
+	}
+}
+
+func TestNewDetectedReader(t *testing.T) {
+	t.Parallel()
+
+	reader, err := NewDetectedReader(strings.NewReader("{\"version\":\"v1\"}\n{\"network\":\"1.0.0.0/24\",\"record\":{}}\n"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", reader.Header["version"])
+	assert.Len(t, readAll(t, reader), 1)
+
+	reader, err = NewDetectedReader(strings.NewReader(`{"version":"v1","dataset":[{"network":"1.0.0.0/24"}]}`), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", reader.Header["version"])
+	assert.Len(t, readAll(t, reader), 1)
+}
+
+func TestSpool(t *testing.T) {
+	t.Parallel()
+
+	content := "{\"network\":\"1.0.0.0/24\",\"record\":{}}\n"
+	path, err := Spool(strings.NewReader(content), false)
+	require.NoError(t, err)
+	defer os.Remove(path)
+
+	assert.True(t, IsLines(path))
+	spooled, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(spooled))
+}
@@ -17,12 +17,20 @@ limitations under the License.
 package dump
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"os"
 
 	"github.com/InfraZ/mmdb-cli/internal/files"
+	"github.com/InfraZ/mmdb-cli/pkg/dataset"
 	"github.com/InfraZ/mmdb-cli/pkg/jsonpath"
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
+	"github.com/InfraZ/mmdb-cli/pkg/progress"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
 	"github.com/oschwald/maxminddb-golang"
 )
 
@@ -31,13 +39,124 @@ type CmdDumpConfig struct {
 	OutputFile    string
 	Verbose       bool
 	JSONPath      string
+
+	// NoSchema skips the schema pass and writes the dataset without a schema
+	// block, in which case generate uses its default type conversion
+	NoSchema bool
+}
+
+// WalkRecords calls fn for every network of the database, skipping aliased
+// networks, with its record decoded into MMDB types.
+func WalkRecords(db *maxminddb.Reader, fn func(subnet *net.IPNet, record mmdbtype.Map) error) error {
+	availableNetworks := db.Networks(
+		maxminddb.SkipAliasedNetworks,
+	)
+
+	for availableNetworks.Next() {
+		var typedRecord mmdb.TypedRecord
+
+		subnet, err := availableNetworks.Network(&typedRecord)
+		if err != nil {
+			return fmt.Errorf("failed to get record for next subnet: %w", err)
+		}
+
+		record, ok := typedRecord.Value().(mmdbtype.Map)
+		if !ok {
+			return fmt.Errorf("record for network %s is not a map", subnet.String())
+		}
+
+		if err := fn(subnet, record); err != nil {
+			return err
+		}
+	}
+	if err := availableNetworks.Err(); err != nil {
+		return fmt.Errorf("failed to read networks: %w", err)
+	}
+
+	return nil
+}
+
+// Options configures the dump of a database.
+type Options struct {
+	// JSONPath keeps only the records matching the expression
+	JSONPath string
+
+	// NoSchema skips the schema pass and writes the dataset without a schema
+	// block
+	NoSchema bool
+
+	// Lines writes a JSON Lines dataset instead of a v1 JSON document
+	Lines bool
+
+	// Progress receives the progress of the dump, it may be nil
+	Progress progress.Reporter
+}
+
+// Result describes a dumped dataset.
+type Result struct {
+	// Read is the number of records read from the database, and Dumped the
+	// number of records written to the dataset
+	Read   int
+	Dumped int
+
+	// Warnings lists the problems that did not stop the dump
+	Warnings []string
+
+	// Size is the number of bytes written
+	Size int64
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// datasetSchema walks every record of the database and returns the schema
+// describing the MMDB types of their values, so that generate can write the
+// dumped records back with the same types. The keys whose type differs across
+// records get the widest of their types, and the keys whose types cannot be
+// widened are left out of the schema and returned as warnings.
+func datasetSchema(ctx context.Context, db *maxminddb.Reader, reporter progress.Reporter) (map[string]interface{}, []string, error) {
+	reporter.Step("Reading record types to build the dataset schema")
+
+	builder := mmdb.NewSchemaBuilder()
+	err := WalkRecords(db, func(subnet *net.IPNet, record mmdbtype.Map) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		builder.Add(record)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var warnings []string
+	for _, conflict := range builder.Conflicts() {
+		warning := fmt.Sprintf("Key %s has incompatible types across records and is left out of the schema", conflict)
+		warnings = append(warnings, warning)
+		reporter.Warning(warning)
+	}
+
+	return builder.Schema(), warnings, nil
 }
 
 /*
-Structure of the dumped JSON dataset:
+Dump writes the records of db to dst as a dataset that generate can read
+back:
 
 	{
 		"version": "v1",
+		"schema": {
+			<SCHEMA>
+		},
 		"metadata": {
 			<METADATA>
 		},
@@ -51,84 +170,70 @@ Structure of the dumped JSON dataset:
 		]
 	}
 */
-func DumpMMMDB(cfg *CmdDumpConfig) error {
-
-	filesToCheck := []files.FilesListValidation{
-		{FilePath: cfg.InputDatabase, ExpectedExtension: ".mmdb", ShouldExist: true},
-		{FilePath: cfg.OutputFile, ExpectedExtension: ".json", ShouldExist: false},
-	}
-
-	if err := files.FilesValidation(filesToCheck); err != nil {
-		return err
-	}
-
-	db, err := maxminddb.Open(cfg.InputDatabase)
-	if err != nil {
-		return fmt.Errorf("failed to open database: %s - %w", cfg.InputDatabase, err)
-	}
-	defer db.Close()
-
-	if len(cfg.OutputFile) < 5 || cfg.OutputFile[len(cfg.OutputFile)-5:] != ".json" {
-		return fmt.Errorf("output file must have a .json extension")
-	}
-
-	outputFile, err := os.Create(cfg.OutputFile)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %s - %w", cfg.OutputFile, err)
+func Dump(ctx context.Context, db *maxminddb.Reader, dst io.Writer, opts Options) (*Result, error) {
+	reporter := progress.OrNop(opts.Progress)
+	result := &Result{}
+	output := &countingWriter{w: dst}
+
+	if opts.JSONPath != "" {
+		if err := jsonpath.ValidateExpression(opts.JSONPath); err != nil {
+			return nil, fmt.Errorf("invalid JSONPath expression: %w", err)
+		}
 	}
-	defer outputFile.Close()
-
-	fmt.Printf("[+] Start dumping %s to %s\n", cfg.InputDatabase, cfg.OutputFile)
 
-	if cfg.JSONPath != "" {
-		if err := jsonpath.ValidateExpression(cfg.JSONPath); err != nil {
-			return fmt.Errorf("%w", err)
+	header := `{"version":"v1",`
+	if !opts.NoSchema {
+		schema, warnings, err := datasetSchema(ctx, db, reporter)
+		if err != nil {
+			return nil, err
+		}
+		result.Warnings = warnings
+		schemaJSON, err := json.Marshal(schema)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal schema: %w", err)
 		}
+		header += fmt.Sprintf(`"schema":%s,`, schemaJSON)
 	}
 
 	metadataJSON, err := json.Marshal(db.Metadata)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	// JSON Lines datasets carry the header on its own line, followed by one
+	// entry per line
+	headerFormat := `%s"metadata":%s,"dataset":[`
+	if opts.Lines {
+		headerFormat = "%s\"metadata\":%s}\n"
 	}
-	if _, err := fmt.Fprintf(outputFile, `{"version":"v1","metadata":%s,"dataset":[`, metadataJSON); err != nil {
-		return fmt.Errorf("failed to write output header: %w", err)
+	if _, err := fmt.Fprintf(output, headerFormat, header, metadataJSON); err != nil {
+		return nil, fmt.Errorf("failed to write output header: %w", err)
 	}
 
-	encoder := json.NewEncoder(outputFile)
-	var readPosition int
-	var dumpPosition int
+	encoder := json.NewEncoder(output)
 	firstRecord := true
 
-	availableNetworks := db.Networks(
-		maxminddb.SkipAliasedNetworks,
-	)
-
-	for availableNetworks.Next() {
-		readPosition++
-		record := make(map[string]interface{})
-
-		subnet, err := availableNetworks.Network(&record)
-		if err != nil {
-			return fmt.Errorf("failed to get record for next subnet: %w", err)
+	err = WalkRecords(db, func(subnet *net.IPNet, typedRecord mmdbtype.Map) error {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
-		if cfg.JSONPath != "" {
-			match, err := jsonpath.MatchesRecord(cfg.JSONPath, record)
+		result.Read++
+		record := mmdb.ToJSONValue(typedRecord).(map[string]interface{})
+
+		if opts.JSONPath != "" {
+			match, err := jsonpath.MatchesRecord(opts.JSONPath, record)
 			if err != nil {
 				return fmt.Errorf("failed to evaluate JSONPath for network %s: %w", subnet.String(), err)
 			}
 			if !match {
-				if !cfg.Verbose {
-					fmt.Printf("\r[-] Read records: %d, Matched records: %d", readPosition, dumpPosition)
-				}
-				continue
+				return nil
 			}
 		}
 
-		dumpPosition++
+		result.Dumped++
 
-		if !firstRecord {
-			if _, err := outputFile.WriteString(","); err != nil {
+		if !firstRecord && !opts.Lines {
+			if _, err := io.WriteString(output, ","); err != nil {
 				return fmt.Errorf("failed to write record separator: %w", err)
 			}
 		}
@@ -142,34 +247,91 @@ func DumpMMMDB(cfg *CmdDumpConfig) error {
 			return fmt.Errorf("failed to encode record for network %s: %w", subnet.String(), err)
 		}
 
-		if cfg.Verbose {
-			fmt.Printf("[-] Dumping record %d for network %s - data: %v\n", dumpPosition, subnet.String(), record)
-		} else if cfg.JSONPath != "" {
-			fmt.Printf("\r[-] Read records: %d, Matched records: %d", readPosition, dumpPosition)
-		} else {
-			fmt.Printf("\r[-] Dumped records: %d", dumpPosition)
+		reporter.Record(result.Dumped, subnet.String(), record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.Lines {
+		if _, err := io.WriteString(output, "]}"); err != nil {
+			return nil, fmt.Errorf("failed to write output footer: %w", err)
 		}
 	}
 
-	if _, err := outputFile.WriteString("]}"); err != nil {
-		return fmt.Errorf("failed to write output footer: %w", err)
+	result.Size = output.n
+
+	return result, nil
+}
+
+// dumpTo is a dump function usable as the io.WriterTo of files.WriteOutput.
+type dumpTo func(w io.Writer) (int64, error)
+
+func (d dumpTo) WriteTo(w io.Writer) (int64, error) {
+	return d(w)
+}
+
+// DumpMMMDB dumps the database file of the configuration to its output
+// dataset file, either of which can be files.Stdio or compressed.
+func DumpMMMDB(cfg *CmdDumpConfig) error {
+
+	filesToCheck := []files.FilesListValidation{
+		{FilePath: cfg.InputDatabase, ExpectedExtensions: mmdb.DatabaseExtensions, ShouldExist: true, AllowStdio: true},
+		{FilePath: cfg.OutputFile, ExpectedExtensions: dataset.Extensions, ShouldExist: false, AllowStdio: true},
 	}
 
-	if cfg.JSONPath != "" {
-		fmt.Printf("\r[+] Read %d records, matched %d records\n", readPosition, dumpPosition)
-	} else {
-		fmt.Printf("\r[+] Total %d records dumped successfully\n", dumpPosition)
+	if err := files.FilesValidation(filesToCheck); err != nil {
+		return err
 	}
 
-	outputFileStat, err := outputFile.Stat()
+	db, err := mmdb.OpenDatabase(cfg.InputDatabase)
 	if err != nil {
-		return fmt.Errorf("failed to get output file stats: %s - %w", cfg.OutputFile, err)
+		return fmt.Errorf("failed to open database: %s - %w", cfg.InputDatabase, err)
+	}
+	defer db.Close()
+
+	slog.Info("Start dumping", "input", cfg.InputDatabase, "output", cfg.OutputFile)
+
+	logger := &progress.Logger{
+		Verbose:       cfg.Verbose,
+		Bar:           progress.TerminalBar(os.Stderr),
+		CountFormat:   "Dumped records: %d",
+		RecordMessage: "Dumping record",
+	}
+
+	var result *Result
+	size, err := files.WriteOutput(cfg.OutputFile, dumpTo(func(w io.Writer) (int64, error) {
+		output, err := files.Compress(w, files.Compression(cfg.OutputFile))
+		if err != nil {
+			return 0, err
+		}
+
+		result, err = Dump(context.Background(), db, output, Options{
+			JSONPath: cfg.JSONPath,
+			NoSchema: cfg.NoSchema,
+			Lines:    dataset.IsLines(cfg.OutputFile),
+			Progress: logger,
+		})
+		if err != nil {
+			return 0, err
+		}
+		return result.Size, output.Close()
+	}), nil)
+	logger.EndLine()
+	if err != nil {
+		return err
+	}
+
+	if cfg.JSONPath != "" {
+		slog.Info("Records dumped", "read", result.Read, "matched", result.Dumped)
+	} else {
+		slog.Info("Records dumped", "records", result.Dumped)
 	}
 
-	outputFileSizeMB := float64(outputFileStat.Size()) / 1024 / 1024
-	fmt.Printf("[+] %s file created with size: %.2f MB\n", cfg.OutputFile, outputFileSizeMB)
+	slog.Info("Dataset file created", "path", cfg.OutputFile, "size_mb", fmt.Sprintf("%.2f", float64(size)/1024/1024))
 
-	fmt.Println("[+] MMDB Dumped successfully")
+	slog.Info("MMDB Dumped successfully")
 
 	return nil
 }
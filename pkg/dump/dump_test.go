@@ -17,11 +17,21 @@ limitations under the License.
 package dump
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"math/big"
+	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/InfraZ/mmdb-cli/pkg/generate"
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -57,6 +67,16 @@ func TestDumpMMMDB(t *testing.T) {
 				assert.Equal(t, "v1", result["version"])
 				assert.NotNil(t, result["metadata"])
 				assert.NotNil(t, result["dataset"])
+				assert.Equal(t, map[string]interface{}{
+					"registered_country": map[string]interface{}{
+						"geoname_id": "float64",
+						"iso_code":   "string",
+						"names": map[string]interface{}{
+							"de": "string", "en": "string", "es": "string", "fr": "string",
+							"ja": "string", "pt-BR": "string", "ru": "string", "zh-CN": "string",
+						},
+					},
+				}, result["schema"])
 
 				dataset, ok := result["dataset"].([]interface{})
 				require.True(t, ok)
@@ -179,6 +199,32 @@ func TestDumpMMMDB(t *testing.T) {
 		},
 	}
 
+	tests = append(tests, struct {
+		name    string
+		cfg     func(t *testing.T) *CmdDumpConfig
+		wantErr bool
+		verify  func(t *testing.T, cfg *CmdDumpConfig)
+	}{
+		name: "dump without schema",
+		cfg: func(t *testing.T) *CmdDumpConfig {
+			t.Helper()
+			return &CmdDumpConfig{
+				InputDatabase: testMMDB,
+				OutputFile:    filepath.Join(t.TempDir(), "output.json"),
+				NoSchema:      true,
+			}
+		},
+		verify: func(t *testing.T, cfg *CmdDumpConfig) {
+			t.Helper()
+			data, err := os.ReadFile(cfg.OutputFile)
+			require.NoError(t, err)
+
+			var result map[string]interface{}
+			require.NoError(t, json.Unmarshal(data, &result))
+			assert.NotContains(t, result, "schema")
+		},
+	})
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			cfg := tt.cfg(t)
@@ -196,3 +242,278 @@ func TestDumpMMMDB(t *testing.T) {
 		})
 	}
 }
+
+func TestDumpGenerateRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	sourcePath := filepath.Join(dir, "source.mmdb")
+
+	writer, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType: "RoundTrip-Test",
+		Description:  map[string]string{"en": "Round trip test"},
+		RecordSize:   24,
+	})
+	require.NoError(t, err)
+
+	records := map[string]mmdbtype.Map{
+		"1.0.0.0/24": {
+			"geoname_id": mmdbtype.Uint32(2077456),
+			"port":       mmdbtype.Uint16(443),
+			"offset":     mmdbtype.Int32(-5),
+			"big":        mmdbtype.Uint64(18446744073709551615),
+			"huge":       (*mmdbtype.Uint128)(new(big.Int).Lsh(big.NewInt(1), 100)),
+			"ratio":      mmdbtype.Float32(0.1),
+			"latitude":   mmdbtype.Float64(-33.494),
+			"hash":       mmdbtype.Bytes("hello"),
+			"anycast":    mmdbtype.Bool(true),
+			"subdivisions": mmdbtype.Slice{
+				mmdbtype.Map{"geoname_id": mmdbtype.Uint32(2155400), "iso_code": mmdbtype.String("NSW")},
+			},
+		},
+		"2.0.0.0/24": {
+			"geoname_id": mmdbtype.Uint32(6252001),
+			"tags":       mmdbtype.Slice{mmdbtype.String("cdn"), mmdbtype.String("anycast")},
+		},
+	}
+	for network, record := range records {
+		_, ipNet, err := net.ParseCIDR(network)
+		require.NoError(t, err)
+		require.NoError(t, writer.Insert(ipNet, record))
+	}
+
+	sourceFile, err := os.Create(sourcePath)
+	require.NoError(t, err)
+	_, err = writer.WriteTo(sourceFile)
+	require.NoError(t, err)
+	require.NoError(t, sourceFile.Close())
+
+	// The JSON document and JSON Lines formats must both round trip
+	for _, extension := range []string{".json", ".ndjson"} {
+		t.Run(extension, func(t *testing.T) {
+			dumpPath := filepath.Join(dir, "dump"+extension)
+			require.NoError(t, DumpMMMDB(&CmdDumpConfig{InputDatabase: sourcePath, OutputFile: dumpPath}))
+
+			regeneratedPath := filepath.Join(dir, "regenerated"+extension+".mmdb")
+			require.NoError(t, generate.GenerateMMDB(&generate.CmdGenerateConfig{
+				InputDataset:   dumpPath,
+				OutputDatabase: regeneratedPath,
+			}))
+
+			db, err := maxminddb.Open(regeneratedPath)
+			require.NoError(t, err)
+			defer db.Close()
+
+			for network, want := range records {
+				_, ipNet, err := net.ParseCIDR(network)
+				require.NoError(t, err)
+
+				var got mmdb.TypedRecord
+				require.NoError(t, db.Lookup(ipNet.IP, &got))
+				assert.True(t, want.Equal(got.Value()), "network %s: got %v, want %v", network, got.Value(), want)
+			}
+		})
+	}
+}
+
+func TestDumpGenerateRoundTripMixedTypes(t *testing.T) {
+	dir := t.TempDir()
+	sourcePath := filepath.Join(dir, "source.mmdb")
+
+	writer, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType: "RoundTrip-Test",
+		Description:  map[string]string{"en": "Round trip test"},
+		RecordSize:   24,
+	})
+	require.NoError(t, err)
+
+	// metro_code holds a uint16 in one record and a uint32 in the other, and
+	// the elements of scores have different integer types
+	records := map[string]mmdbtype.Map{
+		"1.0.0.0/24": {
+			"metro_code": mmdbtype.Uint16(501),
+			"scores":     mmdbtype.Slice{mmdbtype.Uint16(1), mmdbtype.Uint64(1 << 40)},
+		},
+		"2.0.0.0/24": {
+			"metro_code": mmdbtype.Uint32(4000000000),
+			"scores":     mmdbtype.Slice{mmdbtype.Uint32(70000)},
+		},
+	}
+	for network, record := range records {
+		_, ipNet, err := net.ParseCIDR(network)
+		require.NoError(t, err)
+		require.NoError(t, writer.Insert(ipNet, record))
+	}
+
+	sourceFile, err := os.Create(sourcePath)
+	require.NoError(t, err)
+	_, err = writer.WriteTo(sourceFile)
+	require.NoError(t, err)
+	require.NoError(t, sourceFile.Close())
+
+	dumpPath := filepath.Join(dir, "dump.json")
+	require.NoError(t, DumpMMMDB(&CmdDumpConfig{InputDatabase: sourcePath, OutputFile: dumpPath}))
+
+	regeneratedPath := filepath.Join(dir, "regenerated.mmdb")
+	require.NoError(t, generate.GenerateMMDB(&generate.CmdGenerateConfig{
+		InputDataset:   dumpPath,
+		OutputDatabase: regeneratedPath,
+	}))
+
+	redumpPath := filepath.Join(dir, "redump.json")
+	require.NoError(t, DumpMMMDB(&CmdDumpConfig{InputDatabase: regeneratedPath, OutputFile: redumpPath}))
+
+	dumped, err := os.ReadFile(dumpPath)
+	require.NoError(t, err)
+	redumped, err := os.ReadFile(redumpPath)
+	require.NoError(t, err)
+	assert.Equal(t, string(dumped), string(redumped))
+
+	var document struct {
+		Schema map[string]interface{} `json:"schema"`
+	}
+	require.NoError(t, json.Unmarshal(dumped, &document))
+	assert.Equal(t, "uint32", document.Schema["metro_code"])
+	assert.Equal(t, []interface{}{"uint64"}, document.Schema["scores"])
+
+	db, err := maxminddb.Open(regeneratedPath)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var got mmdb.TypedRecord
+	require.NoError(t, db.Lookup(net.ParseIP("1.0.0.1"), &got))
+	assert.True(t, mmdbtype.Map{
+		"metro_code": mmdbtype.Uint32(501),
+		"scores":     mmdbtype.Slice{mmdbtype.Uint64(1), mmdbtype.Uint64(1 << 40)},
+	}.Equal(got.Value()), "got %v", got.Value())
+}
+
+func TestDumpJSONLines(t *testing.T) {
+	dir := t.TempDir()
+	outputPath := filepath.Join(dir, "dump.jsonl")
+
+	require.NoError(t, DumpMMMDB(&CmdDumpConfig{InputDatabase: testMMDB, OutputFile: outputPath}))
+
+	content, err := os.ReadFile(outputPath)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 3)
+
+	var header map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &header))
+	assert.Equal(t, "v1", header["version"])
+	assert.Contains(t, header, "schema")
+	assert.Contains(t, header, "metadata")
+	assert.NotContains(t, header, "dataset")
+
+	for _, line := range lines[1:] {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		assert.Contains(t, entry, "network")
+		assert.Contains(t, entry, "record")
+	}
+}
+
+func TestDump(t *testing.T) {
+	t.Parallel()
+
+	db, err := maxminddb.Open(testMMDB)
+	require.NoError(t, err)
+	// The parallel subtests run after this function returns
+	t.Cleanup(func() { db.Close() })
+
+	t.Run("writes a v1 dataset", func(t *testing.T) {
+		t.Parallel()
+
+		var output bytes.Buffer
+		result, err := Dump(context.Background(), db, &output, Options{})
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, result.Read)
+		assert.Equal(t, 2, result.Dumped)
+		assert.Equal(t, int64(output.Len()), result.Size)
+
+		var dataset map[string]interface{}
+		require.NoError(t, json.Unmarshal(output.Bytes(), &dataset))
+		assert.Contains(t, dataset, "schema")
+		assert.Len(t, dataset["dataset"], 2)
+	})
+
+	t.Run("filters with JSONPath", func(t *testing.T) {
+		t.Parallel()
+
+		var output bytes.Buffer
+		result, err := Dump(context.Background(), db, &output, Options{
+			JSONPath: `{[?(@.nonexistent=="value")]}`,
+			NoSchema: true,
+			Lines:    true,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, result.Read)
+		assert.Equal(t, 0, result.Dumped)
+		assert.Equal(t, 1, strings.Count(output.String(), "\n"))
+	})
+
+	t.Run("rejects an invalid JSONPath", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := Dump(context.Background(), db, &bytes.Buffer{}, Options{JSONPath: "{[?(@.field==}"})
+		assert.ErrorContains(t, err, "invalid JSONPath expression: ")
+	})
+
+	t.Run("stops when the context is canceled", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := Dump(ctx, db, &bytes.Buffer{}, Options{})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestDumpMMMDBStdout(t *testing.T) {
+	oldStdout := os.Stdout
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	os.Stdout = w
+
+	dumpErr := DumpMMMDB(&CmdDumpConfig{InputDatabase: testMMDB, OutputFile: "-"})
+
+	w.Close()
+	os.Stdout = oldStdout
+	require.NoError(t, dumpErr)
+
+	var output bytes.Buffer
+	_, err = output.ReadFrom(r)
+	require.NoError(t, err)
+
+	var dumped map[string]interface{}
+	require.NoError(t, json.Unmarshal(output.Bytes(), &dumped))
+	assert.Equal(t, "v1", dumped["version"])
+	assert.Len(t, dumped["dataset"], 2)
+}
+
+func TestDumpGenerateCompressed(t *testing.T) {
+	for _, compression := range []string{".gz", ".zst"} {
+		t.Run(compression, func(t *testing.T) {
+			dir := t.TempDir()
+			dumpPath := filepath.Join(dir, "dump.json"+compression)
+
+			require.NoError(t, DumpMMMDB(&CmdDumpConfig{InputDatabase: testMMDB, OutputFile: dumpPath}))
+
+			// The dump is not readable as plain JSON
+			content, err := os.ReadFile(dumpPath)
+			require.NoError(t, err)
+			assert.False(t, json.Valid(content))
+
+			generatedPath := filepath.Join(dir, "generated.mmdb")
+			require.NoError(t, generate.GenerateMMDB(&generate.CmdGenerateConfig{InputDataset: dumpPath, OutputDatabase: generatedPath}))
+
+			db, err := maxminddb.Open(generatedPath)
+			require.NoError(t, err)
+			defer db.Close()
+			assert.Equal(t, "Inspect Test", db.Metadata.DatabaseType)
+		})
+	}
+}
@@ -0,0 +1,437 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/InfraZ/mmdb-cli/pkg/dataset"
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
+	"github.com/InfraZ/mmdb-cli/pkg/progress"
+	"github.com/InfraZ/mmdb-cli/pkg/validate"
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// Options configures the generation of a database.
+type Options struct {
+	DisableIPv4Aliasing     bool
+	IncludeReservedNetworks bool
+
+	// Strict makes schema violations fail the generation instead of being
+	// reported as warnings and written as zero values
+	Strict bool
+
+	// OnConflict is the policy for records whose network overlaps a network
+	// inserted earlier, one of the OnConflict constants (default replace)
+	OnConflict string
+
+	// BuildEpoch is the build timestamp written to the database, taking
+	// precedence over the BuildEpoch of the metadata and SOURCE_DATE_EPOCH
+	BuildEpoch int64
+
+	// LayerMethod merges the records of the dataset layers after the first
+	// one when their header has no "method", one of the Method constants
+	// (default deep_merge)
+	LayerMethod string
+
+	// Progress receives the progress of the generation, it may be nil
+	Progress progress.Reporter
+}
+
+// Result describes a generated database.
+type Result struct {
+	// Records is the number of dataset records inserted
+	Records int
+
+	// Networks is the number of networks inserted, ranges being expanded
+	// into several networks
+	Networks int
+
+	// OverlappingRecords is the number of records of the first dataset
+	// overlapping a network inserted earlier, and Overlaps lists every
+	// overlapping pair once ListOverlaps read the dataset again
+	OverlappingRecords int
+	Overlaps           []Overlap
+	overlapping        overlapTracker
+
+	// Warnings lists the problems that did not stop the generation
+	Warnings []string
+
+	// Size is the number of bytes written by Generate
+	Size int64
+}
+
+// ListOverlaps sets Overlaps to the overlapping pairs of records, reader
+// reading the first dataset of the generation again. Only the networks of the
+// overlapping records are kept during the generation, and the records they
+// overlap are found by this second reading.
+func (r *Result) ListOverlaps(reader *dataset.Reader) error {
+	overlaps, err := r.overlapping.pairs(reader)
+	if err != nil {
+		return err
+	}
+	r.Overlaps = overlaps
+	return nil
+}
+
+func (r *Result) warn(reporter progress.Reporter, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	r.Warnings = append(r.Warnings, message)
+	reporter.Warning(message)
+}
+
+// validate checks the options that do not depend on the dataset.
+func (o Options) validate() error {
+	if _, err := conflictInserter(o.OnConflict, nil, new(bool)); err != nil {
+		return err
+	}
+	if _, err := layerInserter(o.LayerMethod, nil); err != nil {
+		return err
+	}
+	if o.BuildEpoch < 0 {
+		return fmt.Errorf("invalid build epoch %d, it must be a positive Unix timestamp", o.BuildEpoch)
+	}
+	return nil
+}
+
+func mmdbWriterOptions(opts Options, metadata map[string]interface{}) (*mmdbwriter.Options, []string, error) {
+
+	if problems := validate.Metadata(metadata); len(problems) > 0 {
+		return nil, nil, problems
+	}
+
+	epoch, err := buildEpoch(opts, metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var warnings []string
+
+	databaseType, _ := metadata["DatabaseType"].(string)
+
+	description := make(map[string]string)
+	for descriptionKey, descriptionValue := range metadata["Description"].(map[string]interface{}) {
+		description[descriptionKey], _ = descriptionValue.(string)
+	}
+
+	var ipVersion int
+	if metadata["IPVersion"] == nil {
+		warnings = append(warnings, "IPVersion is not provided in metadata, defaulting to 6 (An IPv6 database supports both IPv4 and IPv6 lookups)")
+	} else {
+		ipVersion = int(metadata["IPVersion"].(float64))
+	}
+
+	languages := make([]string, 0)
+	if metadata["Languages"] == nil {
+		languages = append(languages, "en")
+		warnings = append(warnings, "Languages is not provided in metadata, defaulting to English")
+	} else {
+		for _, language := range metadata["Languages"].([]interface{}) {
+			languages = append(languages, language.(string))
+		}
+	}
+
+	var recordSize int
+	if metadata["RecordSize"] == nil {
+		recordSize = 28
+		warnings = append(warnings, "RecordSize is not provided in metadata, defaulting to 28 (The supported values are 24, 28, and 32)")
+	} else {
+		recordSize = int(metadata["RecordSize"].(float64))
+	}
+
+	mmdbWriterOptions := &mmdbwriter.Options{
+		BuildEpoch:              epoch,
+		DatabaseType:            databaseType,
+		Description:             description,
+		DisableIPv4Aliasing:     opts.DisableIPv4Aliasing,
+		IncludeReservedNetworks: opts.IncludeReservedNetworks,
+		IPVersion:               ipVersion,
+		Languages:               languages,
+		RecordSize:              recordSize,
+	}
+
+	return mmdbWriterOptions, warnings, nil
+}
+
+// buildEpoch returns the build timestamp of the database, taken from the
+// options, the BuildEpoch of the metadata or the SOURCE_DATE_EPOCH
+// environment variable, in that order. Zero leaves the writer to use the
+// current time.
+func buildEpoch(opts Options, metadata map[string]interface{}) (int64, error) {
+	if opts.BuildEpoch < 0 {
+		return 0, fmt.Errorf("invalid build epoch %d, it must be a positive Unix timestamp", opts.BuildEpoch)
+	}
+	if opts.BuildEpoch != 0 {
+		return opts.BuildEpoch, nil
+	}
+
+	// The metadata has been validated by the caller
+	if epoch, ok := metadata["BuildEpoch"].(float64); ok {
+		return int64(epoch), nil
+	}
+
+	if sourceDateEpoch := os.Getenv("SOURCE_DATE_EPOCH"); sourceDateEpoch != "" {
+		epoch, err := strconv.ParseInt(sourceDateEpoch, 10, 64)
+		if err != nil || epoch <= 0 {
+			return 0, fmt.Errorf("invalid SOURCE_DATE_EPOCH %s, it must be a positive Unix timestamp", sourceDateEpoch)
+		}
+		return epoch, nil
+	}
+
+	return 0, nil
+}
+
+// NewTree creates the MMDB writer tree described by the dataset metadata and
+// the writer options of opts. It returns the defaults applied for the fields
+// missing from the metadata as warnings.
+func NewTree(metadata map[string]interface{}, opts Options) (*mmdbwriter.Tree, []string, error) {
+
+	mmdbWriterOpts, warnings, err := mmdbWriterOptions(opts, metadata)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error initializing MMDB writer options: %w", err)
+	}
+
+	writer, err := mmdbwriter.New(*mmdbWriterOpts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error initializing MMDB writer: %w", err)
+	}
+
+	return writer, warnings, nil
+}
+
+// Build creates a database tree from the entries of reader, whose header
+// holds the metadata of the database. The dataset is expected to be valid,
+// see the validate package, but problems are still reported as errors rather
+// than panics. In strict mode, the schema violations of every record are
+// returned as mmdb.SchemaViolations.
+func Build(ctx context.Context, reader *dataset.Reader, opts Options) (*mmdbwriter.Tree, *Result, error) {
+	return BuildLayers(ctx, []Layer{{Reader: reader}}, opts)
+}
+
+// BuildLayers creates a database tree from several datasets applied in
+// order. The metadata of the first layer is overridden by the metadata of
+// the following layers, and the schemas of every layer are merged. The
+// records of the first layer are inserted with the OnConflict policy, and the
+// records of the following layers are merged with the method of their layer.
+// The errors of a layer are prefixed with its position when there are
+// several layers.
+func BuildLayers(ctx context.Context, layers []Layer, opts Options) (*mmdbwriter.Tree, *Result, error) {
+	if err := opts.validate(); err != nil {
+		return nil, nil, err
+	}
+	if len(layers) == 0 {
+		return nil, nil, fmt.Errorf("no dataset to build the database from")
+	}
+
+	reporter := progress.OrNop(opts.Progress)
+	result := &Result{}
+
+	metadata, err := layerMetadata(layers, reporter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, layer := range layers {
+		if versionInterface, exists := layer.Reader.Header["version"]; exists {
+			if version, ok := versionInterface.(string); ok {
+				if version != "v1" {
+					return nil, nil, fmt.Errorf("unsupported %s version: %s (supported: v1)", layerName(i, len(layers)), version)
+				}
+				reporter.Step(fmt.Sprintf("Dataset version: %s", version))
+			}
+		}
+	}
+
+	schema, useDefaultSchema := layerSchema(layers, result, reporter)
+
+	writer, warnings, err := NewTree(metadata, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error initializing MMDB writer: %w", err)
+	}
+	for _, warning := range warnings {
+		result.warn(reporter, "%s", warning)
+	}
+
+	b := &builder{
+		writer:           writer,
+		schema:           schema,
+		useDefaultSchema: useDefaultSchema,
+		strict:           opts.Strict,
+		result:           result,
+		reporter:         reporter,
+	}
+
+	for i, layer := range layers {
+		var err error
+		if i == 0 {
+			err = b.insert(ctx, layer.Reader, &result.overlapping, func(value mmdbtype.DataType, overlapped *bool) (inserter.Func, error) {
+				return conflictInserter(opts.OnConflict, value, overlapped)
+			})
+		} else {
+			method := layer.method(opts)
+			if _, err := layerInserter(method, nil); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", layerName(i, len(layers)), err)
+			}
+			reporter.Step(fmt.Sprintf("Applying %s with method %s", layerName(i, len(layers)), method))
+			err = b.insert(ctx, layer.Reader, nil, func(value mmdbtype.DataType, _ *bool) (inserter.Func, error) {
+				return layerInserter(method, value)
+			})
+		}
+
+		var violations mmdb.SchemaViolations
+		if errors.As(err, &violations) {
+			if len(layers) > 1 {
+				return nil, result, fmt.Errorf("%s: %w", layerName(i, len(layers)), err)
+			}
+			return nil, result, err
+		}
+		if err != nil {
+			if len(layers) > 1 {
+				return nil, nil, fmt.Errorf("%s: %w", layerName(i, len(layers)), err)
+			}
+			return nil, nil, err
+		}
+	}
+
+	return writer, result, nil
+}
+
+// builder inserts the records of the dataset layers into a tree.
+type builder struct {
+	writer           *mmdbwriter.Tree
+	schema           map[string]interface{}
+	useDefaultSchema bool
+	strict           bool
+	result           *Result
+	reporter         progress.Reporter
+}
+
+// insert inserts the entries of reader with the inserter functions returned
+// by newInserter. The records overlapping a network are counted in the
+// result, and their networks are added to tracker when it is not nil. In
+// strict mode, the schema violations of the layer are returned as
+// mmdb.SchemaViolations once every entry was read.
+func (b *builder) insert(ctx context.Context, reader *dataset.Reader, tracker *overlapTracker, newInserter func(value mmdbtype.DataType, overlapped *bool) (inserter.Func, error)) error {
+	var violations mmdb.SchemaViolations
+	var recordPosition int
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		dataMap, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading dataset: %w", err)
+		}
+
+		b.result.Records++
+		recordPosition++
+
+		networkLabel, _ := dataMap["network"].(string)
+		networks, err := mmdb.ParseNetwork(networkLabel)
+		if err != nil {
+			return fmt.Errorf("invalid network (%s) in the dataset: %w", networkLabel, err)
+		}
+
+		dynamicData, exists := dataMap["record"].(map[string]interface{})
+		if !exists {
+			return fmt.Errorf("error parsing data for record %d (network: %s)", recordPosition, networkLabel)
+		}
+
+		dynamicMmdbData, conversionErrors := mmdb.ConvertToMMDBTypeMapStrict(dynamicData, b.useDefaultSchema, b.schema)
+		for _, conversionError := range conversionErrors {
+			conversionError.Position = recordPosition
+			conversionError.Network = networkLabel
+			if !b.strict {
+				b.result.warn(b.reporter, "%s", conversionError)
+			}
+		}
+		if b.strict && len(conversionErrors) > 0 {
+			violations = append(violations, conversionErrors...)
+			continue
+		}
+
+		var overlapped bool
+		insertFunc, err := newInserter(dynamicMmdbData, &overlapped)
+		if err != nil {
+			return err
+		}
+		for _, network := range networks {
+			err := b.writer.InsertFunc(network, insertFunc)
+			if errors.Is(err, errOverlap) {
+				return &OverlapError{Network: network.String(), Position: recordPosition, network: network}
+			}
+			if err != nil {
+				return fmt.Errorf("error inserting record %d (network: %s) - %w", recordPosition, network, err)
+			}
+		}
+		b.result.Networks += len(networks)
+		if overlapped {
+			b.result.OverlappingRecords++
+			if tracker != nil {
+				for _, network := range networks {
+					tracker.add(network, recordPosition)
+				}
+			}
+		}
+
+		if len(networks) > 1 {
+			b.reporter.Expanded(b.result.Records, networkLabel, mmdb.FormatNetworks(networks))
+		}
+		b.reporter.Record(b.result.Records, networkLabel, dynamicMmdbData)
+	}
+
+	if len(violations) > 0 {
+		return violations
+	}
+
+	return nil
+}
+
+// Generate reads a v1 JSON dataset from src and writes the generated
+// database to dst. Other dataset formats are read by creating their
+// dataset.Reader and calling Build.
+func Generate(ctx context.Context, src io.Reader, dst io.Writer, opts Options) (*Result, error) {
+	reader, err := dataset.NewReader(src)
+	if err != nil {
+		return nil, fmt.Errorf("error reading dataset: %w", err)
+	}
+
+	writer, result, err := Build(ctx, reader, opts)
+	if err != nil {
+		return result, err
+	}
+
+	progress.OrNop(opts.Progress).Step("Writing MMDB database to the output file")
+	result.Size, err = writer.WriteTo(dst)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generate
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a progress.Reporter keeping what it receives.
+type recorder struct {
+	steps    []string
+	records  []string
+	warnings []string
+	expanded []string
+}
+
+func (r *recorder) Step(message string) { r.steps = append(r.steps, message) }
+func (r *recorder) Record(position int, network string, data interface{}) {
+	r.records = append(r.records, network)
+}
+func (r *recorder) Warning(message string) { r.warnings = append(r.warnings, message) }
+func (r *recorder) Expanded(position int, network string, networks string) {
+	r.expanded = append(r.expanded, network+": "+networks)
+}
+
+const buildDataset = `{
+	"version": "v1",
+	"schema": {"asn": "uint32"},
+	"metadata": {
+		"DatabaseType": "Test-DB",
+		"Description": {"en": "Test Database"},
+		"IPVersion": 6,
+		"Languages": ["en"],
+		"RecordSize": 24
+	},
+	"dataset": [
+		{"network": "1.1.1.0/24", "record": {"asn": 13335}},
+		{"network": "8.8.8.0-8.8.10.255", "record": {"asn": 15169}}
+	]
+}`
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("writes the database and reports progress", func(t *testing.T) {
+		t.Parallel()
+
+		var output bytes.Buffer
+		reporter := &recorder{}
+		result, err := Generate(context.Background(), strings.NewReader(buildDataset), &output, Options{
+			BuildEpoch: 1700000000,
+			Progress:   reporter,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, result.Records)
+		assert.Equal(t, 3, result.Networks)
+		assert.Empty(t, result.Warnings)
+		assert.Equal(t, int64(output.Len()), result.Size)
+		assert.Equal(t, []string{"1.1.1.0/24", "8.8.8.0-8.8.10.255"}, reporter.records)
+		assert.Equal(t, []string{"8.8.8.0-8.8.10.255: 8.8.8.0/23, 8.8.10.0/24"}, reporter.expanded)
+		assert.NotEmpty(t, reporter.steps)
+
+		db, err := maxminddb.FromBytes(output.Bytes())
+		require.NoError(t, err)
+		defer db.Close()
+		require.NoError(t, db.Verify())
+		assert.Equal(t, uint(1700000000), db.Metadata.BuildEpoch)
+
+		var record map[string]interface{}
+		require.NoError(t, db.Lookup(net.ParseIP("8.8.9.8"), &record))
+		assert.Equal(t, uint64(15169), record["asn"])
+	})
+
+	t.Run("returns warnings for missing metadata and schema violations", func(t *testing.T) {
+		t.Parallel()
+
+		dataset := `{
+			"schema": {"asn": "uint32"},
+			"metadata": {"DatabaseType": "Test-DB", "Description": {"en": "Test"}},
+			"dataset": [{"network": "1.1.1.0/24", "record": {"asn": "not a number"}}]
+		}`
+		reporter := &recorder{}
+		result, err := Generate(context.Background(), strings.NewReader(dataset), &bytes.Buffer{}, Options{Progress: reporter})
+		require.NoError(t, err)
+
+		assert.Len(t, result.Warnings, 4)
+		assert.Equal(t, result.Warnings, reporter.warnings)
+	})
+
+	t.Run("strict mode returns the violations", func(t *testing.T) {
+		t.Parallel()
+
+		dataset := `{
+			"schema": {"asn": "uint32"},
+			"metadata": {"DatabaseType": "Test-DB", "Description": {"en": "Test"}},
+			"dataset": [{"network": "1.1.1.0/24", "record": {"asn": "not a number"}}]
+		}`
+		_, err := Generate(context.Background(), strings.NewReader(dataset), &bytes.Buffer{}, Options{Strict: true})
+		require.Error(t, err)
+		var violations mmdb.SchemaViolations
+		assert.ErrorAs(t, err, &violations)
+	})
+
+	t.Run("stops when the context is canceled", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var output bytes.Buffer
+		_, err := Generate(ctx, strings.NewReader(buildDataset), &output, Options{})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Zero(t, output.Len())
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := Generate(context.Background(), strings.NewReader(buildDataset), &bytes.Buffer{}, Options{OnConflict: "unknown"})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,293 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/InfraZ/mmdb-cli/pkg/dataset"
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// Policies for records whose network overlaps a network inserted earlier.
+const (
+	OnConflictReplace       = "replace"
+	OnConflictKeepExisting  = "keep-existing"
+	OnConflictDeepMerge     = "deep-merge"
+	OnConflictTopLevelMerge = "top-level-merge"
+	OnConflictError         = "error"
+)
+
+var errOverlap = errors.New("network overlaps a network inserted earlier")
+
+// conflictPolicy returns the name of policy, which defaults to replace.
+func conflictPolicy(policy string) string {
+	if policy == "" {
+		return OnConflictReplace
+	}
+	return policy
+}
+
+// conflictInserter returns the inserter function applying policy to value.
+// It sets overlapped when the network already holds data.
+func conflictInserter(policy string, value mmdbtype.DataType, overlapped *bool) (inserter.Func, error) {
+	var insert inserter.Func
+
+	switch policy {
+	case "", OnConflictReplace:
+		insert = inserter.ReplaceWith(value)
+	case OnConflictKeepExisting:
+		insert = func(existingValue mmdbtype.DataType) (mmdbtype.DataType, error) {
+			if existingValue != nil {
+				return existingValue, nil
+			}
+			return value, nil
+		}
+	case OnConflictDeepMerge:
+		insert = inserter.DeepMergeWith(value)
+	case OnConflictTopLevelMerge:
+		insert = inserter.TopLevelMergeWith(value)
+	case OnConflictError:
+		insert = func(existingValue mmdbtype.DataType) (mmdbtype.DataType, error) {
+			if existingValue != nil {
+				return nil, errOverlap
+			}
+			return value, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported conflict policy '%s' (supported: %s, %s, %s, %s, %s)", policy,
+			OnConflictReplace, OnConflictKeepExisting, OnConflictDeepMerge, OnConflictTopLevelMerge, OnConflictError)
+	}
+
+	return func(existingValue mmdbtype.DataType) (mmdbtype.DataType, error) {
+		if existingValue != nil {
+			*overlapped = true
+		}
+		return insert(existingValue)
+	}, nil
+}
+
+// Overlap is a pair of dataset records whose networks overlap, the first
+// one being inserted before the second.
+type Overlap struct {
+	Network         string
+	Position        int
+	OverlapNetwork  string
+	OverlapPosition int
+}
+
+func (o Overlap) String() string {
+	return fmt.Sprintf("%s (record %d) and %s (record %d)", o.Network, o.Position, o.OverlapNetwork, o.OverlapPosition)
+}
+
+// OverlapError is returned for a record overlapping a network inserted
+// earlier with the OnConflictError policy.
+type OverlapError struct {
+	Network  string
+	Position int
+
+	// Overlaps lists the records the failing record overlaps once they were
+	// found by reading the dataset again
+	Overlaps []Overlap
+
+	network *net.IPNet
+}
+
+func (e *OverlapError) Error() string {
+	message := fmt.Sprintf("record %d (network: %s) overlaps a network inserted earlier", e.Position, e.Network)
+	if len(e.Overlaps) == 0 {
+		return message
+	}
+
+	pairs := make([]string, 0, len(e.Overlaps))
+	for _, overlap := range e.Overlaps {
+		pairs = append(pairs, overlap.String())
+	}
+	return message + ":\n  - " + strings.Join(pairs, "\n  - ")
+}
+
+// ListOverlaps sets Overlaps to the records of reader overlapped by the
+// failing record, reader reading the dataset of the failing record again.
+func (e *OverlapError) ListOverlaps(reader *dataset.Reader) error {
+	var tracker overlapTracker
+	tracker.add(e.network, e.Position)
+
+	overlaps, err := tracker.pairs(reader)
+	if err != nil {
+		return err
+	}
+	e.Overlaps = overlaps
+	return nil
+}
+
+type insertedNetwork struct {
+	prefix   netip.Prefix
+	network  string
+	position int
+}
+
+// overlapTracker keeps the networks of the records that overlapped a network
+// inserted earlier, so that memory use grows with the number of overlapping
+// records only. The records they overlap are found by reading the dataset
+// again.
+type overlapTracker struct {
+	networks []insertedNetwork
+}
+
+func (o *overlapTracker) add(network *net.IPNet, position int) {
+	o.networks = append(o.networks, insertedNetwork{
+		prefix:   treePrefix(network),
+		network:  network.String(),
+		position: position,
+	})
+}
+
+// treePrefix returns the prefix of network in an IPv6 tree, where IPv4
+// networks are found under ::/96.
+func treePrefix(network *net.IPNet) netip.Prefix {
+	ones, _ := network.Mask.Size()
+	if ipv4 := network.IP.To4(); ipv4 != nil && len(network.Mask) == net.IPv4len {
+		var ipv6 [16]byte
+		copy(ipv6[12:], ipv4)
+		return netip.PrefixFrom(netip.AddrFrom16(ipv6), ones+96)
+	}
+	addr, _ := netip.AddrFromSlice(network.IP.To16())
+	return netip.PrefixFrom(addr, ones)
+}
+
+// pairs reads the records of reader, the dataset the tracked records belong
+// to, and returns every pair of a record overlapping a later tracked record,
+// ordered by the position of the later record. The reading stops at the
+// last tracked record.
+func (o *overlapTracker) pairs(reader *dataset.Reader) ([]Overlap, error) {
+	index := newOverlapIndex(o.networks)
+
+	var overlaps []Overlap
+	for position := 1; position < index.lastPosition; position++ {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading dataset: %w", err)
+		}
+
+		networkLabel, _ := entry["network"].(string)
+		networks, err := mmdb.ParseNetwork(networkLabel)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network (%s) in the dataset: %w", networkLabel, err)
+		}
+
+		for _, network := range networks {
+			for _, tracked := range index.overlapping(treePrefix(network)) {
+				if tracked.position <= position {
+					continue
+				}
+				overlaps = append(overlaps, Overlap{
+					Network:         network.String(),
+					Position:        position,
+					OverlapNetwork:  tracked.network,
+					OverlapPosition: tracked.position,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(overlaps, func(i, j int) bool {
+		if overlaps[i].OverlapPosition != overlaps[j].OverlapPosition {
+			return overlaps[i].OverlapPosition < overlaps[j].OverlapPosition
+		}
+		return overlaps[i].Position < overlaps[j].Position
+	})
+
+	return overlaps, nil
+}
+
+// overlapIndex finds the tracked networks overlapping a network, which
+// either hold its address or are within it.
+type overlapIndex struct {
+	// sorted holds the networks ordered by address and then by prefix length
+	sorted []insertedNetwork
+	// byPrefix holds the networks by prefix, and lengths their prefix
+	// lengths in increasing order
+	byPrefix     map[netip.Prefix][]insertedNetwork
+	lengths      []int
+	lastPosition int
+}
+
+func newOverlapIndex(networks []insertedNetwork) *overlapIndex {
+	index := &overlapIndex{
+		sorted:   make([]insertedNetwork, len(networks)),
+		byPrefix: map[netip.Prefix][]insertedNetwork{},
+	}
+	copy(index.sorted, networks)
+	sort.SliceStable(index.sorted, func(i, j int) bool {
+		if cmp := index.sorted[i].prefix.Addr().Compare(index.sorted[j].prefix.Addr()); cmp != 0 {
+			return cmp < 0
+		}
+		return index.sorted[i].prefix.Bits() < index.sorted[j].prefix.Bits()
+	})
+
+	for _, network := range networks {
+		if _, known := index.byPrefix[network.prefix]; !known {
+			index.lengths = append(index.lengths, network.prefix.Bits())
+		}
+		index.byPrefix[network.prefix] = append(index.byPrefix[network.prefix], network)
+		index.lastPosition = max(index.lastPosition, network.position)
+	}
+	sort.Ints(index.lengths)
+	index.lengths = slices.Compact(index.lengths)
+
+	return index
+}
+
+func (x *overlapIndex) overlapping(prefix netip.Prefix) []insertedNetwork {
+	var found []insertedNetwork
+
+	// The networks holding the address of prefix
+	for _, bits := range x.lengths {
+		if bits >= prefix.Bits() {
+			break
+		}
+		container, _ := prefix.Addr().Prefix(bits)
+		found = append(found, x.byPrefix[container]...)
+	}
+
+	// The networks within prefix, including prefix itself
+	start := sort.Search(len(x.sorted), func(i int) bool {
+		return x.sorted[i].prefix.Addr().Compare(prefix.Addr()) >= 0
+	})
+	for _, network := range x.sorted[start:] {
+		if !prefix.Contains(network.prefix.Addr()) {
+			break
+		}
+		if network.prefix.Bits() >= prefix.Bits() {
+			found = append(found, network)
+		}
+	}
+
+	return found
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generate
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/InfraZ/mmdb-cli/pkg/dataset"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlapTracker(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		networks []string
+		want     []string
+	}{
+		{
+			name:     "disjoint networks",
+			networks: []string{"1.0.0.0/24", "1.0.1.0/24", "2001:db8::/32"},
+		},
+		{
+			name:     "nested networks",
+			networks: []string{"1.0.0.0/16", "1.0.1.0/24", "1.0.1.128/25", "1.0.2.0/24"},
+			want: []string{
+				"1.0.0.0/16 (record 1) and 1.0.1.0/24 (record 2)",
+				"1.0.0.0/16 (record 1) and 1.0.1.128/25 (record 3)",
+				"1.0.1.0/24 (record 2) and 1.0.1.128/25 (record 3)",
+				"1.0.0.0/16 (record 1) and 1.0.2.0/24 (record 4)",
+			},
+		},
+		{
+			name:     "larger network inserted later",
+			networks: []string{"1.0.1.0/24", "1.0.0.0/16"},
+			want:     []string{"1.0.1.0/24 (record 1) and 1.0.0.0/16 (record 2)"},
+		},
+		{
+			name:     "equal networks",
+			networks: []string{"1.0.0.0/24", "1.0.0.0/24"},
+			want:     []string{"1.0.0.0/24 (record 1) and 1.0.0.0/24 (record 2)"},
+		},
+		{
+			name:     "IPv4 network within IPv6 network",
+			networks: []string{"::/0", "1.0.0.0/24", "2001:db8::/32"},
+			want: []string{
+				"::/0 (record 1) and 1.0.0.0/24 (record 2)",
+				"::/0 (record 1) and 2001:db8::/32 (record 3)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var tracker overlapTracker
+			var entries []string
+			for i, cidr := range tt.networks {
+				_, network, err := net.ParseCIDR(cidr)
+				require.NoError(t, err)
+				// Tracking the records that overlap nothing adds no pair
+				tracker.add(network, i+1)
+				entries = append(entries, fmt.Sprintf(`{"network":%q,"record":{}}`, cidr))
+			}
+
+			reader, err := dataset.NewReader(strings.NewReader(`{"dataset":[` + strings.Join(entries, ",") + `]}`))
+			require.NoError(t, err)
+			overlaps, err := tracker.pairs(reader)
+			require.NoError(t, err)
+
+			var got []string
+			for _, overlap := range overlaps {
+				got = append(got, overlap.String())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestResultListOverlaps(t *testing.T) {
+	t.Parallel()
+
+	content := `{"metadata":{"DatabaseType":"Test","Description":{"en":"Test"}},"dataset":[
+		{"network":"1.0.0.0/16","record":{"a":"b"}},
+		{"network":"2.0.0.0/24","record":{"a":"b"}},
+		{"network":"1.0.1.0/24","record":{"a":"b"}},
+		{"network":"3.0.0.0/24","record":{"a":"b"}}
+	]}`
+
+	reader, err := dataset.NewReader(strings.NewReader(content))
+	require.NoError(t, err)
+	_, result, err := Build(context.Background(), reader, Options{})
+	require.NoError(t, err)
+
+	// Only the network of the overlapping record is kept
+	assert.Equal(t, 1, result.OverlappingRecords)
+	require.Len(t, result.overlapping.networks, 1)
+	assert.Empty(t, result.Overlaps)
+
+	reader, err = dataset.NewReader(strings.NewReader(content))
+	require.NoError(t, err)
+	require.NoError(t, result.ListOverlaps(reader))
+	require.Len(t, result.Overlaps, 1)
+	assert.Equal(t, "1.0.0.0/16 (record 1) and 1.0.1.0/24 (record 3)", result.Overlaps[0].String())
+
+	reader, err = dataset.NewReader(strings.NewReader(content))
+	require.NoError(t, err)
+	_, _, err = Build(context.Background(), reader, Options{OnConflict: OnConflictError})
+	var overlapErr *OverlapError
+	require.ErrorAs(t, err, &overlapErr)
+	assert.Equal(t, "record 3 (network: 1.0.1.0/24) overlaps a network inserted earlier", overlapErr.Error())
+
+	reader, err = dataset.NewReader(strings.NewReader(content))
+	require.NoError(t, err)
+	require.NoError(t, overlapErr.ListOverlaps(reader))
+	assert.Equal(t, "record 3 (network: 1.0.1.0/24) overlaps a network inserted earlier:\n  - 1.0.0.0/16 (record 1) and 1.0.1.0/24 (record 3)", overlapErr.Error())
+}
+
+func TestConflictInserter(t *testing.T) {
+	t.Parallel()
+
+	var overlapped bool
+	_, err := conflictInserter("unknown", nil, &overlapped)
+	assert.Error(t, err)
+
+	insert, err := conflictInserter("", nil, &overlapped)
+	require.NoError(t, err)
+	_, err = insert(nil)
+	require.NoError(t, err)
+	assert.False(t, overlapped)
+
+	insert, err = conflictInserter(OnConflictError, nil, &overlapped)
+	require.NoError(t, err)
+	_, err = insert(nil)
+	require.NoError(t, err)
+	_, err = insert(mmdbtype.String("existing"))
+	assert.ErrorIs(t, err, errOverlap)
+	assert.True(t, overlapped)
+}
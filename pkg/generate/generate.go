@@ -17,15 +17,18 @@ limitations under the License.
 package generate
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net"
+	"log/slog"
 	"os"
+	"path/filepath"
 
 	"github.com/InfraZ/mmdb-cli/internal/files"
-	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
-	"github.com/maxmind/mmdbwriter"
+	"github.com/InfraZ/mmdb-cli/pkg/dataset"
+	"github.com/InfraZ/mmdb-cli/pkg/progress"
+	"github.com/InfraZ/mmdb-cli/pkg/validate"
+	"github.com/InfraZ/mmdb-cli/pkg/verify"
 )
 
 type CmdGenerateConfig struct {
@@ -33,8 +36,39 @@ type CmdGenerateConfig struct {
 	OutputDatabase string
 	Verbose        bool
 
+	// Layers are the dataset files applied in order over InputDataset, see
+	// BuildLayers. InputDataset and Layers can also be directories, whose
+	// dataset files are applied in the order of their names.
+	Layers []string
+
+	// LayerMethod merges the records of the layers whose header has no
+	// "method", one of the Method constants (default deep_merge)
+	LayerMethod string
+
 	DisableIPv4Aliasing     bool
 	IncludeReservedNetworks bool
+
+	// Strict makes schema violations fail the generation instead of being
+	// logged and written as zero values
+	Strict bool
+
+	// Mapping is the mapping file describing the columns of a CSV dataset
+	Mapping string
+
+	// OnConflict is the policy for records whose network overlaps a network
+	// inserted earlier, one of the OnConflict constants (default replace)
+	OnConflict string
+
+	// BuildEpoch is the build timestamp written to the database, taking
+	// precedence over the BuildEpoch of the metadata and SOURCE_DATE_EPOCH
+	BuildEpoch int64
+
+	// SkipValidation skips the validation pass over the dataset that runs
+	// before the database is generated
+	SkipValidation bool
+
+	// Verify checks the written database before it replaces the output file
+	Verify bool
 }
 
 /*
@@ -59,211 +93,227 @@ Structure of the dumped JSON dataset:
 	}
 */
 
-func readDataSet(inputDataSet string) (map[string]interface{}, error) {
-	_, err := os.Stat(inputDataSet)
-	if os.IsNotExist(err) {
-		return nil, fmt.Errorf("file %s does not exist", inputDataSet)
-	}
+// datasetInputs are the extensions of the dataset files generate reads.
+var datasetInputs = append([]string{".csv"}, dataset.Extensions...)
 
-	datasetFile, err := os.Open(inputDataSet)
-	if err != nil {
-		return nil, err
-	}
-	defer datasetFile.Close()
-
-	byteValue, err := io.ReadAll(datasetFile)
-	if err != nil {
-		return nil, err
-	}
-
-	var dataset map[string]interface{}
-	if err := json.Unmarshal(byteValue, &dataset); err != nil {
-		return nil, err
+// openDataset opens the dataset file at path, reading CSV files through the
+// mapping file.
+func openDataset(path string, mapping string) (*dataset.Reader, error) {
+	if files.CheckFileExtension(path, ".csv") {
+		if mapping == "" {
+			return nil, fmt.Errorf("a mapping file is required for CSV datasets")
+		}
+		return dataset.OpenCSV(path, mapping)
 	}
 
-	return dataset, nil
+	return dataset.Open(path)
 }
 
-func mmdbWriterOptions(cfg *CmdGenerateConfig, metadata map[string]interface{}) (*mmdbwriter.Options, error) {
-
-	if metadata["BuildEpoch"] != nil {
-		fmt.Println("[-] BuildEpoch in metadata will be ignored")
-	}
-
-	var databaseType string
-	if metadata["DatabaseType"] == nil {
-		return nil, fmt.Errorf("DatabaseType is a required field in metadata")
+// readOverlaps reads the dataset file at path again with list, which finds
+// the records overlapping each other.
+func readOverlaps(path string, mapping string, list func(*dataset.Reader) error) error {
+	reader, err := openDataset(path, mapping)
+	if err != nil {
+		return fmt.Errorf("error reading dataset %s: %w", path, err)
 	}
-	databaseType = metadata["DatabaseType"].(string)
+	defer reader.Close()
 
-	description := make(map[string]string)
-	if metadata["Description"] == nil {
-		return nil, fmt.Errorf("Description is a required field in metadata")
-	}
-	for descriptionKey, descriptionValue := range metadata["Description"].(map[string]interface{}) {
-		description[descriptionKey] = descriptionValue.(string)
-	}
+	return list(reader)
+}
 
-	var ipVersion int
-	if metadata["IPVersion"] == nil {
-		metadata["IPVersion"] = 6
-		fmt.Println("[-] IPVersion is not provided in metadata, defaulting to 6 (An IPv6 database supports both IPv4 and IPv6 lookups)")
-	} else {
-		ipVersion = int(metadata["IPVersion"].(float64))
-		if ipVersion != 4 && ipVersion != 6 {
-			return nil, fmt.Errorf("invalid value for IPVersion in metadata, supported values are 4 and 6")
+// datasetFiles returns the dataset files of the inputs in order, replacing
+// every directory with its dataset files sorted by name. Subdirectories and
+// the files without a dataset extension are skipped.
+func datasetFiles(inputs []string) ([]string, error) {
+	var paths []string
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if files.IsStdio(input) || err != nil || !info.IsDir() {
+			paths = append(paths, input)
+			continue
 		}
-	}
 
-	languages := make([]string, 0)
-	if metadata["Languages"] == nil {
-		languages = append(languages, "en")
-		fmt.Println("[-] Languages is not provided in metadata, defaulting to English")
-	} else {
-		for _, language := range metadata["Languages"].([]interface{}) {
-			languages = append(languages, language.(string))
+		entries, err := os.ReadDir(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read directory %s: %w", input, err)
 		}
-	}
-
-	var recordSize int
-	if metadata["RecordSize"] == nil {
-		recordSize = 28
-		fmt.Println("[-] RecordSize is not provided in metadata, defaulting to 28 (The supported values are 24, 28, and 32)")
-	} else {
-		recordSize = int(metadata["RecordSize"].(float64))
-		if recordSize != 24 && recordSize != 28 && recordSize != 32 {
-			return nil, fmt.Errorf("invalid value for RecordSize in metadata, supported values are 24, 28, and 32")
+		found := false
+		for _, entry := range entries {
+			if entry.IsDir() || !files.CheckFileExtensions(entry.Name(), datasetInputs) {
+				continue
+			}
+			paths = append(paths, filepath.Join(input, entry.Name()))
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("directory %s holds no dataset file", input)
 		}
 	}
+	return paths, nil
+}
 
-	mmdbWriterOptions := &mmdbwriter.Options{
-		DatabaseType:            databaseType,
-		Description:             description,
+// options returns the library options matching the configuration.
+func (cfg *CmdGenerateConfig) options(reporter progress.Reporter) Options {
+	return Options{
 		DisableIPv4Aliasing:     cfg.DisableIPv4Aliasing,
 		IncludeReservedNetworks: cfg.IncludeReservedNetworks,
-		IPVersion:               ipVersion,
-		Languages:               languages,
-		RecordSize:              recordSize,
+		Strict:                  cfg.Strict,
+		OnConflict:              cfg.OnConflict,
+		BuildEpoch:              cfg.BuildEpoch,
+		LayerMethod:             cfg.LayerMethod,
+		Progress:                reporter,
 	}
-
-	return mmdbWriterOptions, nil
 }
 
-func initializeMMDBWriter(cfg *CmdGenerateConfig, metadata map[string]interface{}) (*mmdbwriter.Tree, error) {
+func GenerateMMDB(cfg *CmdGenerateConfig) error {
 
-	mmdbWriterOpts, err := mmdbWriterOptions(cfg, metadata)
+	inputs, err := datasetFiles(append([]string{cfg.InputDataset}, cfg.Layers...))
 	if err != nil {
-		return nil, fmt.Errorf("error initializing MMDB writer options: %w", err)
+		return err
 	}
 
-	writer, err := mmdbwriter.New(*mmdbWriterOpts)
-	if err != nil {
-		return nil, fmt.Errorf("error initializing MMDB writer: %w", err)
+	var filesToCheck []files.FilesListValidation
+	stdinInputs := 0
+	for _, input := range inputs {
+		filesToCheck = append(filesToCheck, files.FilesListValidation{FilePath: input, ExpectedExtensions: datasetInputs, ShouldExist: true, AllowStdio: true})
+		if files.IsStdio(input) {
+			stdinInputs++
+		}
 	}
-
-	return writer, nil
-}
-
-func GenerateMMDB(cfg *CmdGenerateConfig) error {
-
-	filesToCheck := []files.FilesListValidation{
-		{FilePath: cfg.InputDataset, ExpectedExtension: ".json", ShouldExist: true},
+	if cfg.Mapping != "" {
+		filesToCheck = append(filesToCheck, files.FilesListValidation{FilePath: cfg.Mapping, ExpectedExtension: ".json", ShouldExist: true})
 	}
 
 	if err := files.FilesValidation(filesToCheck); err != nil {
 		return err
 	}
 
-	var recordPosition int = 0
-
-	var dataset map[string]interface{}
-	dataset, err := readDataSet(cfg.InputDataset)
-	if err != nil {
-		return fmt.Errorf("error reading dataset: %w", err)
+	if stdinInputs > 1 {
+		return fmt.Errorf("the standard input can only be read as one of the datasets")
 	}
 
-	metadata := dataset["metadata"]
-
-	var schema map[string]interface{}
-	var useDefaultSchema bool = true
-
-	if versionInterface, exists := dataset["version"]; exists {
-		if version, ok := versionInterface.(string); ok {
-			if version != "v1" {
-				return fmt.Errorf("unsupported dataset version: %s (supported: v1)", version)
-			}
-			fmt.Printf("[+] Dataset version: %s\n", version)
-		}
+	if cfg.Verify && files.IsStdio(cfg.OutputDatabase) {
+		return fmt.Errorf("a database written to the standard output cannot be verified")
 	}
 
-	if schemaInterface, exists := dataset["schema"]; exists {
-		if schemaMap, ok := schemaInterface.(map[string]interface{}); ok {
-			schema = schemaMap
-			useDefaultSchema = false
-			fmt.Printf("[+] Using dynamic schema from dataset: %v\n", schema)
-		} else {
-			fmt.Println("[-] Schema field exists but is not a valid object, falling back to default schema")
-		}
-	} else {
-		fmt.Println("[-] No schema found in dataset, using default schema")
+	logger := &progress.Logger{
+		Verbose:       cfg.Verbose,
+		Bar:           progress.TerminalBar(os.Stderr),
+		CountFormat:   "Inserted %d records",
+		RecordMessage: "Inserting record",
 	}
+	opts := cfg.options(logger)
 
-	writer, err := initializeMMDBWriter(cfg, metadata.(map[string]interface{}))
-	if err != nil {
-		return fmt.Errorf("error initializing MMDB writer: %w", err)
+	// Check the options before reading the dataset
+	if err := opts.validate(); err != nil {
+		return err
 	}
 
-	for _, dataset := range dataset["dataset"].([]interface{}) {
-
-		recordPosition++
-
-		dataMap := dataset.(map[string]interface{})
-
-		_, network, err := net.ParseCIDR(dataMap["network"].(string))
+	// The datasets are read once to validate them and once to generate the
+	// database, so the standard input is kept in a temporary file
+	for i, input := range inputs {
+		if !files.IsStdio(input) {
+			continue
+		}
+		spoolPath, err := dataset.Spool(os.Stdin, cfg.Mapping != "")
 		if err != nil {
-			return fmt.Errorf("invalid network (%s) in the dataset: %w", dataMap["network"].(string), err)
+			return fmt.Errorf("error reading dataset from the standard input: %w", err)
 		}
+		defer os.Remove(spoolPath)
+		inputs[i] = spoolPath
+	}
 
-		dynamicData, exists := dataMap["record"].(map[string]interface{})
-		if !exists {
-			return fmt.Errorf("error parsing data for record %d (network: %s)", recordPosition, network)
+	if cfg.Mapping != "" {
+		csvInputs := false
+		for _, input := range inputs {
+			csvInputs = csvInputs || files.CheckFileExtension(input, ".csv")
 		}
+		if !csvInputs {
+			slog.Warn("Mapping file is only used for CSV datasets and will be ignored")
+		}
+	}
 
-		dynamicMmdbData := mmdb.ConvertToMMDBTypeMap(dynamicData, useDefaultSchema, schema)
+	if len(inputs) > 1 {
+		slog.Info("Generating from dataset layers", "layers", len(inputs))
+	}
 
-		if err := writer.Insert(network, dynamicMmdbData); err != nil {
-			return fmt.Errorf("error inserting record %d (network: %s) - %w", recordPosition, network, err)
+	if !cfg.SkipValidation {
+		slog.Info("Validating dataset")
+		for i, input := range inputs {
+			kind := validate.KindGenerate
+			if i > 0 {
+				kind = validate.KindLayer
+			}
+			if _, err := validate.ValidateDataset(validate.CmdValidateDatasetConfig{
+				InputDataset: input,
+				Mapping:      cfg.Mapping,
+				Kind:         kind,
+			}); err != nil {
+				if len(inputs) > 1 {
+					return fmt.Errorf("invalid dataset %s: %w", input, err)
+				}
+				return err
+			}
 		}
+	}
 
-		if cfg.Verbose {
-			fmt.Printf("[-] Inserting record %d for network %s - data: %v\n", recordPosition, network, dynamicMmdbData)
-		} else {
-			fmt.Printf("\r[-] Inserted %d records", recordPosition)
+	layers := make([]Layer, 0, len(inputs))
+	for _, input := range inputs {
+		datasetReader, err := openDataset(input, cfg.Mapping)
+		if err != nil {
+			return fmt.Errorf("error reading dataset %s: %w", input, err)
 		}
+		defer datasetReader.Close()
+		layers = append(layers, Layer{Reader: datasetReader})
 	}
 
-	fmt.Printf("\r[+] Total records inserted: %d\n", recordPosition)
-
-	outputFile, err := os.Create(cfg.OutputDatabase)
+	writer, result, err := BuildLayers(context.Background(), layers, opts)
+	logger.EndLine()
+	var overlapErr *OverlapError
+	if errors.As(err, &overlapErr) {
+		if listErr := readOverlaps(inputs[0], cfg.Mapping, overlapErr.ListOverlaps); listErr != nil {
+			slog.Warn("Failed to list the overlapped records", "error", listErr)
+		}
+	}
 	if err != nil {
 		return err
 	}
-	defer outputFile.Close()
 
-	fmt.Println("[+] Writing MMDB database to the output file")
-	if _, err = writer.WriteTo(outputFile); err != nil {
-		return err
+	slog.Info("Total records inserted", "records", result.Records, "networks", result.Networks)
+
+	if result.OverlappingRecords > 0 {
+		slog.Warn("Records overlap networks inserted earlier", "records", result.OverlappingRecords, "policy", conflictPolicy(cfg.OnConflict))
+		if err := readOverlaps(inputs[0], cfg.Mapping, result.ListOverlaps); err != nil {
+			return err
+		}
+		for _, overlap := range result.Overlaps {
+			slog.Warn("Overlapping networks", "overlap", overlap.String())
+		}
 	}
 
-	outputDatabaseStat, err := outputFile.Stat()
+	slog.Info("Writing MMDB database to the output file", "path", cfg.OutputDatabase)
+	size, err := files.WriteOutput(cfg.OutputDatabase, writer, verifyOutput(cfg.Verify))
 	if err != nil {
-		return fmt.Errorf("failed to get output file stats: %s - %w", cfg.OutputDatabase, err)
+		return err
 	}
+	slog.Info("MMDB file created", "path", cfg.OutputDatabase, "size_mb", fmt.Sprintf("%.2f", float64(size)/1024/1024))
 
-	outputDatabaseSizeMB := float64(outputDatabaseStat.Size()) / 1024 / 1024
-	fmt.Printf("\r[+] %s file created with size: %.2f MB\n", cfg.OutputDatabase, outputDatabaseSizeMB)
+	slog.Info("MMDB Generated successfully")
 
-	fmt.Println("[+] MMDB Generated successfully")
+	return nil
+}
 
-	return err
+// verifyOutput returns the check run on a written database before it is moved
+// into place, or nil when the database should not be verified.
+func verifyOutput(enabled bool) func(string) error {
+	if !enabled {
+		return nil
+	}
+	return func(tempPath string) error {
+		slog.Info("Verifying the written MMDB database")
+		if _, err := verify.VerifyMMDB(verify.CmdVerifyConfig{InputFile: tempPath}); err != nil {
+			return fmt.Errorf("written MMDB database is invalid: %w", err)
+		}
+		return nil
+	}
 }
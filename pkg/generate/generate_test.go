@@ -17,10 +17,14 @@ limitations under the License.
 package generate
 
 import (
+	"encoding/json"
+	"math/big"
+	"net"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
 	"github.com/oschwald/maxminddb-golang"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -46,6 +50,11 @@ func TestReadDataSet(t *testing.T) {
 			content: `{"metadata":{"DatabaseType":"Test"},"dataset":[]}`,
 			wantErr: false,
 		},
+		{
+			name:    "sorted keys",
+			content: `{"dataset":[{"network":"1.0.0.0/24","record":{"a":"b"}}],"metadata":{"DatabaseType":"Test"},"version":"v1"}`,
+			wantErr: false,
+		},
 		{
 			name:    "invalid JSON",
 			content: `{invalid`,
@@ -59,19 +68,20 @@ func TestReadDataSet(t *testing.T) {
 			dir := t.TempDir()
 			path := writeTestJSON(t, dir, "data.json", tt.content)
 
-			result, err := readDataSet(path)
+			result, err := openDataset(path, "")
 			if tt.wantErr {
 				assert.Error(t, err)
 				return
 			}
 			require.NoError(t, err)
-			assert.NotNil(t, result)
+			defer result.Close()
+			assert.Equal(t, map[string]interface{}{"DatabaseType": "Test"}, result.Header["metadata"])
 		})
 	}
 
 	t.Run("non-existent file", func(t *testing.T) {
 		t.Parallel()
-		_, err := readDataSet("/nonexistent/file.json")
+		_, err := openDataset("/nonexistent/file.json", "")
 		assert.Error(t, err)
 	})
 }
@@ -79,8 +89,6 @@ func TestReadDataSet(t *testing.T) {
 func TestMmdbWriterOptions(t *testing.T) {
 	t.Parallel()
 
-	baseCfg := &CmdGenerateConfig{}
-
 	tests := []struct {
 		name     string
 		metadata map[string]interface{}
@@ -166,7 +174,7 @@ func TestMmdbWriterOptions(t *testing.T) {
 			wantErr: false,
 		},
 		{
-			name: "BuildEpoch is ignored",
+			name: "BuildEpoch from metadata",
 			metadata: map[string]interface{}{
 				"DatabaseType": "GeoIP2-City",
 				"Description":  map[string]interface{}{"en": "Test DB"},
@@ -174,12 +182,50 @@ func TestMmdbWriterOptions(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "invalid BuildEpoch",
+			metadata: map[string]interface{}{
+				"DatabaseType": "GeoIP2-City",
+				"Description":  map[string]interface{}{"en": "Test DB"},
+				"BuildEpoch":   "yesterday",
+			},
+			wantErr: true,
+		},
+		{
+			name: "fractional BuildEpoch",
+			metadata: map[string]interface{}{
+				"DatabaseType": "GeoIP2-City",
+				"Description":  map[string]interface{}{"en": "Test DB"},
+				"BuildEpoch":   1.5,
+			},
+			wantErr: true,
+		},
+		{
+			name: "wrongly typed fields",
+			metadata: map[string]interface{}{
+				"DatabaseType": float64(1),
+				"Description":  map[string]interface{}{"en": true},
+				"IPVersion":    "6",
+				"Languages":    "en",
+				"RecordSize":   "28",
+			},
+			wantErr: true,
+		},
+		{
+			name: "null optional fields from dump",
+			metadata: map[string]interface{}{
+				"DatabaseType": "GeoIP2-City",
+				"Description":  map[string]interface{}{"en": "Test DB"},
+				"Languages":    nil,
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			opts, err := mmdbWriterOptions(baseCfg, tt.metadata)
+			opts, _, err := mmdbWriterOptions(Options{}, tt.metadata)
 			if tt.wantErr {
 				assert.Error(t, err)
 				assert.Nil(t, opts)
@@ -191,6 +237,40 @@ func TestMmdbWriterOptions(t *testing.T) {
 	}
 }
 
+func TestBuildEpoch(t *testing.T) {
+	metadata := map[string]interface{}{"BuildEpoch": float64(1600000000)}
+
+	tests := []struct {
+		name            string
+		opts            Options
+		metadata        map[string]interface{}
+		sourceDateEpoch string
+		want            int64
+		wantErr         bool
+	}{
+		{name: "current time", opts: Options{}, want: 0},
+		{name: "metadata", opts: Options{}, metadata: metadata, want: 1600000000},
+		{name: "flag over metadata", opts: Options{BuildEpoch: 1700000000}, metadata: metadata, want: 1700000000},
+		{name: "metadata over SOURCE_DATE_EPOCH", opts: Options{}, metadata: metadata, sourceDateEpoch: "1500000000", want: 1600000000},
+		{name: "SOURCE_DATE_EPOCH", opts: Options{}, sourceDateEpoch: "1500000000", want: 1500000000},
+		{name: "invalid SOURCE_DATE_EPOCH", opts: Options{}, sourceDateEpoch: "now", wantErr: true},
+		{name: "negative flag", opts: Options{BuildEpoch: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SOURCE_DATE_EPOCH", tt.sourceDateEpoch)
+			epoch, err := buildEpoch(tt.opts, tt.metadata)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, epoch)
+		})
+	}
+}
+
 func TestGenerateMMDB(t *testing.T) {
 	t.Parallel()
 
@@ -245,6 +325,40 @@ func TestGenerateMMDB(t *testing.T) {
 		assert.Equal(t, "Test-DB", db.Metadata.DatabaseType)
 	})
 
+	t.Run("dataset with sorted keys", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		// json.Marshal and jq -S write the dataset before the metadata
+		inputJSON, err := json.Marshal(map[string]interface{}{
+			"version": "v1",
+			"schema":  map[string]interface{}{"asn": "uint32"},
+			"metadata": map[string]interface{}{
+				"DatabaseType": "Sorted-DB",
+				"Description":  map[string]interface{}{"en": "Sorted Database"},
+			},
+			"dataset": []interface{}{
+				map[string]interface{}{"network": "1.1.1.0/24", "record": map[string]interface{}{"asn": 13335}},
+			},
+		})
+		require.NoError(t, err)
+		inputPath := writeTestJSON(t, dir, "sorted.json", string(inputJSON))
+		outputPath := filepath.Join(dir, "output.mmdb")
+
+		require.NoError(t, GenerateMMDB(&CmdGenerateConfig{
+			InputDataset:   inputPath,
+			OutputDatabase: outputPath,
+		}))
+
+		db, err := maxminddb.Open(outputPath)
+		require.NoError(t, err)
+		defer db.Close()
+
+		assert.Equal(t, "Sorted-DB", db.Metadata.DatabaseType)
+		var record map[string]interface{}
+		require.NoError(t, db.Lookup(net.ParseIP("1.1.1.1"), &record))
+		assert.Equal(t, uint64(13335), record["asn"])
+	})
+
 	t.Run("generation with schema", func(t *testing.T) {
 		t.Parallel()
 		dir := t.TempDir()
@@ -285,6 +399,418 @@ func TestGenerateMMDB(t *testing.T) {
 		assert.NoError(t, db.Verify())
 	})
 
+	t.Run("generation with arrays", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		inputJSON := `{
+			"version": "v1",
+			"schema": {
+				"asns": ["uint32"],
+				"subdivisions": [{"geoname_id": "uint32"}]
+			},
+			"metadata": {
+				"DatabaseType": "Array-DB",
+				"Description": {"en": "Array Database"}
+			},
+			"dataset": [
+				{
+					"network": "1.0.0.0/24",
+					"record": {
+						"asns": [13335, 15169],
+						"subdivisions": [{"iso_code": "CA", "geoname_id": 5332921}],
+						"tags": ["anycast", ["nested"]]
+					}
+				}
+			]
+		}`
+		inputPath := writeTestJSON(t, dir, "input.json", inputJSON)
+		outputPath := filepath.Join(dir, "output.mmdb")
+
+		err := GenerateMMDB(&CmdGenerateConfig{
+			InputDataset:   inputPath,
+			OutputDatabase: outputPath,
+		})
+		require.NoError(t, err)
+
+		db, openErr := maxminddb.Open(outputPath)
+		require.NoError(t, openErr)
+		defer db.Close()
+
+		var record struct {
+			ASNs         []uint32 `maxminddb:"asns"`
+			Subdivisions []struct {
+				GeoNameID uint32 `maxminddb:"geoname_id"`
+				ISOCode   string `maxminddb:"iso_code"`
+			} `maxminddb:"subdivisions"`
+			Tags []interface{} `maxminddb:"tags"`
+		}
+		require.NoError(t, db.Lookup(net.ParseIP("1.0.0.1"), &record))
+		assert.Equal(t, []uint32{13335, 15169}, record.ASNs)
+		require.Len(t, record.Subdivisions, 1)
+		assert.Equal(t, uint32(5332921), record.Subdivisions[0].GeoNameID)
+		assert.Equal(t, "CA", record.Subdivisions[0].ISOCode)
+		assert.Equal(t, []interface{}{"anycast", []interface{}{"nested"}}, record.Tags)
+	})
+
+	t.Run("generation with extended schema types", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		inputJSON := `{
+			"version": "v1",
+			"schema": {
+				"big": "uint64",
+				"huge": "uint128",
+				"ratio": "float32",
+				"hash": "bytes"
+			},
+			"metadata": {
+				"DatabaseType": "Types-DB",
+				"Description": {"en": "Types Database"}
+			},
+			"dataset": [
+				{
+					"network": "1.0.0.0/24",
+					"record": {
+						"big": "18446744073709551615",
+						"huge": "0x0102030405060708090a0b0c0d0e0f10",
+						"ratio": 0.5,
+						"hash": "aGVsbG8="
+					}
+				}
+			]
+		}`
+		inputPath := writeTestJSON(t, dir, "input.json", inputJSON)
+		outputPath := filepath.Join(dir, "output.mmdb")
+
+		err := GenerateMMDB(&CmdGenerateConfig{
+			InputDataset:   inputPath,
+			OutputDatabase: outputPath,
+		})
+		require.NoError(t, err)
+
+		db, openErr := maxminddb.Open(outputPath)
+		require.NoError(t, openErr)
+		defer db.Close()
+
+		var record struct {
+			Big   uint64  `maxminddb:"big"`
+			Huge  big.Int `maxminddb:"huge"`
+			Ratio float32 `maxminddb:"ratio"`
+			Hash  []byte  `maxminddb:"hash"`
+		}
+		require.NoError(t, db.Lookup(net.ParseIP("1.0.0.1"), &record))
+		assert.Equal(t, uint64(18446744073709551615), record.Big)
+		assert.Equal(t, "102030405060708090a0b0c0d0e0f10", record.Huge.Text(16))
+		assert.Equal(t, float32(0.5), record.Ratio)
+		assert.Equal(t, []byte("hello"), record.Hash)
+	})
+
+	t.Run("strict mode reports every schema violation", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		inputJSON := `{
+			"version": "v1",
+			"schema": {
+				"asn": "uint32",
+				"country": {"geoname_id": "uint32"}
+			},
+			"metadata": {
+				"DatabaseType": "Strict-DB",
+				"Description": {"en": "Strict Database"}
+			},
+			"dataset": [
+				{"network": "1.0.0.0/24", "record": {"asn": "not-a-number"}},
+				{"network": "2.0.0.0/24", "record": {"asn": 1}},
+				{"network": "3.0.0.0/24", "record": {"country": {"geoname_id": -5}}}
+			]
+		}`
+		inputPath := writeTestJSON(t, dir, "input.json", inputJSON)
+		outputPath := filepath.Join(dir, "output.mmdb")
+
+		err := GenerateMMDB(&CmdGenerateConfig{
+			InputDataset:   inputPath,
+			OutputDatabase: outputPath,
+			Strict:         true,
+		})
+		require.Error(t, err)
+
+		var violations mmdb.SchemaViolations
+		require.ErrorAs(t, err, &violations)
+		require.Len(t, violations, 2)
+		assert.Equal(t, 1, violations[0].Position)
+		assert.Equal(t, "1.0.0.0/24", violations[0].Network)
+		assert.Equal(t, "asn", violations[0].Path)
+		assert.Equal(t, 3, violations[1].Position)
+		assert.Equal(t, "country.geoname_id", violations[1].Path)
+
+		_, statErr := os.Stat(outputPath)
+		assert.True(t, os.IsNotExist(statErr))
+	})
+
+	t.Run("generation with IP ranges and bare IPs", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		inputJSON := `{
+			"version": "v1",
+			"metadata": {
+				"DatabaseType": "Range-DB",
+				"Description": {"en": "Range Database"}
+			},
+			"dataset": [
+				{"network": "5.0.0.5-5.0.0.20", "record": {"name": "range"}},
+				{"network": "5.1.0.1", "record": {"name": "ipv4"}},
+				{"network": "2a00::1", "record": {"name": "ipv6"}}
+			]
+		}`
+		inputPath := writeTestJSON(t, dir, "input.json", inputJSON)
+		outputPath := filepath.Join(dir, "output.mmdb")
+
+		require.NoError(t, GenerateMMDB(&CmdGenerateConfig{
+			InputDataset:   inputPath,
+			OutputDatabase: outputPath,
+			Verbose:        true,
+		}))
+
+		db, err := maxminddb.Open(outputPath)
+		require.NoError(t, err)
+		defer db.Close()
+
+		lookups := map[string]string{
+			"5.0.0.5":  "range",
+			"5.0.0.12": "range",
+			"5.0.0.20": "range",
+			"5.1.0.1":  "ipv4",
+			"2a00::1":  "ipv6",
+		}
+		for ip, want := range lookups {
+			var record map[string]interface{}
+			require.NoError(t, db.Lookup(net.ParseIP(ip), &record))
+			assert.Equal(t, want, record["name"], "lookup of %s", ip)
+		}
+
+		for _, ip := range []string{"5.0.0.4", "5.0.0.21", "5.1.0.2"} {
+			var record map[string]interface{}
+			require.NoError(t, db.Lookup(net.ParseIP(ip), &record))
+			assert.Nil(t, record, "lookup of %s", ip)
+		}
+	})
+
+	t.Run("invalid IP range", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		inputJSON := `{
+			"metadata": {"DatabaseType": "Range-DB", "Description": {"en": "Range Database"}},
+			"dataset": [{"network": "10.0.0.9-10.0.0.1", "record": {"name": "range"}}]
+		}`
+		inputPath := writeTestJSON(t, dir, "input.json", inputJSON)
+
+		err := GenerateMMDB(&CmdGenerateConfig{
+			InputDataset:   inputPath,
+			OutputDatabase: filepath.Join(dir, "output.mmdb"),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "ends before it starts")
+	})
+
+	t.Run("reproducible output", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		inputJSON := `{
+			"version": "v1",
+			"metadata": {
+				"DatabaseType": "Reproducible-DB",
+				"Description": {"en": "Reproducible Database", "de": "Reproduzierbare Datenbank", "fr": "Base reproductible"},
+				"BuildEpoch": 1700000000
+			},
+			"dataset": [
+				{"network": "1.1.1.0/24", "record": {"a": 1, "b": "x", "c": {"d": true, "e": [1, 2], "f": "y"}, "g": 2.5}},
+				{"network": "8.8.8.0/24", "record": {"h": "z", "i": {"j": {"k": "l"}}, "m": 3}}
+			]
+		}`
+		inputPath := writeTestJSON(t, dir, "input.json", inputJSON)
+
+		var outputs [][]byte
+		for _, name := range []string{"first.mmdb", "second.mmdb"} {
+			outputPath := filepath.Join(dir, name)
+			require.NoError(t, GenerateMMDB(&CmdGenerateConfig{
+				InputDataset:   inputPath,
+				OutputDatabase: outputPath,
+				Verify:         true,
+			}))
+			content, err := os.ReadFile(outputPath)
+			require.NoError(t, err)
+			outputs = append(outputs, content)
+		}
+		assert.Equal(t, outputs[0], outputs[1])
+
+		db, err := maxminddb.Open(filepath.Join(dir, "first.mmdb"))
+		require.NoError(t, err)
+		defer db.Close()
+		assert.Equal(t, uint(1700000000), db.Metadata.BuildEpoch)
+	})
+
+	t.Run("conflict policies", func(t *testing.T) {
+		t.Parallel()
+		inputJSON := `{
+			"version": "v1",
+			"metadata": {
+				"DatabaseType": "Conflict-DB",
+				"Description": {"en": "Conflict Database"}
+			},
+			"dataset": [
+				{"network": "5.0.0.0/16", "record": {"name": "wide", "geo": {"country": "NL", "city": "Amsterdam"}}},
+				{"network": "5.0.1.0/24", "record": {"asn": 1, "geo": {"city": "Rotterdam"}}}
+			]
+		}`
+
+		tests := []struct {
+			policy string
+			want   map[string]interface{}
+		}{
+			{
+				policy: OnConflictReplace,
+				want:   map[string]interface{}{"asn": float64(1), "geo": map[string]interface{}{"city": "Rotterdam"}},
+			},
+			{
+				policy: OnConflictKeepExisting,
+				want:   map[string]interface{}{"name": "wide", "geo": map[string]interface{}{"country": "NL", "city": "Amsterdam"}},
+			},
+			{
+				policy: OnConflictTopLevelMerge,
+				want:   map[string]interface{}{"name": "wide", "asn": float64(1), "geo": map[string]interface{}{"city": "Rotterdam"}},
+			},
+			{
+				policy: OnConflictDeepMerge,
+				want:   map[string]interface{}{"name": "wide", "asn": float64(1), "geo": map[string]interface{}{"country": "NL", "city": "Rotterdam"}},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.policy, func(t *testing.T) {
+				t.Parallel()
+				dir := t.TempDir()
+				inputPath := writeTestJSON(t, dir, "input.json", inputJSON)
+				outputPath := filepath.Join(dir, "output.mmdb")
+
+				require.NoError(t, GenerateMMDB(&CmdGenerateConfig{
+					InputDataset:   inputPath,
+					OutputDatabase: outputPath,
+					OnConflict:     tt.policy,
+				}))
+
+				db, err := maxminddb.Open(outputPath)
+				require.NoError(t, err)
+				defer db.Close()
+
+				var record map[string]interface{}
+				require.NoError(t, db.Lookup(net.ParseIP("5.0.1.1"), &record))
+				assert.Equal(t, tt.want, record)
+
+				// The rest of the wide network keeps its record
+				require.NoError(t, db.Lookup(net.ParseIP("5.0.2.1"), &record))
+				assert.Equal(t, "wide", record["name"])
+			})
+		}
+
+		t.Run(OnConflictError, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			inputPath := writeTestJSON(t, dir, "input.json", inputJSON)
+
+			err := GenerateMMDB(&CmdGenerateConfig{
+				InputDataset:   inputPath,
+				OutputDatabase: filepath.Join(dir, "output.mmdb"),
+				OnConflict:     OnConflictError,
+			})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "record 2 (network: 5.0.1.0/24) overlaps")
+			assert.Contains(t, err.Error(), "5.0.0.0/16 (record 1) and 5.0.1.0/24 (record 2)")
+		})
+
+		t.Run("unsupported policy", func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			inputPath := writeTestJSON(t, dir, "input.json", inputJSON)
+
+			err := GenerateMMDB(&CmdGenerateConfig{
+				InputDataset:   inputPath,
+				OutputDatabase: filepath.Join(dir, "output.mmdb"),
+				OnConflict:     "merge",
+			})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "unsupported conflict policy 'merge'")
+		})
+	})
+
+	t.Run("generation from CSV", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		inputPath := writeTestJSON(t, dir, "input.csv", "first,last,cc,asn\n1.0.0.0,1.0.0.255,AU,13335\n2.0.0.0,2.0.1.255,US,15169\n")
+		mappingPath := writeTestJSON(t, dir, "mapping.json", `{
+			"start_ip": "first",
+			"end_ip": "last",
+			"metadata": {"DatabaseType": "CSV-DB", "Description": {"en": "CSV Database"}},
+			"columns": {
+				"cc": {"path": "country.iso_code"},
+				"asn": {"path": "asn", "type": "uint32"}
+			}
+		}`)
+		outputPath := filepath.Join(dir, "output.mmdb")
+
+		require.NoError(t, GenerateMMDB(&CmdGenerateConfig{
+			InputDataset:   inputPath,
+			OutputDatabase: outputPath,
+			Mapping:        mappingPath,
+		}))
+
+		db, err := maxminddb.Open(outputPath)
+		require.NoError(t, err)
+		defer db.Close()
+
+		var record map[string]interface{}
+		require.NoError(t, db.Lookup(net.ParseIP("2.0.1.10"), &record))
+		assert.Equal(t, map[string]interface{}{
+			"asn":     uint64(15169),
+			"country": map[string]interface{}{"iso_code": "US"},
+		}, record)
+	})
+
+	t.Run("CSV strict mode reports unparsable cells", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		inputPath := writeTestJSON(t, dir, "input.csv", "network,asn\n1.0.0.0/24,AS13335\n")
+		mappingPath := writeTestJSON(t, dir, "mapping.json", `{
+			"network": "network",
+			"metadata": {"DatabaseType": "CSV-DB", "Description": {"en": "CSV Database"}},
+			"columns": {"asn": {"path": "asn", "type": "uint32"}}
+		}`)
+
+		err := GenerateMMDB(&CmdGenerateConfig{
+			InputDataset:   inputPath,
+			OutputDatabase: filepath.Join(dir, "output.mmdb"),
+			Mapping:        mappingPath,
+			Strict:         true,
+		})
+
+		var violations mmdb.SchemaViolations
+		require.ErrorAs(t, err, &violations)
+		require.Len(t, violations, 1)
+		assert.Equal(t, "asn", violations[0].Path)
+	})
+
+	t.Run("CSV without mapping", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		inputPath := writeTestJSON(t, dir, "input.csv", "network,asn\n1.0.0.0/24,1\n")
+
+		err := GenerateMMDB(&CmdGenerateConfig{
+			InputDataset:   inputPath,
+			OutputDatabase: filepath.Join(dir, "output.mmdb"),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "mapping file is required")
+	})
+
 	t.Run("non-existent input file", func(t *testing.T) {
 		t.Parallel()
 		dir := t.TempDir()
@@ -308,6 +834,34 @@ func TestGenerateMMDB(t *testing.T) {
 		assert.Error(t, err)
 	})
 
+	t.Run("malformed dataset reports every problem", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		inputJSON := `{
+			"metadata": {"DatabaseType": 1, "Description": "Test", "IPVersion": "6"},
+			"dataset": [
+				{"network": "5.0.0.0/24", "record": {"name": "valid"}},
+				{"network": "5.0.1.0/24", "record": "invalid"}
+			]
+		}`
+		inputPath := writeTestJSON(t, dir, "input.json", inputJSON)
+		outputPath := filepath.Join(dir, "output.mmdb")
+
+		err := GenerateMMDB(&CmdGenerateConfig{
+			InputDataset:   inputPath,
+			OutputDatabase: outputPath,
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "4 problem(s) found in the dataset")
+		assert.Contains(t, err.Error(), "/metadata/DatabaseType: must be a string")
+		assert.Contains(t, err.Error(), "/metadata/Description: must be an object")
+		assert.Contains(t, err.Error(), "/metadata/IPVersion: must be 4 or 6")
+		assert.Contains(t, err.Error(), "/dataset/1/record: must be an object")
+
+		_, statErr := os.Stat(outputPath)
+		assert.True(t, os.IsNotExist(statErr))
+	})
+
 	t.Run("unsupported version", func(t *testing.T) {
 		t.Parallel()
 		dir := t.TempDir()
@@ -326,7 +880,7 @@ func TestGenerateMMDB(t *testing.T) {
 		}
 		err := GenerateMMDB(cfg)
 		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "unsupported dataset version")
+		assert.Contains(t, err.Error(), "/version: unsupported version 'v2'")
 	})
 
 	t.Run("verbose mode", func(t *testing.T) {
@@ -363,3 +917,29 @@ func TestGenerateMMDB(t *testing.T) {
 		assert.NoError(t, db.Verify())
 	})
 }
+
+func TestGenerateMMDBStdin(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := writeTestJSON(t, dir, "input.ndjson", `{"version":"v1","metadata":{"DatabaseType":"Stdin-DB","Description":{"en":"Stdin"}}}
+{"network":"1.1.1.0/24","record":{"country":"AU"}}
+`)
+	outputPath := filepath.Join(dir, "output.mmdb")
+
+	stdin, err := os.Open(inputPath)
+	require.NoError(t, err)
+	defer stdin.Close()
+
+	oldStdin := os.Stdin
+	os.Stdin = stdin
+	defer func() { os.Stdin = oldStdin }()
+
+	require.NoError(t, GenerateMMDB(&CmdGenerateConfig{InputDataset: "-", OutputDatabase: outputPath}))
+
+	db, err := maxminddb.Open(outputPath)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, "Stdin-DB", db.Metadata.DatabaseType)
+
+	err = GenerateMMDB(&CmdGenerateConfig{InputDataset: inputPath, OutputDatabase: "-", Verify: true})
+	assert.ErrorContains(t, err, "cannot be verified")
+}
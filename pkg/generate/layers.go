@@ -0,0 +1,158 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+
+	"github.com/InfraZ/mmdb-cli/pkg/dataset"
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
+	"github.com/InfraZ/mmdb-cli/pkg/progress"
+	"github.com/InfraZ/mmdb-cli/pkg/validate"
+)
+
+// Methods merging the records of a dataset layer into the networks inserted
+// by the layers before it, as in the entries of update datasets.
+const (
+	MethodRemove        = "remove"
+	MethodReplace       = "replace"
+	MethodTopLevelMerge = "top_level_merge"
+	MethodDeepMerge     = "deep_merge"
+)
+
+// Layer is a dataset of a layered generation. The first layer is inserted
+// like a single dataset, and every following layer is merged into the
+// networks inserted before it.
+type Layer struct {
+	Reader *dataset.Reader
+
+	// Method is one of the Method constants. When it is empty, the "method"
+	// of the dataset header is used, then Options.LayerMethod.
+	Method string
+}
+
+// method returns the method merging the records of the layer.
+func (l Layer) method(opts Options) string {
+	if l.Method != "" {
+		return l.Method
+	}
+	if method, ok := l.Reader.Header["method"].(string); ok && method != "" {
+		return method
+	}
+	if opts.LayerMethod != "" {
+		return opts.LayerMethod
+	}
+	return MethodDeepMerge
+}
+
+// layerInserter returns the inserter function merging value with method.
+func layerInserter(method string, value mmdbtype.DataType) (inserter.Func, error) {
+	switch method {
+	case MethodRemove:
+		return inserter.Remove, nil
+	case MethodReplace:
+		return inserter.ReplaceWith(value), nil
+	case MethodTopLevelMerge:
+		return inserter.TopLevelMergeWith(value), nil
+	case "", MethodDeepMerge:
+		return inserter.DeepMergeWith(value), nil
+	default:
+		return nil, fmt.Errorf("unsupported layer method '%s' (supported: %s)", method, strings.Join(validate.UpdateMethods, ", "))
+	}
+}
+
+// layerName names the layer at index in messages, a single dataset being
+// named as such.
+func layerName(index int, layers int) string {
+	if layers == 1 {
+		return "dataset"
+	}
+	return fmt.Sprintf("dataset layer %d", index+1)
+}
+
+// layerMetadata returns the metadata of the first layer, whose fields are
+// overridden by the metadata of the following layers.
+func layerMetadata(layers []Layer, reporter progress.Reporter) (map[string]interface{}, error) {
+	first, ok := layers[0].Reader.Header["metadata"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("no 'metadata' object found in the %s", layerName(0, len(layers)))
+	}
+
+	metadata := make(map[string]interface{}, len(first))
+	for key, value := range first {
+		metadata[key] = value
+	}
+
+	for i, layer := range layers[1:] {
+		override, ok := layer.Reader.Header["metadata"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key, value := range override {
+			metadata[key] = value
+		}
+		reporter.Step(fmt.Sprintf("Metadata overridden by %s", layerName(i+1, len(layers))))
+	}
+
+	return metadata, nil
+}
+
+// layerSchema merges the schemas of the layers. It reports whether no layer
+// has a schema, in which case the default conversion is used, and warns
+// about the keys declared with different types, which are converted with the
+// default conversion.
+func layerSchema(layers []Layer, result *Result, reporter progress.Reporter) (map[string]interface{}, bool) {
+	builder := mmdb.NewSchemaBuilder()
+	found := false
+
+	for i, layer := range layers {
+		name := layerName(i, len(layers))
+
+		schemaInterface, exists := layer.Reader.Header["schema"]
+		if !exists {
+			continue
+		}
+		schema, ok := schemaInterface.(map[string]interface{})
+		if !ok {
+			result.warn(reporter, "Schema field of the %s is not a valid object and is ignored", name)
+			continue
+		}
+
+		found = true
+		builder.AddSchema(schema)
+		reporter.Step(fmt.Sprintf("Using dynamic schema from %s: %v", name, schema))
+	}
+
+	if !found {
+		if len(layers) > 1 {
+			result.warn(reporter, "No schema found in the dataset layers, using default schema")
+		} else {
+			result.warn(reporter, "No schema found in the dataset, using default schema")
+		}
+		return nil, true
+	}
+
+	for _, conflict := range builder.Conflicts() {
+		result.warn(reporter, "Key %s is declared with incompatible types across the dataset layers and uses the default conversion", conflict)
+	}
+
+	return builder.Schema(), false
+}
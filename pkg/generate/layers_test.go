@@ -0,0 +1,220 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generate
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/InfraZ/mmdb-cli/pkg/dataset"
+	"github.com/maxmind/mmdbwriter"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const baseLayer = `{
+	"version": "v1",
+	"schema": {"asn": "uint32", "tags": ["string"]},
+	"metadata": {
+		"DatabaseType": "Layered-DB",
+		"Description": {"en": "Base"},
+		"IPVersion": 6,
+		"Languages": ["en"],
+		"RecordSize": 24
+	},
+	"dataset": [
+		{"network": "1.1.1.0/24", "record": {"asn": 13335, "name": "base", "location": {"city": "Sydney"}}},
+		{"network": "8.8.8.0/24", "record": {"asn": 15169, "name": "base"}}
+	]
+}`
+
+func newLayer(t *testing.T, content string) Layer {
+	t.Helper()
+	reader, err := dataset.NewReader(strings.NewReader(content))
+	require.NoError(t, err)
+	return Layer{Reader: reader}
+}
+
+func lookupLayered(t *testing.T, db *maxminddb.Reader, ip string) map[string]interface{} {
+	t.Helper()
+	var record map[string]interface{}
+	require.NoError(t, db.Lookup(net.ParseIP(ip), &record))
+	return record
+}
+
+func TestBuildLayers(t *testing.T) {
+	t.Parallel()
+
+	t.Run("merges the layers with their method", func(t *testing.T) {
+		t.Parallel()
+
+		reporter := &recorder{}
+		tree, result, err := BuildLayers(context.Background(), []Layer{
+			newLayer(t, baseLayer),
+			newLayer(t, `{
+				"schema": {"asn": "uint32", "tags": ["string"]},
+				"dataset": [{"network": "1.1.1.0/24", "record": {"location": {"country": "AU"}, "tags": ["cdn"]}}]
+			}`),
+			newLayer(t, `{
+				"method": "replace",
+				"metadata": {"Description": {"en": "Corrected"}},
+				"dataset": [{"network": "8.8.8.0/25", "record": {"name": "corrected"}}]
+			}`),
+		}, Options{BuildEpoch: 1700000000, Progress: reporter})
+		require.NoError(t, err)
+
+		assert.Equal(t, 4, result.Records)
+		assert.Zero(t, result.OverlappingRecords)
+		assert.Empty(t, result.Warnings)
+		assert.Contains(t, reporter.steps, "Applying dataset layer 2 with method deep_merge")
+		assert.Contains(t, reporter.steps, "Applying dataset layer 3 with method replace")
+
+		db := writeTree(t, tree)
+		assert.Equal(t, "Layered-DB", db.Metadata.DatabaseType)
+		assert.Equal(t, map[string]string{"en": "Corrected"}, db.Metadata.Description)
+
+		assert.Equal(t, map[string]interface{}{
+			"asn":      uint64(13335),
+			"name":     "base",
+			"location": map[string]interface{}{"city": "Sydney", "country": "AU"},
+			"tags":     []interface{}{"cdn"},
+		}, lookupLayered(t, db, "1.1.1.1"))
+		assert.Equal(t, map[string]interface{}{"name": "corrected"}, lookupLayered(t, db, "8.8.8.8"))
+		assert.Equal(t, map[string]interface{}{"asn": uint64(15169), "name": "base"}, lookupLayered(t, db, "8.8.8.200"))
+	})
+
+	t.Run("layer method option and removal", func(t *testing.T) {
+		t.Parallel()
+
+		tree, _, err := BuildLayers(context.Background(), []Layer{
+			newLayer(t, baseLayer),
+			newLayer(t, `{"dataset": [{"network": "8.8.8.0/24", "record": {}}]}`),
+		}, Options{LayerMethod: MethodRemove})
+		require.NoError(t, err)
+
+		db := writeTree(t, tree)
+		assert.Nil(t, lookupLayered(t, db, "8.8.8.8"))
+		assert.NotNil(t, lookupLayered(t, db, "1.1.1.1"))
+	})
+
+	t.Run("reports conflicting schemas", func(t *testing.T) {
+		t.Parallel()
+
+		_, result, err := BuildLayers(context.Background(), []Layer{
+			newLayer(t, baseLayer),
+			newLayer(t, `{"schema": {"asn": "string"}, "dataset": [{"network": "9.9.9.0/24", "record": {"asn": "AS19281"}}]}`),
+		}, Options{})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"Key asn is declared with incompatible types across the dataset layers and uses the default conversion"}, result.Warnings)
+	})
+
+	t.Run("errors are prefixed with the layer", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := BuildLayers(context.Background(), []Layer{
+			newLayer(t, baseLayer),
+			{Reader: newLayer(t, `{"dataset": []}`).Reader, Method: "merge"},
+		}, Options{})
+		assert.ErrorContains(t, err, "dataset layer 2: unsupported layer method 'merge'")
+
+		_, _, err = BuildLayers(context.Background(), []Layer{
+			newLayer(t, `{"dataset": []}`),
+			newLayer(t, baseLayer),
+		}, Options{})
+		assert.ErrorContains(t, err, "no 'metadata' object found in the dataset layer 1")
+
+		_, _, err = BuildLayers(context.Background(), nil, Options{})
+		assert.Error(t, err)
+
+		_, _, err = BuildLayers(context.Background(), []Layer{newLayer(t, baseLayer)}, Options{LayerMethod: "merge"})
+		assert.Error(t, err)
+	})
+}
+
+func writeTree(t *testing.T, tree *mmdbwriter.Tree) *maxminddb.Reader {
+	t.Helper()
+	var output bytes.Buffer
+	_, err := tree.WriteTo(&output)
+	require.NoError(t, err)
+	db, err := maxminddb.FromBytes(output.Bytes())
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestGenerateMMDBLayers(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	layersDir := filepath.Join(dir, "layers")
+	require.NoError(t, os.Mkdir(layersDir, 0755))
+	basePath := writeTestJSON(t, dir, "base.json", baseLayer)
+	writeTestJSON(t, layersDir, "20-corrections.ndjson", `{"method": "replace"}
+{"network": "8.8.8.0/24", "record": {"name": "corrected"}}
+`)
+	writeTestJSON(t, layersDir, "10-internal.json", `{"dataset": [{"network": "8.8.8.0/24", "record": {"internal": true}}]}`)
+	writeTestJSON(t, layersDir, "README.md", "not a dataset")
+
+	t.Run("applies the files of a directory in order", func(t *testing.T) {
+		t.Parallel()
+
+		outputPath := filepath.Join(t.TempDir(), "output.mmdb")
+		require.NoError(t, GenerateMMDB(&CmdGenerateConfig{
+			InputDataset:   basePath,
+			Layers:         []string{layersDir},
+			OutputDatabase: outputPath,
+			Verify:         true,
+		}))
+
+		db, err := maxminddb.Open(outputPath)
+		require.NoError(t, err)
+		defer db.Close()
+
+		assert.Equal(t, map[string]interface{}{"name": "corrected"}, lookupLayered(t, db, "8.8.8.8"))
+		assert.Equal(t, "base", lookupLayered(t, db, "1.1.1.1")["name"])
+	})
+
+	t.Run("validates the layers", func(t *testing.T) {
+		t.Parallel()
+
+		invalidPath := writeTestJSON(t, t.TempDir(), "invalid.json", `{"method": "merge", "dataset": []}`)
+		err := GenerateMMDB(&CmdGenerateConfig{
+			InputDataset:   basePath,
+			Layers:         []string{invalidPath},
+			OutputDatabase: filepath.Join(t.TempDir(), "output.mmdb"),
+		})
+		assert.ErrorContains(t, err, "/method")
+	})
+
+	t.Run("rejects directories without datasets", func(t *testing.T) {
+		t.Parallel()
+
+		err := GenerateMMDB(&CmdGenerateConfig{
+			InputDataset:   basePath,
+			Layers:         []string{t.TempDir()},
+			OutputDatabase: filepath.Join(t.TempDir(), "output.mmdb"),
+		})
+		assert.ErrorContains(t, err, "holds no dataset file")
+	})
+}
@@ -0,0 +1,647 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/InfraZ/mmdb-cli/internal/files"
+	"github.com/InfraZ/mmdb-cli/pkg/generate"
+	"github.com/InfraZ/mmdb-cli/pkg/progress"
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+type CmdImportGeoIP2CSVConfig struct {
+	BlocksFiles    []string
+	LocationsFiles []string
+	OutputDatabase string
+
+	// DatabaseType is written to the metadata, the empty string uses the
+	// type of the distribution of the first blocks file, see
+	// detectDatabaseType
+	DatabaseType string
+
+	Description string
+	Verbose     bool
+
+	DisableIPv4Aliasing     bool
+	IncludeReservedNetworks bool
+}
+
+// location is a row of the GeoIP2 locations CSV, with the names of every
+// locale file merged together.
+type location struct {
+	continentCode     string
+	continentNames    map[string]string
+	countryISOCode    string
+	countryNames      map[string]string
+	isInEuropeanUnion bool
+	subdivisions      []subdivision
+	cityNames         map[string]string
+	metroCode         string
+	timeZone          string
+}
+
+type subdivision struct {
+	isoCode string
+	names   map[string]string
+}
+
+// locations indexes the locations by geoname_id. Countries and continents
+// are also indexed by code, since the locations of cities only carry the
+// codes of the country and continent they belong to.
+type locations struct {
+	byID        map[uint32]*location
+	countries   map[string]uint32
+	continents  map[string]uint32
+	localeCodes []string
+}
+
+// csvFile reads a CSV file whose first row holds the column names.
+type csvFile struct {
+	path    string
+	file    *os.File
+	reader  *csv.Reader
+	columns map[string]int
+	row     []string
+	line    int
+}
+
+func openCSVFile(path string) (*csvFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read column names of %s: %w", path, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		columns[column] = i
+	}
+
+	return &csvFile{path: path, file: file, reader: reader, columns: columns, line: 1}, nil
+}
+
+// next reads the next row, returning io.EOF after the last one.
+func (f *csvFile) next() error {
+	row, err := f.reader.Read()
+	if err == io.EOF {
+		return err
+	}
+	f.line++
+	if err != nil {
+		return fmt.Errorf("invalid row %d in %s: %w", f.line, f.path, err)
+	}
+	f.row = row
+	return nil
+}
+
+func (f *csvFile) has(column string) bool {
+	_, exists := f.columns[column]
+	return exists
+}
+
+// field returns the value of column in the current row, or an empty string
+// when the file has no such column.
+func (f *csvFile) field(column string) string {
+	if i, exists := f.columns[column]; exists {
+		return f.row[i]
+	}
+	return ""
+}
+
+func (f *csvFile) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("row %d in %s: %s", f.line, f.path, fmt.Sprintf(format, args...))
+}
+
+func (f *csvFile) close() {
+	f.file.Close()
+}
+
+func readLocations(paths []string) (*locations, error) {
+	locs := &locations{
+		byID:       map[uint32]*location{},
+		countries:  map[string]uint32{},
+		continents: map[string]uint32{},
+	}
+	localeCodes := map[string]bool{}
+
+	for _, path := range paths {
+		slog.Info("Reading locations", "path", path)
+
+		file, err := openCSVFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, column := range []string{"geoname_id", "locale_code"} {
+			if !file.has(column) {
+				file.close()
+				return nil, fmt.Errorf("%s is not a GeoIP2 locations file: missing column %s", path, column)
+			}
+		}
+
+		for {
+			if err := file.next(); err == io.EOF {
+				break
+			} else if err != nil {
+				file.close()
+				return nil, err
+			}
+
+			id, err := strconv.ParseUint(file.field("geoname_id"), 10, 32)
+			if err != nil {
+				file.close()
+				return nil, file.errorf("invalid geoname_id %q", file.field("geoname_id"))
+			}
+			geonameID := uint32(id)
+
+			locale := file.field("locale_code")
+			localeCodes[locale] = true
+
+			loc, exists := locs.byID[geonameID]
+			if !exists {
+				loc = &location{
+					continentCode:     file.field("continent_code"),
+					continentNames:    map[string]string{},
+					countryISOCode:    file.field("country_iso_code"),
+					countryNames:      map[string]string{},
+					isInEuropeanUnion: file.field("is_in_european_union") == "1",
+					cityNames:         map[string]string{},
+					metroCode:         file.field("metro_code"),
+					timeZone:          file.field("time_zone"),
+				}
+				locs.byID[geonameID] = loc
+			}
+
+			setName(loc.continentNames, locale, file.field("continent_name"))
+			setName(loc.countryNames, locale, file.field("country_name"))
+			setName(loc.cityNames, locale, file.field("city_name"))
+
+			for i, prefix := range []string{"subdivision_1", "subdivision_2"} {
+				isoCode := file.field(prefix + "_iso_code")
+				name := file.field(prefix + "_name")
+				if isoCode == "" && name == "" {
+					continue
+				}
+				// The subdivisions are listed from the largest, a second
+				// one cannot come without the first
+				if len(loc.subdivisions) < i {
+					file.close()
+					return nil, file.errorf("%s without subdivision_1 for geoname_id %d", prefix, geonameID)
+				}
+				if len(loc.subdivisions) == i {
+					loc.subdivisions = append(loc.subdivisions, subdivision{isoCode: isoCode, names: map[string]string{}})
+				}
+				setName(loc.subdivisions[i].names, locale, name)
+			}
+		}
+
+		file.close()
+	}
+
+	// Locations without a city or subdivision describe a country, and the
+	// ones without a country describe a continent. The IDs are sorted so the
+	// lowest one wins when several locations describe the same country.
+	geonameIDs := make([]uint32, 0, len(locs.byID))
+	for geonameID := range locs.byID {
+		geonameIDs = append(geonameIDs, geonameID)
+	}
+	sort.Slice(geonameIDs, func(i, j int) bool { return geonameIDs[i] < geonameIDs[j] })
+
+	for _, geonameID := range geonameIDs {
+		loc := locs.byID[geonameID]
+		if loc.countryISOCode != "" && len(loc.cityNames) == 0 && len(loc.subdivisions) == 0 {
+			if _, exists := locs.countries[loc.countryISOCode]; !exists {
+				locs.countries[loc.countryISOCode] = geonameID
+			}
+		} else if loc.countryISOCode == "" && loc.continentCode != "" {
+			if _, exists := locs.continents[loc.continentCode]; !exists {
+				locs.continents[loc.continentCode] = geonameID
+			}
+		}
+	}
+
+	for locale := range localeCodes {
+		locs.localeCodes = append(locs.localeCodes, locale)
+	}
+	sort.Strings(locs.localeCodes)
+
+	return locs, nil
+}
+
+func setName(names map[string]string, locale string, name string) {
+	if name != "" {
+		names[locale] = name
+	}
+}
+
+func namesMap(names map[string]string) mmdbtype.Map {
+	namesMap := make(mmdbtype.Map, len(names))
+	for locale, name := range names {
+		namesMap[mmdbtype.String(locale)] = mmdbtype.String(name)
+	}
+	return namesMap
+}
+
+// countryOf returns the country fields of loc.
+func countryOf(loc *location) mmdbtype.Map {
+	country := mmdbtype.Map{}
+	if loc.countryISOCode != "" {
+		country["iso_code"] = mmdbtype.String(loc.countryISOCode)
+	}
+	if len(loc.countryNames) > 0 {
+		country["names"] = namesMap(loc.countryNames)
+	}
+	if loc.isInEuropeanUnion {
+		country["is_in_european_union"] = mmdbtype.Bool(true)
+	}
+	return country
+}
+
+// countryRecord returns the country record of geonameID, as used for the
+// registered and represented countries.
+func (l *locations) countryRecord(geonameID uint32) (mmdbtype.Map, bool) {
+	loc, exists := l.byID[geonameID]
+	if !exists {
+		return nil, false
+	}
+
+	country := countryOf(loc)
+	country["geoname_id"] = mmdbtype.Uint32(geonameID)
+	return country, true
+}
+
+// addLocation adds the continent, country, subdivisions, city and location
+// fields of geonameID to record.
+func (l *locations) addLocation(record mmdbtype.Map, geonameID uint32) bool {
+	loc, exists := l.byID[geonameID]
+	if !exists {
+		return false
+	}
+
+	if loc.continentCode != "" {
+		continent := mmdbtype.Map{"code": mmdbtype.String(loc.continentCode)}
+		if continentID, exists := l.continents[loc.continentCode]; exists {
+			continent["geoname_id"] = mmdbtype.Uint32(continentID)
+		}
+		if len(loc.continentNames) > 0 {
+			continent["names"] = namesMap(loc.continentNames)
+		}
+		record["continent"] = continent
+	}
+
+	if loc.countryISOCode != "" {
+		// The geoname_id of the country is only known when the locations
+		// have a row for the country itself
+		if countryID, exists := l.countries[loc.countryISOCode]; exists {
+			record["country"], _ = l.countryRecord(countryID)
+		} else {
+			record["country"] = countryOf(loc)
+		}
+	}
+
+	if len(loc.subdivisions) > 0 {
+		subdivisions := make(mmdbtype.Slice, 0, len(loc.subdivisions))
+		for _, sub := range loc.subdivisions {
+			subdivisionRecord := mmdbtype.Map{}
+			if sub.isoCode != "" {
+				subdivisionRecord["iso_code"] = mmdbtype.String(sub.isoCode)
+			}
+			if len(sub.names) > 0 {
+				subdivisionRecord["names"] = namesMap(sub.names)
+			}
+			subdivisions = append(subdivisions, subdivisionRecord)
+		}
+		record["subdivisions"] = subdivisions
+	}
+
+	if len(loc.cityNames) > 0 {
+		record["city"] = mmdbtype.Map{
+			"geoname_id": mmdbtype.Uint32(geonameID),
+			"names":      namesMap(loc.cityNames),
+		}
+	}
+
+	locationRecord := mmdbtype.Map{}
+	if loc.timeZone != "" {
+		locationRecord["time_zone"] = mmdbtype.String(loc.timeZone)
+	}
+	if loc.metroCode != "" {
+		if metroCode, err := strconv.ParseUint(loc.metroCode, 10, 16); err == nil {
+			locationRecord["metro_code"] = mmdbtype.Uint16(metroCode)
+		}
+	}
+	if len(locationRecord) > 0 {
+		record["location"] = locationRecord
+	}
+
+	return true
+}
+
+// blockRecord builds the GeoIP2 record of the current row of a blocks file.
+// ASN blocks carry the autonomous system fields, the other blocks reference
+// locations by geoname_id, and the references missing from the locations are
+// reported as warnings.
+func blockRecord(file *csvFile, locs *locations, reporter progress.Reporter) (mmdbtype.Map, error) {
+	record := mmdbtype.Map{}
+
+	if file.has("autonomous_system_number") {
+		if asn := file.field("autonomous_system_number"); asn != "" {
+			number, err := strconv.ParseUint(asn, 10, 32)
+			if err != nil {
+				return nil, file.errorf("invalid autonomous_system_number %q", asn)
+			}
+			record["autonomous_system_number"] = mmdbtype.Uint32(number)
+		}
+		if organization := file.field("autonomous_system_organization"); organization != "" {
+			record["autonomous_system_organization"] = mmdbtype.String(organization)
+		}
+		return record, nil
+	}
+
+	for _, column := range []string{"geoname_id", "registered_country_geoname_id", "represented_country_geoname_id"} {
+		value := file.field(column)
+		if value == "" {
+			continue
+		}
+
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, file.errorf("invalid %s %q", column, value)
+		}
+		geonameID := uint32(id)
+
+		var found bool
+		switch column {
+		case "geoname_id":
+			found = locs.addLocation(record, geonameID)
+		default:
+			var country mmdbtype.Map
+			if country, found = locs.countryRecord(geonameID); found {
+				record[mmdbtype.String(strings.TrimSuffix(column, "_geoname_id"))] = country
+			}
+		}
+		if !found {
+			reporter.Warning(fmt.Sprintf("%s %d of network %s is not in the locations files", column, geonameID, file.field("network")))
+		}
+	}
+
+	if postalCode := file.field("postal_code"); postalCode != "" {
+		record["postal"] = mmdbtype.Map{"code": mmdbtype.String(postalCode)}
+	}
+
+	locationRecord, _ := record["location"].(mmdbtype.Map)
+	if locationRecord == nil {
+		locationRecord = mmdbtype.Map{}
+	}
+	for _, column := range []string{"latitude", "longitude"} {
+		if value := file.field(column); value != "" {
+			coordinate, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, file.errorf("invalid %s %q", column, value)
+			}
+			locationRecord[mmdbtype.String(column)] = mmdbtype.Float64(coordinate)
+		}
+	}
+	if value := file.field("accuracy_radius"); value != "" {
+		radius, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return nil, file.errorf("invalid accuracy_radius %q", value)
+		}
+		locationRecord["accuracy_radius"] = mmdbtype.Uint16(radius)
+	}
+	if len(locationRecord) > 0 {
+		record["location"] = locationRecord
+	}
+
+	traits := mmdbtype.Map{}
+	for _, column := range []string{"is_anonymous_proxy", "is_satellite_provider", "is_anycast"} {
+		if file.field(column) == "1" {
+			traits[mmdbtype.String(column)] = mmdbtype.Bool(true)
+		}
+	}
+	if len(traits) > 0 {
+		record["traits"] = traits
+	}
+
+	return record, nil
+}
+
+/*
+ImportGeoIP2CSV builds a MMDB database from the CSV distribution of a GeoIP2 or
+GeoLite2 database. The blocks files (IPv4 and IPv6) are joined with the
+locations files (one per locale) on geoname_id, and every network is written
+with the nested GeoIP2 record layout:
+
+	{
+		"city": {"geoname_id": <UINT32>, "names": {<LOCALE>: <STRING>}},
+		"continent": {"code": <STRING>, "geoname_id": <UINT32>, "names": {...}},
+		"country": {"geoname_id": <UINT32>, "iso_code": <STRING>, "names": {...}},
+		"location": {"accuracy_radius": <UINT16>, "latitude": <DOUBLE>, ...},
+		"postal": {"code": <STRING>},
+		"registered_country": {...},
+		"represented_country": {...},
+		"subdivisions": [{"iso_code": <STRING>, "names": {...}}],
+		"traits": {"is_anycast": <BOOL>, ...}
+	}
+
+ASN blocks files need no locations and are written with the
+autonomous_system_number and autonomous_system_organization fields. Unless
+set, the database type of the metadata is detected from the columns of the
+first blocks file.
+*/
+func ImportGeoIP2CSV(cfg *CmdImportGeoIP2CSVConfig) error {
+
+	var filesToCheck []files.FilesListValidation
+	for _, path := range append(append([]string{}, cfg.BlocksFiles...), cfg.LocationsFiles...) {
+		filesToCheck = append(filesToCheck, files.FilesListValidation{FilePath: path, ExpectedExtension: ".csv", ShouldExist: true})
+	}
+	filesToCheck = append(filesToCheck, files.FilesListValidation{FilePath: cfg.OutputDatabase, ExpectedExtension: ".mmdb", ShouldExist: false})
+
+	if err := files.FilesValidation(filesToCheck); err != nil {
+		return err
+	}
+
+	if len(cfg.BlocksFiles) == 0 {
+		return fmt.Errorf("at least one blocks file is required")
+	}
+
+	locs, err := readLocations(cfg.LocationsFiles)
+	if err != nil {
+		return fmt.Errorf("error reading locations: %w", err)
+	}
+
+	databaseType := cfg.DatabaseType
+	if databaseType == "" {
+		databaseType, err = detectDatabaseType(cfg.BlocksFiles[0])
+		if err != nil {
+			return fmt.Errorf("error reading blocks: %w", err)
+		}
+		slog.Info("Detected database type", "type", databaseType)
+	}
+
+	languages := make([]interface{}, 0, len(locs.localeCodes))
+	for _, locale := range locs.localeCodes {
+		languages = append(languages, locale)
+	}
+	if len(languages) == 0 {
+		languages = append(languages, "en")
+	}
+
+	// Every GeoIP2 edition holds its IPv4 networks in an IPv6 tree, so the
+	// IP version does not depend on the detected database type
+	metadata := map[string]interface{}{
+		"DatabaseType": databaseType,
+		"Description":  map[string]interface{}{"en": cfg.Description},
+		"IPVersion":    float64(6),
+		"Languages":    languages,
+		"RecordSize":   float64(28),
+	}
+
+	writer, warnings, err := generate.NewTree(metadata, generate.Options{
+		DisableIPv4Aliasing:     cfg.DisableIPv4Aliasing,
+		IncludeReservedNetworks: cfg.IncludeReservedNetworks,
+	})
+	if err != nil {
+		return fmt.Errorf("error initializing MMDB writer: %w", err)
+	}
+	for _, warning := range warnings {
+		slog.Warn(warning)
+	}
+
+	logger := &progress.Logger{
+		Verbose:       cfg.Verbose,
+		Bar:           progress.TerminalBar(os.Stderr),
+		CountFormat:   "Inserted %d records",
+		RecordMessage: "Inserting record",
+	}
+
+	var recordPosition int
+	for _, path := range cfg.BlocksFiles {
+		logger.Step(fmt.Sprintf("Importing networks from %s", path))
+
+		if err := importBlocks(logger, writer, path, locs, &recordPosition); err != nil {
+			logger.EndLine()
+			return err
+		}
+	}
+	logger.EndLine()
+
+	slog.Info("Total records inserted", "records", recordPosition)
+
+	outputFile, err := files.CreateAtomic(cfg.OutputDatabase)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Abort()
+
+	slog.Info("Writing MMDB database to the output file", "path", cfg.OutputDatabase)
+	if _, err = writer.WriteTo(outputFile); err != nil {
+		return err
+	}
+	if err := outputFile.Commit(nil); err != nil {
+		return err
+	}
+
+	fileSize, err := files.CheckFileSizeMb(cfg.OutputDatabase)
+	if err != nil {
+		return fmt.Errorf("failed to check output file size: %w", err)
+	}
+	slog.Info("MMDB file created", "path", cfg.OutputDatabase, "size_mb", fmt.Sprintf("%.2f", fileSize))
+
+	slog.Info("MMDB Imported successfully")
+
+	return nil
+}
+
+// detectDatabaseType returns the database type of the distribution of the
+// blocks file at path: GeoIP2-ASN for the autonomous system blocks,
+// GeoIP2-City for the blocks carrying a location and GeoIP2-Country for the
+// other ones.
+func detectDatabaseType(path string) (string, error) {
+	file, err := openCSVFile(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.close()
+
+	switch {
+	case file.has("autonomous_system_number"):
+		return "GeoIP2-ASN", nil
+	case file.has("latitude"), file.has("postal_code"):
+		return "GeoIP2-City", nil
+	default:
+		return "GeoIP2-Country", nil
+	}
+}
+
+func importBlocks(reporter progress.Reporter, writer *mmdbwriter.Tree, path string, locs *locations, recordPosition *int) error {
+	file, err := openCSVFile(path)
+	if err != nil {
+		return err
+	}
+	defer file.close()
+
+	if !file.has("network") {
+		return fmt.Errorf("%s is not a GeoIP2 blocks file: missing column network", path)
+	}
+	if !file.has("autonomous_system_number") && len(locs.byID) == 0 {
+		return fmt.Errorf("locations files are required to import %s", path)
+	}
+
+	for {
+		if err := file.next(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		*recordPosition++
+
+		_, network, err := net.ParseCIDR(file.field("network"))
+		if err != nil {
+			return file.errorf("invalid network %q", file.field("network"))
+		}
+
+		record, err := blockRecord(file, locs, reporter)
+		if err != nil {
+			return err
+		}
+
+		if err := writer.Insert(network, record); err != nil {
+			return fmt.Errorf("error inserting record %d (network: %s) - %w", *recordPosition, network, err)
+		}
+
+		reporter.Record(*recordPosition, network.String(), record)
+	}
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package importer
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestCSV(t *testing.T, dir, filename, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, filename)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func lookupTyped(t *testing.T, path string, ip string) mmdbtype.DataType {
+	t.Helper()
+	db, err := maxminddb.Open(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var record mmdb.TypedRecord
+	require.NoError(t, db.Lookup(net.ParseIP(ip), &record))
+	return record.Value()
+}
+
+const (
+	cityBlocks = `network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider,postal_code,latitude,longitude,accuracy_radius,is_anycast
+1.0.0.0/24,2147714,2077456,,0,0,2000,-33.8715,151.2006,100,1
+2.0.0.0/24,2077456,2077456,,0,0,,,,,
+`
+	cityLocationsEN = `geoname_id,locale_code,continent_code,continent_name,country_iso_code,country_name,subdivision_1_iso_code,subdivision_1_name,subdivision_2_iso_code,subdivision_2_name,city_name,metro_code,time_zone,is_in_european_union
+6255151,en,OC,Oceania,,,,,,,,,,0
+2077456,en,OC,Oceania,AU,Australia,,,,,,,,0
+2147714,en,OC,Oceania,AU,Australia,NSW,"New South Wales",,,Sydney,,Australia/Sydney,0
+`
+	cityLocationsDE = `geoname_id,locale_code,continent_code,continent_name,country_iso_code,country_name,subdivision_1_iso_code,subdivision_1_name,subdivision_2_iso_code,subdivision_2_name,city_name,metro_code,time_zone,is_in_european_union
+6255151,de,OC,Ozeanien,,,,,,,,,,0
+2077456,de,OC,Ozeanien,AU,Australien,,,,,,,,0
+2147714,de,OC,Ozeanien,AU,Australien,NSW,"Neusüdwales",,,Sydney,,Australia/Sydney,0
+`
+)
+
+func TestImportGeoIP2CSV(t *testing.T) {
+	t.Parallel()
+
+	t.Run("city", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		outputPath := filepath.Join(dir, "city.mmdb")
+
+		require.NoError(t, ImportGeoIP2CSV(&CmdImportGeoIP2CSVConfig{
+			BlocksFiles: []string{writeTestCSV(t, dir, "blocks.csv", cityBlocks)},
+			LocationsFiles: []string{
+				writeTestCSV(t, dir, "locations-en.csv", cityLocationsEN),
+				writeTestCSV(t, dir, "locations-de.csv", cityLocationsDE),
+			},
+			OutputDatabase: outputPath,
+			DatabaseType:   "GeoLite2-City",
+			Description:    "Test import",
+		}))
+
+		australia := mmdbtype.Map{
+			"geoname_id": mmdbtype.Uint32(2077456),
+			"iso_code":   mmdbtype.String("AU"),
+			"names":      mmdbtype.Map{"en": mmdbtype.String("Australia"), "de": mmdbtype.String("Australien")},
+		}
+		oceania := mmdbtype.Map{
+			"code":       mmdbtype.String("OC"),
+			"geoname_id": mmdbtype.Uint32(6255151),
+			"names":      mmdbtype.Map{"en": mmdbtype.String("Oceania"), "de": mmdbtype.String("Ozeanien")},
+		}
+
+		want := mmdbtype.Map{
+			"city": mmdbtype.Map{
+				"geoname_id": mmdbtype.Uint32(2147714),
+				"names":      mmdbtype.Map{"en": mmdbtype.String("Sydney"), "de": mmdbtype.String("Sydney")},
+			},
+			"continent":          oceania,
+			"country":            australia,
+			"registered_country": australia,
+			"subdivisions": mmdbtype.Slice{mmdbtype.Map{
+				"iso_code": mmdbtype.String("NSW"),
+				"names":    mmdbtype.Map{"en": mmdbtype.String("New South Wales"), "de": mmdbtype.String("Neusüdwales")},
+			}},
+			"location": mmdbtype.Map{
+				"accuracy_radius": mmdbtype.Uint16(100),
+				"latitude":        mmdbtype.Float64(-33.8715),
+				"longitude":       mmdbtype.Float64(151.2006),
+				"time_zone":       mmdbtype.String("Australia/Sydney"),
+			},
+			"postal": mmdbtype.Map{"code": mmdbtype.String("2000")},
+			"traits": mmdbtype.Map{"is_anycast": mmdbtype.Bool(true)},
+		}
+		got := lookupTyped(t, outputPath, "1.0.0.1")
+		assert.True(t, want.Equal(got), "got %v, want %v", got, want)
+
+		wantCountry := mmdbtype.Map{
+			"continent":          oceania,
+			"country":            australia,
+			"registered_country": australia,
+		}
+		got = lookupTyped(t, outputPath, "2.0.0.1")
+		assert.True(t, wantCountry.Equal(got), "got %v, want %v", got, wantCountry)
+
+		db, err := maxminddb.Open(outputPath)
+		require.NoError(t, err)
+		defer db.Close()
+		assert.Equal(t, "GeoLite2-City", db.Metadata.DatabaseType)
+		assert.Equal(t, []string{"de", "en"}, db.Metadata.Languages)
+	})
+
+	t.Run("asn", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		outputPath := filepath.Join(dir, "asn.mmdb")
+
+		require.NoError(t, ImportGeoIP2CSV(&CmdImportGeoIP2CSVConfig{
+			BlocksFiles:    []string{writeTestCSV(t, dir, "blocks.csv", "network,autonomous_system_number,autonomous_system_organization\n1.1.1.0/24,13335,CLOUDFLARENET\n")},
+			OutputDatabase: outputPath,
+			Description:    "Test import",
+		}))
+
+		want := mmdbtype.Map{
+			"autonomous_system_number":       mmdbtype.Uint32(13335),
+			"autonomous_system_organization": mmdbtype.String("CLOUDFLARENET"),
+		}
+		got := lookupTyped(t, outputPath, "1.1.1.1")
+		assert.True(t, want.Equal(got), "got %v, want %v", got, want)
+
+		// The database type is detected from the blocks file
+		db, err := maxminddb.Open(outputPath)
+		require.NoError(t, err)
+		defer db.Close()
+		assert.Equal(t, "GeoIP2-ASN", db.Metadata.DatabaseType)
+	})
+
+	t.Run("blocks without locations", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		err := ImportGeoIP2CSV(&CmdImportGeoIP2CSVConfig{
+			BlocksFiles:    []string{writeTestCSV(t, dir, "blocks.csv", cityBlocks)},
+			OutputDatabase: filepath.Join(dir, "city.mmdb"),
+			DatabaseType:   "GeoLite2-City",
+			Description:    "Test import",
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "locations files are required")
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		err := ImportGeoIP2CSV(&CmdImportGeoIP2CSVConfig{
+			BlocksFiles:    []string{writeTestCSV(t, dir, "blocks.csv", "network,autonomous_system_number\n1.1.1.0/24,AS13335\n")},
+			OutputDatabase: filepath.Join(dir, "asn.mmdb"),
+			DatabaseType:   "GeoLite2-ASN",
+			Description:    "Test import",
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "row 2")
+	})
+
+	t.Run("subdivision_2 without subdivision_1", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		locations := `geoname_id,locale_code,continent_code,continent_name,country_iso_code,country_name,subdivision_1_iso_code,subdivision_1_name,subdivision_2_iso_code,subdivision_2_name,city_name,metro_code,time_zone,is_in_european_union
+2147714,en,OC,Oceania,AU,Australia,,,SYD,Sydney,Sydney,,Australia/Sydney,0
+`
+		err := ImportGeoIP2CSV(&CmdImportGeoIP2CSVConfig{
+			BlocksFiles:    []string{writeTestCSV(t, dir, "blocks.csv", cityBlocks)},
+			LocationsFiles: []string{writeTestCSV(t, dir, "locations-en.csv", locations)},
+			OutputDatabase: filepath.Join(dir, "city.mmdb"),
+			DatabaseType:   "GeoLite2-City",
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "subdivision_2 without subdivision_1")
+	})
+
+	t.Run("not a blocks file", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		err := ImportGeoIP2CSV(&CmdImportGeoIP2CSVConfig{
+			BlocksFiles:    []string{writeTestCSV(t, dir, "blocks.csv", "cidr,asn\n")},
+			OutputDatabase: filepath.Join(dir, "asn.mmdb"),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing column network")
+	})
+}
+
+func TestDetectDatabaseType(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		blocks string
+		want   string
+	}{
+		{"city", cityBlocks, "GeoIP2-City"},
+		{"country", "network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider,is_anycast\n", "GeoIP2-Country"},
+		{"asn", "network,autonomous_system_number,autonomous_system_organization\n", "GeoIP2-ASN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := detectDatabaseType(writeTestCSV(t, t.TempDir(), "blocks.csv", tt.blocks))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
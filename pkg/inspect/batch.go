@@ -0,0 +1,406 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inspect
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/InfraZ/mmdb-cli/internal/files"
+	"github.com/InfraZ/mmdb-cli/pkg/jsonpath"
+)
+
+// Formats of the results of a batch inspection.
+const (
+	// FormatNDJSON writes one JSON object per query, holding the query and
+	// its records, or the error of an invalid query
+	FormatNDJSON = "ndjson"
+	// FormatCSV writes one row per record, with the query, the network, the
+	// record as JSON and the error of an invalid query. Queries without
+	// records get a single row without network and record. In lookup mode,
+	// every query gets a single row with the found flag and the prefix
+	// length of the network.
+	FormatCSV = "csv"
+)
+
+// Header rows of the CSV results in within and lookup modes.
+var (
+	csvHeader       = []string{"query", "network", "record", "error"}
+	csvLookupHeader = []string{"ip", "found", "network", "prefix_len", "record", "error"}
+)
+
+// maxLineSize is the maximum size of a query line.
+const maxLineSize = 1 << 16
+
+// BatchOptions configures a batch inspection.
+type BatchOptions struct {
+	// JSONPath keeps only the records matching the expression
+	JSONPath string
+
+	// Mode is one of the Mode constants (default ModeWithin). In lookup
+	// mode, the results are LookupResult values.
+	Mode string
+
+	// Format is one of the Format constants (default ndjson)
+	Format string
+
+	// Workers is the number of queries inspected concurrently (default the
+	// number of CPUs)
+	Workers int
+
+	// Ordered writes the results in the order of the queries, otherwise
+	// they are written as soon as they are ready
+	Ordered bool
+}
+
+// BatchResult is the result of a query of a batch inspection.
+type BatchResult struct {
+	Query   string   `json:"query"`
+	Records []Record `json:"records"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// batchJob is a query and its position in the input.
+type batchJob struct {
+	index int
+	query string
+}
+
+// batchOutput is the inspected result of a batchJob, a BatchResult or a
+// *LookupResult depending on the mode.
+type batchOutput struct {
+	index  int
+	result any
+}
+
+// InspectBatch inspects the queries read from src, one IP address or CIDR per
+// line, and streams their results to dst. Empty lines and lines starting with
+// # are skipped. Invalid queries are reported in their result and do not stop
+// the inspection. In lookup mode, the queries are IP addresses and each
+// result holds the network containing the address. The queries are inspected
+// by a pool of workers sharing the databases, and at most a few queries per
+// worker are kept in memory. The results are flushed to dst whenever no other
+// result is ready, so that it can be read while the queries are still being
+// written to src.
+func InspectBatch(ctx context.Context, dbs Databases, src io.Reader, dst io.Writer, opts BatchOptions) error {
+	if opts.JSONPath != "" {
+		if err := jsonpath.ValidateExpression(opts.JSONPath); err != nil {
+			return fmt.Errorf("invalid JSONPath expression: %w", err)
+		}
+	}
+	if err := validMode(opts.Mode); err != nil {
+		return err
+	}
+	if opts.Format == "" {
+		opts.Format = FormatNDJSON
+	}
+	if opts.Format != FormatNDJSON && opts.Format != FormatCSV {
+		return fmt.Errorf("unsupported batch output format: %s (supported: %s, %s)", opts.Format, FormatNDJSON, FormatCSV)
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+
+	writer, err := newBatchWriter(dst, opts.Format, opts.Mode)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// window bounds the queries read but not written yet
+	window := make(chan struct{}, opts.Workers*16)
+	jobs := make(chan batchJob)
+	outputs := make(chan batchOutput)
+
+	go func() {
+		defer close(jobs)
+		if err := readQueries(ctx, src, window, jobs); err != nil {
+			cancel(err)
+		}
+	}()
+
+	var workers sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range jobs {
+				var result any
+				var err error
+				if opts.Mode == ModeLookup {
+					result, err = lookupQuery(dbs, job.query, opts.JSONPath)
+				} else {
+					result, err = inspectQuery(ctx, dbs, job.query, opts.JSONPath)
+				}
+				if err != nil {
+					cancel(err)
+					return
+				}
+				select {
+				case outputs <- batchOutput{index: job.index, result: result}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(outputs)
+	}()
+
+	// Results ready before the results preceding them are kept until they
+	// can be written in order
+	pending := map[int]any{}
+	next := 0
+	for {
+		var output batchOutput
+		var ok bool
+		select {
+		case output, ok = <-outputs:
+		default:
+			// The results written so far are flushed before waiting for the
+			// next ones, so that they stream to dst as soon as they are ready
+			// without a flush per result when the workers keep up
+			if ctx.Err() == nil {
+				if err := writer.flush(); err != nil {
+					cancel(err)
+				}
+			}
+			output, ok = <-outputs
+		}
+		if !ok {
+			break
+		}
+		if ctx.Err() != nil {
+			continue
+		}
+
+		if !opts.Ordered {
+			if err := writer.write(output.result); err != nil {
+				cancel(err)
+			}
+			<-window
+			continue
+		}
+
+		pending[output.index] = output.result
+		for {
+			result, ready := pending[next]
+			if !ready {
+				break
+			}
+			delete(pending, next)
+			next++
+			if err := writer.write(result); err != nil {
+				cancel(err)
+				break
+			}
+			<-window
+		}
+	}
+
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+
+	return writer.flush()
+}
+
+// readQueries sends the queries of src to jobs, taking a slot of window for
+// each of them.
+func readQueries(ctx context.Context, src io.Reader, window chan struct{}, jobs chan<- batchJob) error {
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+
+	index := 0
+	for scanner.Scan() {
+		query := strings.TrimSpace(scanner.Text())
+		if query == "" || strings.HasPrefix(query, "#") {
+			continue
+		}
+
+		select {
+		case window <- struct{}{}:
+		case <-ctx.Done():
+			return nil
+		}
+		select {
+		case jobs <- batchJob{index: index, query: query}:
+		case <-ctx.Done():
+			return nil
+		}
+		index++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read queries: %w", err)
+	}
+
+	return nil
+}
+
+// inspectQuery returns the networks of dbs within query. Invalid queries are
+// reported in the result, and the errors returned stop the inspection.
+func inspectQuery(ctx context.Context, dbs Databases, query string, jsonPath string) (BatchResult, error) {
+	result := BatchResult{Query: query, Records: []Record{}}
+
+	lookupNetwork, err := determineLookupNetwork(query)
+	if err == nil {
+		_, _, err = net.ParseCIDR(lookupNetwork)
+	}
+	if err != nil {
+		result.Error = fmt.Sprintf("invalid input: %s", query)
+		return result, nil
+	}
+
+	err = dbs.NetworksWithin(ctx, query, jsonPath, func(record Record) error {
+		result.Records = append(result.Records, record)
+		return nil
+	})
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	return result, nil
+}
+
+// lookupQuery returns the networks of dbs holding query. An invalid address is
+// reported in the result, and the errors returned stop the inspection.
+func lookupQuery(dbs Databases, query string, jsonPath string) (*LookupResult, error) {
+	if net.ParseIP(query) == nil {
+		return &LookupResult{IP: query, Error: fmt.Sprintf("invalid IP address: %s", query)}, nil
+	}
+	return dbs.Lookup(query, jsonPath)
+}
+
+// batchWriter writes the results of a batch inspection in its format.
+type batchWriter struct {
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+	csv      *csv.Writer
+}
+
+func newBatchWriter(dst io.Writer, format string, mode string) (*batchWriter, error) {
+	buffered := bufio.NewWriter(dst)
+	w := &batchWriter{buffered: buffered}
+
+	if format == FormatCSV {
+		header := csvHeader
+		if mode == ModeLookup {
+			header = csvLookupHeader
+		}
+		w.csv = csv.NewWriter(buffered)
+		if err := w.csv.Write(header); err != nil {
+			return nil, fmt.Errorf("failed to write CSV header: %w", err)
+		}
+		return w, nil
+	}
+
+	w.encoder = json.NewEncoder(buffered)
+	return w, nil
+}
+
+func (w *batchWriter) write(result any) error {
+	if w.csv == nil {
+		if err := w.encoder.Encode(result); err != nil {
+			return fmt.Errorf("failed to write result: %w", err)
+		}
+		return nil
+	}
+
+	if lookup, ok := result.(*LookupResult); ok {
+		return w.writeLookupRow(lookup)
+	}
+	return w.writeRows(result.(BatchResult))
+}
+
+// writeRows writes the CSV rows of the records of result.
+func (w *batchWriter) writeRows(result BatchResult) error {
+	if len(result.Records) == 0 {
+		return w.csv.Write([]string{result.Query, "", "", result.Error})
+	}
+	for _, record := range result.Records {
+		recordJSON, err := json.Marshal(record.Record)
+		if err != nil {
+			return fmt.Errorf("failed to marshal record of %s: %w", record.Network, err)
+		}
+		if err := w.csv.Write([]string{result.Query, record.Network, string(recordJSON), ""}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeLookupRow writes the CSV row of result.
+func (w *batchWriter) writeLookupRow(result *LookupResult) error {
+	if !result.Found {
+		return w.csv.Write([]string{result.IP, "false", "", "", "", result.Error})
+	}
+
+	recordJSON, err := json.Marshal(result.Record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record of %s: %w", result.Network, err)
+	}
+	return w.csv.Write([]string{result.IP, "true", result.Network, strconv.Itoa(*result.PrefixLen), string(recordJSON), ""})
+}
+
+func (w *batchWriter) flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return w.buffered.Flush()
+}
+
+// InspectListInMMDB inspects the queries of the list file of the
+// configuration, or of the standard input for files.Stdio, and streams the
+// results to dst.
+func InspectListInMMDB(cfg CmdInspectConfig, dst io.Writer) error {
+
+	dbs, err := OpenDatabases(cfg.InputFiles)
+	if err != nil {
+		return err
+	}
+	defer dbs.Close()
+
+	list, err := files.OpenDecompressed(cfg.InputList)
+	if err != nil {
+		return fmt.Errorf("failed to open input list %s: %w", cfg.InputList, err)
+	}
+	defer list.Close()
+
+	return InspectBatch(context.Background(), dbs, list, dst, BatchOptions{
+		JSONPath: cfg.JSONPath,
+		Mode:     cfg.Mode,
+		Format:   cfg.ListFormat,
+		Workers:  cfg.Workers,
+		Ordered:  cfg.Ordered,
+	})
+}
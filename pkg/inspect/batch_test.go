@@ -0,0 +1,209 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inspect
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeBatchResults(t *testing.T, output string) []BatchResult {
+	t.Helper()
+
+	var results []BatchResult
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		var result BatchResult
+		require.NoError(t, json.Unmarshal([]byte(line), &result), line)
+		results = append(results, result)
+	}
+	return results
+}
+
+func TestInspectBatch(t *testing.T) {
+	t.Parallel()
+
+	db, err := maxminddb.Open(testMMDB)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	dbs := Databases{{Reader: db}}
+
+	input := "1.1.1.1\n\n# comment\n1.0.0.1\n  10.0.0.0/8  \ninvalid_input\n"
+
+	t.Run("ordered NDJSON", func(t *testing.T) {
+		t.Parallel()
+		var output bytes.Buffer
+		err := InspectBatch(context.Background(), dbs, strings.NewReader(input), &output, BatchOptions{Workers: 4, Ordered: true})
+		require.NoError(t, err)
+
+		results := decodeBatchResults(t, output.String())
+		require.Len(t, results, 4)
+		assert.Equal(t, "1.1.1.1", results[0].Query)
+		require.Len(t, results[0].Records, 1)
+		assert.Equal(t, "1.1.1.1/32", results[0].Records[0].Network)
+		assert.Equal(t, "1.0.0.1", results[1].Query)
+		assert.Equal(t, "10.0.0.0/8", results[2].Query)
+		assert.Empty(t, results[2].Records)
+		assert.Empty(t, results[2].Error)
+		assert.Equal(t, "invalid_input", results[3].Query)
+		assert.Contains(t, results[3].Error, "invalid input")
+	})
+
+	t.Run("ordered many queries", func(t *testing.T) {
+		t.Parallel()
+		var queries strings.Builder
+		for i := 0; i < 1000; i++ {
+			fmt.Fprintf(&queries, "1.0.%d.%d\n", i/256, i%256)
+		}
+
+		var output bytes.Buffer
+		err := InspectBatch(context.Background(), dbs, strings.NewReader(queries.String()), &output, BatchOptions{Workers: 8, Ordered: true})
+		require.NoError(t, err)
+
+		results := decodeBatchResults(t, output.String())
+		require.Len(t, results, 1000)
+		for i, result := range results {
+			assert.Equal(t, fmt.Sprintf("1.0.%d.%d", i/256, i%256), result.Query)
+		}
+	})
+
+	t.Run("unordered NDJSON", func(t *testing.T) {
+		t.Parallel()
+		var output bytes.Buffer
+		err := InspectBatch(context.Background(), dbs, strings.NewReader(input), &output, BatchOptions{Workers: 4})
+		require.NoError(t, err)
+
+		var queries []string
+		for _, result := range decodeBatchResults(t, output.String()) {
+			queries = append(queries, result.Query)
+		}
+		assert.ElementsMatch(t, []string{"1.1.1.1", "1.0.0.1", "10.0.0.0/8", "invalid_input"}, queries)
+	})
+
+	t.Run("streams the results of the queries read so far", func(t *testing.T) {
+		t.Parallel()
+		queries, queriesWriter := io.Pipe()
+		output, outputWriter := io.Pipe()
+
+		done := make(chan error, 1)
+		go func() {
+			done <- InspectBatch(context.Background(), dbs, queries, outputWriter, BatchOptions{Workers: 2})
+			outputWriter.Close()
+		}()
+
+		// Every result is read before the next query is written
+		lines := bufio.NewScanner(output)
+		for _, query := range []string{"1.1.1.1", "1.0.0.1"} {
+			_, err := io.WriteString(queriesWriter, query+"\n")
+			require.NoError(t, err)
+			require.True(t, lines.Scan())
+			assert.Equal(t, query, decodeBatchResults(t, lines.Text())[0].Query)
+		}
+
+		require.NoError(t, queriesWriter.Close())
+		assert.False(t, lines.Scan())
+		assert.NoError(t, <-done)
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		t.Parallel()
+		var output bytes.Buffer
+		err := InspectBatch(context.Background(), dbs, strings.NewReader(input), &output, BatchOptions{Format: FormatCSV, Ordered: true})
+		require.NoError(t, err)
+
+		rows, err := csv.NewReader(&output).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 5)
+		assert.Equal(t, []string{"query", "network", "record", "error"}, rows[0])
+		assert.Equal(t, "1.1.1.1", rows[1][0])
+		assert.Equal(t, "1.1.1.1/32", rows[1][1])
+		assert.Contains(t, rows[1][2], `"iso_code":"AU"`)
+		assert.Equal(t, []string{"10.0.0.0/8", "", "", ""}, rows[3])
+		assert.Equal(t, []string{"invalid_input", "", "", "invalid input: invalid_input"}, rows[4])
+	})
+
+	t.Run("JSONPath filter", func(t *testing.T) {
+		t.Parallel()
+		var output bytes.Buffer
+		err := InspectBatch(context.Background(), dbs, strings.NewReader("1.1.1.1\n"), &output, BatchOptions{
+			JSONPath: `{[?(@.registered_country.iso_code=="US")]}`,
+		})
+		require.NoError(t, err)
+
+		results := decodeBatchResults(t, output.String())
+		require.Len(t, results, 1)
+		assert.Empty(t, results[0].Records)
+	})
+
+	t.Run("lookup mode NDJSON", func(t *testing.T) {
+		t.Parallel()
+		var output bytes.Buffer
+		err := InspectBatch(context.Background(), dbs, strings.NewReader("1.0.0.7\n192.168.1.1\n1.0.0.0/24\n"), &output, BatchOptions{Mode: ModeLookup, Ordered: true})
+		require.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+		require.Len(t, lines, 3)
+		var results []LookupResult
+		for _, line := range lines {
+			var result LookupResult
+			require.NoError(t, json.Unmarshal([]byte(line), &result))
+			results = append(results, result)
+		}
+		assert.True(t, results[0].Found)
+		assert.Equal(t, "1.0.0.0/24", results[0].Network)
+		assert.Equal(t, 24, *results[0].PrefixLen)
+		assert.Equal(t, LookupResult{IP: "192.168.1.1"}, results[1])
+		assert.False(t, results[2].Found)
+		assert.Equal(t, "invalid IP address: 1.0.0.0/24", results[2].Error)
+	})
+
+	t.Run("lookup mode CSV", func(t *testing.T) {
+		t.Parallel()
+		var output bytes.Buffer
+		err := InspectBatch(context.Background(), dbs, strings.NewReader("1.1.1.1\n192.168.1.1\n"), &output, BatchOptions{Mode: ModeLookup, Format: FormatCSV, Ordered: true})
+		require.NoError(t, err)
+
+		rows, err := csv.NewReader(&output).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 3)
+		assert.Equal(t, []string{"ip", "found", "network", "prefix_len", "record", "error"}, rows[0])
+		assert.Equal(t, []string{"1.1.1.1", "true", "1.1.1.1/32", "32"}, rows[1][:4])
+		assert.Equal(t, []string{"192.168.1.1", "false", "", "", "", ""}, rows[2])
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		t.Parallel()
+		err := InspectBatch(context.Background(), dbs, strings.NewReader(input), &bytes.Buffer{}, BatchOptions{Format: "yaml"})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid JSONPath", func(t *testing.T) {
+		t.Parallel()
+		err := InspectBatch(context.Background(), dbs, strings.NewReader(input), &bytes.Buffer{}, BatchOptions{JSONPath: "{[?("})
+		assert.Error(t, err)
+	})
+}
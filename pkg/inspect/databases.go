@@ -0,0 +1,378 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inspect
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/InfraZ/mmdb-cli/pkg/jsonpath"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// aliasPattern matches the aliases that can prefix a database input.
+var aliasPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// ParseDatabaseInput splits a database input of the form alias=path into its
+// alias and its path. An input without an alias is returned as the path.
+func ParseDatabaseInput(input string) (alias string, path string) {
+	prefix, rest, found := strings.Cut(input, "=")
+	if found && aliasPattern.MatchString(prefix) {
+		return prefix, rest
+	}
+	return "", input
+}
+
+// Database is an open database queried with others.
+type Database struct {
+	// Alias keys the records of the database in the merged records, which
+	// are deep-merged with the records of the other databases when empty
+	Alias string
+
+	Reader *maxminddb.Reader
+}
+
+/*
+Databases are queried together, the records found in each of them for a query
+being merged into a single record. The record of a database with an alias is
+set under the alias, and the records of the databases without one are merged
+key by key, the values of the later databases taking precedence:
+
+	-i city.mmdb -i asn=asn.mmdb  ->  {"city": ..., "country": ..., "asn": {...}}
+
+A single database without an alias is queried as it is.
+*/
+type Databases []Database
+
+// OpenDatabases opens the database inputs, each of them a path optionally
+// prefixed by an alias, see ParseDatabaseInput.
+func OpenDatabases(inputs []string) (Databases, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("at least one database is required")
+	}
+
+	var dbs Databases
+	aliases := map[string]bool{}
+	for _, input := range inputs {
+		alias, path := ParseDatabaseInput(input)
+		if alias != "" {
+			if aliases[alias] {
+				dbs.Close()
+				return nil, fmt.Errorf("several databases have the alias %s", alias)
+			}
+			aliases[alias] = true
+		}
+
+		reader, err := mmdbReader(path)
+		if err != nil {
+			dbs.Close()
+			return nil, err
+		}
+		dbs = append(dbs, Database{Alias: alias, Reader: reader})
+	}
+
+	return dbs, nil
+}
+
+// Close closes the readers of the databases.
+func (dbs Databases) Close() {
+	for _, db := range dbs {
+		db.Reader.Close()
+	}
+}
+
+// single returns the reader of the databases when they are a single database
+// without an alias, whose records do not have to be merged.
+func (dbs Databases) single() *maxminddb.Reader {
+	if len(dbs) == 1 && dbs[0].Alias == "" {
+		return dbs[0].Reader
+	}
+	return nil
+}
+
+// Inspect returns the networks of the databases found within every query,
+// with their merged records, see the Inspect function.
+func (dbs Databases) Inspect(ctx context.Context, queries []string, jsonPath string) ([]Result, error) {
+
+	if jsonPath != "" {
+		if err := jsonpath.ValidateExpression(jsonPath); err != nil {
+			return nil, fmt.Errorf("invalid JSONPath expression: %w", err)
+		}
+	}
+
+	results := make([]Result, 0, len(queries))
+
+	for _, input := range queries {
+		records := []Record{}
+		err := dbs.NetworksWithin(ctx, input, jsonPath, func(record Record) error {
+			records = append(records, record)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, Result{Query: input, Records: records})
+	}
+
+	return results, nil
+}
+
+// LookupAll looks up every IP address of ips in the databases, see Lookup,
+// and returns one result for each of them.
+func (dbs Databases) LookupAll(ips []string, jsonPath string) ([]*LookupResult, error) {
+
+	if jsonPath != "" {
+		if err := jsonpath.ValidateExpression(jsonPath); err != nil {
+			return nil, fmt.Errorf("invalid JSONPath expression: %w", err)
+		}
+	}
+
+	results := make([]*LookupResult, 0, len(ips))
+	for _, ip := range ips {
+		result, err := dbs.Lookup(ip, jsonPath)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// Lookup returns the merged records of the networks of the databases holding
+// the IP address ip, see the Lookup function. The network of the result is
+// the most specific of these networks, across which every merged record
+// applies.
+func (dbs Databases) Lookup(ip string, jsonPath string) (*LookupResult, error) {
+	if db := dbs.single(); db != nil {
+		return Lookup(db, ip, jsonPath)
+	}
+
+	address := net.ParseIP(ip)
+	if address == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", ip)
+	}
+
+	result := &LookupResult{IP: ip}
+
+	var merged any
+	var network *net.IPNet
+	for _, db := range dbs {
+		var record any
+		dbNetwork, ok, err := db.Reader.LookupNetwork(address, &record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lookup IP %s: %w", ip, err)
+		}
+		if !ok {
+			continue
+		}
+
+		merged = mergeRecord(merged, db.Alias, record)
+		if network == nil || hostBits(dbNetwork) < hostBits(network) {
+			network = dbNetwork
+		}
+	}
+	if network == nil {
+		return result, nil
+	}
+
+	if jsonPath != "" {
+		recordMap, _ := merged.(map[string]interface{})
+		match, err := jsonpath.MatchesRecord(jsonPath, recordMap)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate JSONPath expression: %w", err)
+		}
+		if !match {
+			return result, nil
+		}
+	}
+
+	result.Found = true
+	result.Network = network.String()
+	result.PrefixLen = prefixLen(network)
+	result.Record = merged
+
+	return result, nil
+}
+
+// NetworksWithin calls fn for every network of the databases within query,
+// see the NetworksWithin function. The networks of all the databases are
+// listed once, ordered by address and the larger networks first, with the
+// merged records of the databases having a network that holds the whole
+// network. The networks of a database nested in the networks of another one
+// are thus listed along with them, and a network holding the whole query is
+// listed as it is, like with a single database. The networks of the
+// databases are merged as they are read, without being kept in memory.
+func (dbs Databases) NetworksWithin(ctx context.Context, query string, jsonPath string, fn func(Record) error) error {
+	if db := dbs.single(); db != nil {
+		return NetworksWithin(ctx, db, query, jsonPath, fn)
+	}
+
+	lookupNetwork, err := determineLookupNetwork(query)
+	if err != nil {
+		return fmt.Errorf("invalid input: %s", query)
+	}
+
+	_, netIPNet, err := net.ParseCIDR(lookupNetwork)
+	if err != nil {
+		return fmt.Errorf("invalid input: %s", query)
+	}
+
+	cursors := make([]*networkCursor, 0, len(dbs))
+	for _, db := range dbs {
+		cursor := &networkCursor{networks: mmdbNetworksWithin(db.Reader, netIPNet)}
+		if err := cursor.advance(); err != nil {
+			return err
+		}
+		cursors = append(cursors, cursor)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var network *net.IPNet
+		for _, cursor := range cursors {
+			if cursor.network != nil && (network == nil || compareNetworks(cursor.network, network) < 0) {
+				network = cursor.network
+			}
+		}
+		if network == nil {
+			return nil
+		}
+
+		if err := dbs.mergedNetwork(network, jsonPath, fn); err != nil {
+			return err
+		}
+
+		// The databases having the same network list it once
+		for _, cursor := range cursors {
+			if cursor.network != nil && compareNetworks(cursor.network, network) == 0 {
+				if err := cursor.advance(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// mergedNetwork calls fn for network with the merged records of the
+// databases having a network that holds it, unless they do not match
+// jsonPath.
+func (dbs Databases) mergedNetwork(network *net.IPNet, jsonPath string, fn func(Record) error) error {
+	var merged any
+	for _, db := range dbs {
+		var record any
+		dbNetwork, ok, err := db.Reader.LookupNetwork(network.IP, &record)
+		if err != nil {
+			return fmt.Errorf("failed to lookup record: %w", err)
+		}
+		if ok && hostBits(dbNetwork) >= hostBits(network) {
+			merged = mergeRecord(merged, db.Alias, record)
+		}
+	}
+
+	if jsonPath != "" {
+		recordMap, _ := merged.(map[string]interface{})
+		match, err := jsonpath.MatchesRecord(jsonPath, recordMap)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate JSONPath expression: %w", err)
+		}
+		if !match {
+			return nil
+		}
+	}
+
+	return fn(Record{Network: network.String(), Record: merged})
+}
+
+// networkCursor is the next network of a database within a query, nil once
+// they were all read.
+type networkCursor struct {
+	networks *maxminddb.Networks
+	network  *net.IPNet
+}
+
+// advance reads the next network of the cursor.
+func (c *networkCursor) advance() error {
+	c.network = nil
+	if !c.networks.Next() {
+		if err := c.networks.Err(); err != nil {
+			return fmt.Errorf("failed to read networks: %w", err)
+		}
+		return nil
+	}
+
+	var anyNetwork any
+	network, err := c.networks.Network(&anyNetwork)
+	if err != nil {
+		return fmt.Errorf("failed to get network: %w", err)
+	}
+	c.network = network
+	return nil
+}
+
+// hostBits returns the number of host bits of network, which compares the
+// sizes of IPv4 networks whether they are read from IPv4 or IPv6 databases.
+func hostBits(network *net.IPNet) int {
+	ones, bits := network.Mask.Size()
+	return bits - ones
+}
+
+// compareNetworks orders the networks by address, and the larger networks
+// first for the same address.
+func compareNetworks(a *net.IPNet, b *net.IPNet) int {
+	if order := bytes.Compare(a.IP.To16(), b.IP.To16()); order != 0 {
+		return order
+	}
+	return hostBits(b) - hostBits(a)
+}
+
+// mergeRecord merges the record of a database into the records merged so
+// far, under alias when it is set.
+func mergeRecord(merged any, alias string, record any) any {
+	if alias != "" {
+		record = map[string]any{alias: record}
+	}
+	return deepMerge(merged, record)
+}
+
+// deepMerge merges the maps of src into the maps of dst, the other values of
+// src replacing those of dst. The maps of dst are copied before they are
+// changed.
+func deepMerge(dst any, src any) any {
+	dstMap, dstIsMap := dst.(map[string]any)
+	srcMap, srcIsMap := src.(map[string]any)
+	if !dstIsMap || !srcIsMap {
+		return src
+	}
+
+	merged := make(map[string]any, len(dstMap)+len(srcMap))
+	for key, value := range dstMap {
+		merged[key] = value
+	}
+	for key, value := range srcMap {
+		merged[key] = deepMerge(merged[key], value)
+	}
+	return merged
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inspect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestDatabase writes a database holding records to a file of dir.
+func writeTestDatabase(t *testing.T, dir string, name string, records map[string]mmdbtype.Map) string {
+	t.Helper()
+
+	writer, err := mmdbwriter.New(mmdbwriter.Options{DatabaseType: name, RecordSize: 24})
+	require.NoError(t, err)
+	for network, record := range records {
+		_, ipNet, err := net.ParseCIDR(network)
+		require.NoError(t, err)
+		require.NoError(t, writer.Insert(ipNet, record))
+	}
+
+	path := filepath.Join(dir, name+".mmdb")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	_, err = writer.WriteTo(file)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	return path
+}
+
+func TestParseDatabaseInput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input string
+		alias string
+		path  string
+	}{
+		{input: "GeoLite2-City.mmdb", path: "GeoLite2-City.mmdb"},
+		{input: "asn=GeoLite2-ASN.mmdb", alias: "asn", path: "GeoLite2-ASN.mmdb"},
+		{input: "internal_tags=/data/tags.mmdb.gz", alias: "internal_tags", path: "/data/tags.mmdb.gz"},
+		{input: "./a=b.mmdb", path: "./a=b.mmdb"},
+		{input: "=b.mmdb", path: "=b.mmdb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+			alias, path := ParseDatabaseInput(tt.input)
+			assert.Equal(t, tt.alias, alias)
+			assert.Equal(t, tt.path, path)
+		})
+	}
+}
+
+func TestDatabases(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	geo := writeTestDatabase(t, dir, "geo", map[string]mmdbtype.Map{
+		"20.0.0.0/8": {
+			"country": mmdbtype.Map{"iso_code": mmdbtype.String("US")},
+			"source":  mmdbtype.String("geo"),
+		},
+	})
+	tags := writeTestDatabase(t, dir, "tags", map[string]mmdbtype.Map{
+		"20.1.0.0/16": {
+			"country": mmdbtype.Map{"name": mmdbtype.String("United States")},
+			"source":  mmdbtype.String("tags"),
+		},
+		"81.2.69.0/24": {
+			"source": mmdbtype.String("tags"),
+		},
+	})
+
+	merged, err := OpenDatabases([]string{geo, tags})
+	require.NoError(t, err)
+	t.Cleanup(merged.Close)
+
+	aliased, err := OpenDatabases([]string{"geo=" + geo, "tags=" + tags})
+	require.NoError(t, err)
+	t.Cleanup(aliased.Close)
+
+	t.Run("deep-merged lookup", func(t *testing.T) {
+		t.Parallel()
+		result, err := merged.Lookup("20.1.2.3", "")
+		require.NoError(t, err)
+		assert.True(t, result.Found)
+		assert.Equal(t, "20.1.0.0/16", result.Network)
+		assert.Equal(t, 16, *result.PrefixLen)
+		assert.Equal(t, map[string]any{
+			"country": map[string]any{"iso_code": "US", "name": "United States"},
+			"source":  "tags",
+		}, result.Record)
+	})
+
+	t.Run("aliased lookup", func(t *testing.T) {
+		t.Parallel()
+		result, err := aliased.Lookup("20.2.0.1", "")
+		require.NoError(t, err)
+		assert.True(t, result.Found)
+		assert.Equal(t, "20.0.0.0/8", result.Network)
+		assert.Equal(t, map[string]any{
+			"geo": map[string]any{"country": map[string]any{"iso_code": "US"}, "source": "geo"},
+		}, result.Record)
+	})
+
+	t.Run("lookup not found", func(t *testing.T) {
+		t.Parallel()
+		result, err := aliased.Lookup("30.0.0.1", "")
+		require.NoError(t, err)
+		assert.Equal(t, &LookupResult{IP: "30.0.0.1"}, result)
+	})
+
+	t.Run("lookup JSONPath filter", func(t *testing.T) {
+		t.Parallel()
+		result, err := aliased.Lookup("20.1.2.3", `{[?(@.tags.source=="tags")]}`)
+		require.NoError(t, err)
+		assert.True(t, result.Found)
+
+		result, err = aliased.Lookup("20.2.0.1", `{[?(@.tags.source=="tags")]}`)
+		require.NoError(t, err)
+		assert.False(t, result.Found)
+	})
+
+	t.Run("networks within", func(t *testing.T) {
+		t.Parallel()
+		var records []Record
+		err := aliased.NetworksWithin(context.Background(), "0.0.0.0/0", "", func(record Record) error {
+			records = append(records, record)
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.Len(t, records, 3)
+		assert.Equal(t, "20.0.0.0/8", records[0].Network)
+		assert.Equal(t, []string{"geo"}, recordKeys(records[0].Record))
+		assert.Equal(t, "20.1.0.0/16", records[1].Network)
+		assert.Equal(t, []string{"geo", "tags"}, recordKeys(records[1].Record))
+		assert.Equal(t, "81.2.69.0/24", records[2].Network)
+		assert.Equal(t, []string{"tags"}, recordKeys(records[2].Record))
+	})
+
+	t.Run("inspect", func(t *testing.T) {
+		t.Parallel()
+		results, err := merged.Inspect(context.Background(), []string{"20.1.0.0/16"}, "")
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.Len(t, results[0].Records, 2)
+		assert.Equal(t, "20.0.0.0/8", results[0].Records[0].Network)
+		assert.Equal(t, map[string]any{"iso_code": "US"}, results[0].Records[0].Record.(map[string]any)["country"])
+		assert.Equal(t, "20.1.0.0/16", results[0].Records[1].Network)
+		assert.Equal(t, map[string]any{"iso_code": "US", "name": "United States"}, results[0].Records[1].Record.(map[string]any)["country"])
+	})
+
+	t.Run("inspect an address", func(t *testing.T) {
+		t.Parallel()
+		// The networks holding the address are listed as they are, like
+		// with a single database
+		results, err := aliased.Inspect(context.Background(), []string{"20.1.2.3", "81.2.69.1"}, "")
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		require.Len(t, results[0].Records, 2)
+		assert.Equal(t, "20.0.0.0/8", results[0].Records[0].Network)
+		assert.Equal(t, []string{"geo"}, recordKeys(results[0].Records[0].Record))
+		assert.Equal(t, "20.1.0.0/16", results[0].Records[1].Network)
+		assert.Equal(t, []string{"geo", "tags"}, recordKeys(results[0].Records[1].Record))
+		require.Len(t, results[1].Records, 1)
+		assert.Equal(t, "81.2.69.0/24", results[1].Records[0].Network)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		t.Parallel()
+		var output bytes.Buffer
+		err := InspectBatch(context.Background(), aliased, strings.NewReader("20.1.2.3\n81.2.69.1\n"), &output, BatchOptions{Mode: ModeLookup, Ordered: true})
+		require.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+		require.Len(t, lines, 2)
+		var result LookupResult
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &result))
+		assert.Equal(t, "20.1.0.0/16", result.Network)
+		assert.Equal(t, []string{"geo", "tags"}, recordKeys(result.Record))
+		require.NoError(t, json.Unmarshal([]byte(lines[1]), &result))
+		assert.Equal(t, []string{"tags"}, recordKeys(result.Record))
+	})
+
+	t.Run("duplicate alias", func(t *testing.T) {
+		t.Parallel()
+		_, err := OpenDatabases([]string{"geo=" + geo, "geo=" + tags})
+		assert.Error(t, err)
+	})
+}
+
+// recordKeys returns the sorted keys of a merged record.
+func recordKeys(record any) []string {
+	var keys []string
+	for key := range record.(map[string]any) {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestDeepMerge(t *testing.T) {
+	t.Parallel()
+
+	dst := map[string]any{"a": map[string]any{"b": 1, "c": 2}, "d": []any{1}}
+	merged := deepMerge(dst, map[string]any{"a": map[string]any{"c": 3}, "d": []any{2}})
+
+	assert.Equal(t, map[string]any{"a": map[string]any{"b": 1, "c": 3}, "d": []any{2}}, merged)
+	assert.Equal(t, map[string]any{"a": map[string]any{"b": 1, "c": 2}, "d": []any{1}}, dst)
+	assert.Equal(t, "x", deepMerge(map[string]any{"a": 1}, "x"))
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package inspect
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -24,13 +25,52 @@ import (
 	"strings"
 
 	"github.com/InfraZ/mmdb-cli/pkg/jsonpath"
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
 	"github.com/oschwald/maxminddb-golang"
 )
 
+// Modes of inspection of a query.
+const (
+	// ModeWithin returns every network of the database within the query, an
+	// IP address or a CIDR
+	ModeWithin = "within"
+	// ModeLookup returns the network holding the query, an IP address, with
+	// its prefix length, as the longest prefix match of the database
+	ModeLookup = "lookup"
+)
+
+// validMode checks that mode is one of the Mode constants, the empty mode
+// being ModeWithin.
+func validMode(mode string) error {
+	if mode != "" && mode != ModeWithin && mode != ModeLookup {
+		return fmt.Errorf("unsupported inspect mode: %s (supported: %s, %s)", mode, ModeWithin, ModeLookup)
+	}
+	return nil
+}
+
 type CmdInspectConfig struct {
-	InputFile string
-	Inputs    []string
-	JSONPath  string
+	// InputFiles are the database files, each of them optionally prefixed by
+	// an alias, see ParseDatabaseInput
+	InputFiles []string
+	Inputs     []string
+	JSONPath   string
+
+	// Mode is one of the Mode constants (default ModeWithin)
+	Mode string
+
+	// InputList is a file holding one query per line, or files.Stdio, read
+	// instead of Inputs
+	InputList string
+
+	// ListFormat is the format of the results of the queries of InputList,
+	// one of the batch Format constants
+	ListFormat string
+
+	// Workers is the number of queries of InputList inspected concurrently
+	Workers int
+
+	// Ordered writes the results of the queries of InputList in their order
+	Ordered bool
 }
 
 func determineLookupNetwork(input string) (string, error) {
@@ -53,7 +93,7 @@ func determineLookupNetwork(input string) (string, error) {
 }
 
 func mmdbReader(input string) (*maxminddb.Reader, error) {
-	db, err := maxminddb.Open(input)
+	db, err := mmdb.OpenDatabase(input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open MMDB database: %w", err)
 	}
@@ -77,75 +117,170 @@ func mmdbNetworksWithin(reader *maxminddb.Reader, query *net.IPNet) *maxminddb.N
 	return networksList
 }
 
-func InspectInMMDB(cfg CmdInspectConfig) ([]byte, error) {
+// Result holds the networks of the database found for a query.
+type Result struct {
+	Query   string   `json:"query"`
+	Records []Record `json:"records"`
+}
+
+// Record is a network of the database and its record.
+type Record struct {
+	Network string `json:"network"`
+	Record  any    `json:"record"`
+}
+
+// Inspect looks up every query, an IP address or a CIDR, in db and returns
+// the networks found within each of them. When jsonPath is set, only the
+// records matching the expression are kept.
+func Inspect(ctx context.Context, db *maxminddb.Reader, queries []string, jsonPath string) ([]Result, error) {
+	return Databases{{Reader: db}}.Inspect(ctx, queries, jsonPath)
+}
 
-	reader, err := mmdbReader(cfg.InputFile)
+// NetworksWithin calls fn for every network of db within query, an IP
+// address or a CIDR, stopping at the first error. When jsonPath is set, only
+// the records matching the expression are passed to fn. The expression is
+// expected to be valid, see jsonpath.ValidateExpression.
+func NetworksWithin(ctx context.Context, db *maxminddb.Reader, query string, jsonPath string, fn func(Record) error) error {
+	lookupNetwork, err := determineLookupNetwork(query)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("invalid input: %s", query)
 	}
 
-	inspectInMmdbResult := []map[string]interface{}{}
-
-	if cfg.JSONPath != "" {
-		if err := jsonpath.ValidateExpression(cfg.JSONPath); err != nil {
-			return nil, fmt.Errorf("invalid JSONPath expression: %w", err)
-		}
+	_, netIPNet, err := net.ParseCIDR(lookupNetwork)
+	if err != nil {
+		return fmt.Errorf("invalid input: %s", query)
 	}
 
-	for _, input := range cfg.Inputs {
+	inputNetworks := mmdbNetworksWithin(db, netIPNet)
 
-		inspectInMmdbResult = append(inspectInMmdbResult, map[string]interface{}{
-			"query": input,
-		})
+	for inputNetworks.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
-		lookupNetwork, err := determineLookupNetwork(input)
+		var anyNetwork any
+		address, err := inputNetworks.Network(&anyNetwork)
 		if err != nil {
-			return nil, fmt.Errorf("invalid input: %s", input)
+			return fmt.Errorf("failed to get network: %w", err)
 		}
 
-		_, netIPNet, err := net.ParseCIDR(lookupNetwork)
+		record, err := mmdbLookup(db, address.IP)
 		if err != nil {
-			return nil, fmt.Errorf("invalid input: %s", input)
+			return fmt.Errorf("failed to lookup record: %w", err)
 		}
 
-		inputNetworks := mmdbNetworksWithin(reader, netIPNet)
-
-		recordsResults := []map[string]interface{}{}
-		for inputNetworks.Next() {
-			var anyNetwork any
-			address, err := inputNetworks.Network(&anyNetwork)
+		if jsonPath != "" {
+			recordMap, _ := record.(map[string]interface{})
+			match, err := jsonpath.MatchesRecord(jsonPath, recordMap)
 			if err != nil {
-				return nil, fmt.Errorf("failed to get network: %w", err)
+				return fmt.Errorf("failed to evaluate JSONPath expression: %w", err)
 			}
-
-			record, err := mmdbLookup(reader, address.IP)
-			if err != nil {
-				return nil, fmt.Errorf("failed to lookup record: %w", err)
+			if !match {
+				continue
 			}
+		}
 
-			recordsResults = append(recordsResults, map[string]interface{}{
-				"network": address.String(),
-				"record":  record,
-			})
+		if err := fn(Record{Network: address.String(), Record: record}); err != nil {
+			return err
 		}
+	}
+	if err := inputNetworks.Err(); err != nil {
+		return fmt.Errorf("failed to read networks: %w", err)
+	}
 
-		if cfg.JSONPath != "" {
-			filtered := []map[string]interface{}{}
-			for _, entry := range recordsResults {
-				record, _ := entry["record"].(map[string]interface{})
-				match, err := jsonpath.MatchesRecord(cfg.JSONPath, record)
-				if err != nil {
-					return nil, fmt.Errorf("failed to evaluate JSONPath expression: %w", err)
-				}
-				if match {
-					filtered = append(filtered, entry)
-				}
-			}
-			recordsResults = filtered
+	return nil
+}
+
+// LookupResult is the record of the network holding an IP address, Found
+// being false when the database has no record for it.
+type LookupResult struct {
+	IP      string `json:"ip"`
+	Found   bool   `json:"found"`
+	Network string `json:"network,omitempty"`
+
+	// PrefixLen is only set for a found record, so that a prefix length of
+	// zero is kept in the JSON results
+	PrefixLen *int `json:"prefix_len,omitempty"`
+
+	Record any `json:"record,omitempty"`
+
+	// Error is set in the results of a batch inspection for an invalid
+	// address
+	Error string `json:"error,omitempty"`
+}
+
+// Lookup returns the record of the network of db holding the IP address ip.
+// When jsonPath is set, a record that does not match the expression is
+// reported as not found. The expression is expected to be valid, see
+// jsonpath.ValidateExpression.
+func Lookup(db *maxminddb.Reader, ip string, jsonPath string) (*LookupResult, error) {
+	address := net.ParseIP(ip)
+	if address == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", ip)
+	}
+
+	result := &LookupResult{IP: ip}
+
+	var record any
+	network, ok, err := db.LookupNetwork(address, &record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup IP %s: %w", ip, err)
+	}
+	if !ok {
+		return result, nil
+	}
+
+	if jsonPath != "" {
+		recordMap, _ := record.(map[string]interface{})
+		match, err := jsonpath.MatchesRecord(jsonPath, recordMap)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate JSONPath expression: %w", err)
+		}
+		if !match {
+			return result, nil
 		}
+	}
+
+	result.Found = true
+	result.Network = network.String()
+	result.PrefixLen = prefixLen(network)
+	result.Record = record
+
+	return result, nil
+}
 
-		inspectInMmdbResult[len(inspectInMmdbResult)-1]["records"] = recordsResults
+// prefixLen returns the prefix length of network.
+func prefixLen(network *net.IPNet) *int {
+	ones, _ := network.Mask.Size()
+	return &ones
+}
+
+// LookupAll looks up every IP address of ips in db, see Lookup, and returns
+// one result for each of them.
+func LookupAll(db *maxminddb.Reader, ips []string, jsonPath string) ([]*LookupResult, error) {
+	return Databases{{Reader: db}}.LookupAll(ips, jsonPath)
+}
+
+func InspectInMMDB(cfg CmdInspectConfig) ([]byte, error) {
+
+	if err := validMode(cfg.Mode); err != nil {
+		return nil, err
+	}
 
+	dbs, err := OpenDatabases(cfg.InputFiles)
+	if err != nil {
+		return nil, err
+	}
+	defer dbs.Close()
+
+	var inspectInMmdbResult any
+	if cfg.Mode == ModeLookup {
+		inspectInMmdbResult, err = dbs.LookupAll(cfg.Inputs, cfg.JSONPath)
+	} else {
+		inspectInMmdbResult, err = dbs.Inspect(context.Background(), cfg.Inputs, cfg.JSONPath)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	inspectInMmdbResultJson, err := json.Marshal(inspectInMmdbResult)
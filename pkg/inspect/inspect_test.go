@@ -17,6 +17,7 @@ limitations under the License.
 package inspect
 
 import (
+	"context"
 	"encoding/json"
 	"net"
 	"testing"
@@ -221,8 +222,8 @@ func TestInspectInMMDB(t *testing.T) {
 		{
 			name: "single IPv4 lookup",
 			cfg: CmdInspectConfig{
-				InputFile: testMMDB,
-				Inputs:    []string{"1.1.1.1"},
+				InputFiles: []string{testMMDB},
+				Inputs:     []string{"1.1.1.1"},
 			},
 			wantErr: false,
 			verify: func(t *testing.T, result []byte) {
@@ -239,8 +240,8 @@ func TestInspectInMMDB(t *testing.T) {
 		{
 			name: "CIDR range lookup",
 			cfg: CmdInspectConfig{
-				InputFile: testMMDB,
-				Inputs:    []string{"1.0.0.0/8"},
+				InputFiles: []string{testMMDB},
+				Inputs:     []string{"1.0.0.0/8"},
 			},
 			wantErr: false,
 			verify: func(t *testing.T, result []byte) {
@@ -256,8 +257,8 @@ func TestInspectInMMDB(t *testing.T) {
 		{
 			name: "multiple inputs",
 			cfg: CmdInspectConfig{
-				InputFile: testMMDB,
-				Inputs:    []string{"1.1.1.1", "1.0.0.0/24"},
+				InputFiles: []string{testMMDB},
+				Inputs:     []string{"1.1.1.1", "1.0.0.0/24"},
 			},
 			wantErr: false,
 			verify: func(t *testing.T, result []byte) {
@@ -270,9 +271,9 @@ func TestInspectInMMDB(t *testing.T) {
 		{
 			name: "with JSONPath filter - matching",
 			cfg: CmdInspectConfig{
-				InputFile: testMMDB,
-				Inputs:    []string{"1.1.1.1"},
-				JSONPath:  `{[?(@.registered_country.iso_code=="AU")]}`,
+				InputFiles: []string{testMMDB},
+				Inputs:     []string{"1.1.1.1"},
+				JSONPath:   `{[?(@.registered_country.iso_code=="AU")]}`,
 			},
 			wantErr: false,
 			verify: func(t *testing.T, result []byte) {
@@ -288,9 +289,9 @@ func TestInspectInMMDB(t *testing.T) {
 		{
 			name: "with JSONPath filter - non-matching",
 			cfg: CmdInspectConfig{
-				InputFile: testMMDB,
-				Inputs:    []string{"1.1.1.1"},
-				JSONPath:  `{[?(@.registered_country.iso_code=="ZZ")]}`,
+				InputFiles: []string{testMMDB},
+				Inputs:     []string{"1.1.1.1"},
+				JSONPath:   `{[?(@.registered_country.iso_code=="ZZ")]}`,
 			},
 			wantErr: false,
 			verify: func(t *testing.T, result []byte) {
@@ -306,33 +307,33 @@ func TestInspectInMMDB(t *testing.T) {
 		{
 			name: "invalid MMDB file",
 			cfg: CmdInspectConfig{
-				InputFile: "/nonexistent/file.mmdb",
-				Inputs:    []string{"1.1.1.1"},
+				InputFiles: []string{"/nonexistent/file.mmdb"},
+				Inputs:     []string{"1.1.1.1"},
 			},
 			wantErr: true,
 		},
 		{
 			name: "invalid input IP",
 			cfg: CmdInspectConfig{
-				InputFile: testMMDB,
-				Inputs:    []string{"not_an_ip"},
+				InputFiles: []string{testMMDB},
+				Inputs:     []string{"not_an_ip"},
 			},
 			wantErr: true,
 		},
 		{
 			name: "invalid JSONPath expression",
 			cfg: CmdInspectConfig{
-				InputFile: testMMDB,
-				Inputs:    []string{"1.1.1.1"},
-				JSONPath:  "{[?(@.field==}",
+				InputFiles: []string{testMMDB},
+				Inputs:     []string{"1.1.1.1"},
+				JSONPath:   "{[?(@.field==}",
 			},
 			wantErr: true,
 		},
 		{
 			name: "IP not in database",
 			cfg: CmdInspectConfig{
-				InputFile: testMMDB,
-				Inputs:    []string{"192.168.1.1"},
+				InputFiles: []string{testMMDB},
+				Inputs:     []string{"192.168.1.1"},
 			},
 			wantErr: false,
 			verify: func(t *testing.T, result []byte) {
@@ -345,6 +346,44 @@ func TestInspectInMMDB(t *testing.T) {
 				assert.Empty(t, records)
 			},
 		},
+		{
+			name: "lookup mode",
+			cfg: CmdInspectConfig{
+				InputFiles: []string{testMMDB},
+				Inputs:     []string{"1.0.0.7", "192.168.1.1"},
+				Mode:       ModeLookup,
+			},
+			wantErr: false,
+			verify: func(t *testing.T, result []byte) {
+				t.Helper()
+				var parsed []LookupResult
+				require.NoError(t, json.Unmarshal(result, &parsed))
+				require.Len(t, parsed, 2)
+				assert.True(t, parsed[0].Found)
+				assert.Equal(t, "1.0.0.0/24", parsed[0].Network)
+				assert.Equal(t, 24, *parsed[0].PrefixLen)
+				assert.NotNil(t, parsed[0].Record)
+				assert.Equal(t, LookupResult{IP: "192.168.1.1"}, parsed[1])
+			},
+		},
+		{
+			name: "lookup mode with CIDR",
+			cfg: CmdInspectConfig{
+				InputFiles: []string{testMMDB},
+				Inputs:     []string{"1.0.0.0/24"},
+				Mode:       ModeLookup,
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsupported mode",
+			cfg: CmdInspectConfig{
+				InputFiles: []string{testMMDB},
+				Inputs:     []string{"1.1.1.1"},
+				Mode:       "nearest",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -362,3 +401,60 @@ func TestInspectInMMDB(t *testing.T) {
 		})
 	}
 }
+
+func TestInspect(t *testing.T) {
+	t.Parallel()
+
+	db, err := mmdbReader(testMMDB)
+	require.NoError(t, err)
+	defer db.Close()
+
+	results, err := Inspect(context.Background(), db, []string{"1.1.1.1", "10.0.0.0/8"}, "")
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	assert.Equal(t, "1.1.1.1", results[0].Query)
+	require.NotEmpty(t, results[0].Records)
+	assert.NotEmpty(t, results[0].Records[0].Network)
+	assert.Equal(t, "10.0.0.0/8", results[1].Query)
+	assert.NotNil(t, results[1].Records)
+
+	_, err = Inspect(context.Background(), db, []string{"invalid"}, "")
+	assert.Error(t, err)
+}
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+
+	db, err := mmdbReader(testMMDB)
+	require.NoError(t, err)
+	defer db.Close()
+
+	result, err := Lookup(db, "1.1.1.1", "")
+	require.NoError(t, err)
+	assert.True(t, result.Found)
+	assert.Equal(t, "1.1.1.1/32", result.Network)
+	assert.Equal(t, 32, *result.PrefixLen)
+	assert.NotNil(t, result.Record)
+
+	result, err = Lookup(db, "1.1.1.1", `{[?(@.registered_country.iso_code=="US")]}`)
+	require.NoError(t, err)
+	assert.Equal(t, &LookupResult{IP: "1.1.1.1"}, result)
+
+	result, err = Lookup(db, "10.0.0.1", "")
+	require.NoError(t, err)
+	assert.False(t, result.Found)
+	assert.Nil(t, result.Record)
+	encoded, err := json.Marshal(result)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"ip":"10.0.0.1","found":false}`, string(encoded))
+
+	// The prefix length of a found record is kept when it is zero
+	zero := 0
+	encoded, err = json.Marshal(&LookupResult{IP: "10.0.0.1", Found: true, Network: "0.0.0.0/0", PrefixLen: &zero})
+	require.NoError(t, err)
+	assert.Contains(t, string(encoded), `"prefix_len":0`)
+
+	_, err = Lookup(db, "invalid", "")
+	assert.Error(t, err)
+}
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
 	"github.com/oschwald/maxminddb-golang"
 )
 
@@ -39,17 +40,11 @@ type DatabaseMetadata struct {
 	RecordSize               uint              `json:"record_size"`
 }
 
-func MetadataMMDB(cfg CmdMetadataConfig) ([]byte, error) {
-
-	db, err := maxminddb.Open(cfg.InputFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %s - %w", cfg.InputFile, err)
-	}
-	defer db.Close()
-
+// Metadata returns the metadata of the open database db.
+func Metadata(db *maxminddb.Reader) DatabaseMetadata {
 	mmdbMetadata := db.Metadata
 
-	databaseMetadata := DatabaseMetadata{
+	return DatabaseMetadata{
 		Description:              mmdbMetadata.Description,
 		DatabaseType:             mmdbMetadata.DatabaseType,
 		Languages:                mmdbMetadata.Languages,
@@ -60,6 +55,17 @@ func MetadataMMDB(cfg CmdMetadataConfig) ([]byte, error) {
 		NodeCount:                mmdbMetadata.NodeCount,
 		RecordSize:               mmdbMetadata.RecordSize,
 	}
+}
+
+func MetadataMMDB(cfg CmdMetadataConfig) ([]byte, error) {
+
+	db, err := mmdb.OpenDatabase(cfg.InputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %s - %w", cfg.InputFile, err)
+	}
+	defer db.Close()
+
+	databaseMetadata := Metadata(db)
 
 	jsonDatabaseMetadata, err := json.Marshal(databaseMetadata)
 	if err != nil {
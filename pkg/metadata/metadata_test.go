@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/oschwald/maxminddb-golang"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -102,3 +103,15 @@ func TestMetadataMMDBJsonStructure(t *testing.T) {
 		assert.Contains(t, raw, field, "JSON output should contain field %q", field)
 	}
 }
+
+func TestMetadata(t *testing.T) {
+	t.Parallel()
+
+	db, err := maxminddb.Open(testMMDB)
+	require.NoError(t, err)
+	defer db.Close()
+
+	meta := Metadata(db)
+	assert.Equal(t, "Metadata Test", meta.DatabaseType)
+	assert.Equal(t, db.Metadata.NodeCount, meta.NodeCount)
+}
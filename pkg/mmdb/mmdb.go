@@ -17,22 +17,93 @@ limitations under the License.
 package mmdb
 
 import (
-	"log"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/big"
+	"strings"
 
 	"github.com/maxmind/mmdbwriter/mmdbtype"
 )
 
+var (
+	maxUint64  = new(big.Int).SetUint64(math.MaxUint64)
+	maxUint128 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+)
+
+// ConversionError describes a value that does not match the MMDB type its
+// schema requires. Position and Network are left empty by the conversion
+// functions and are filled in by the caller that knows which dataset record
+// the value belongs to.
+type ConversionError struct {
+	Position int
+	Network  string
+	Path     string
+	Expected string
+	Value    interface{}
+}
+
+func (e *ConversionError) Error() string {
+	msg := fmt.Sprintf("expected %s for key %s, got %v (%T)", e.Expected, e.Path, e.Value, e.Value)
+	if e.Network != "" {
+		return fmt.Sprintf("record %d (network: %s): %s", e.Position, e.Network, msg)
+	}
+	return msg
+}
+
+// SchemaViolations is returned in strict mode and lists every value of a
+// dataset that does not match its schema.
+type SchemaViolations []*ConversionError
+
+func (v SchemaViolations) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d schema violation(s) found in the dataset:", len(v))
+	for _, violation := range v {
+		sb.WriteString("\n  - ")
+		sb.WriteString(violation.Error())
+	}
+	return sb.String()
+}
+
+// converter collects the errors raised while converting a record so they can
+// either be logged or returned to the caller in strict mode.
+type converter struct {
+	errs []*ConversionError
+}
+
+func (c *converter) fail(path string, expected string, value interface{}) {
+	c.errs = append(c.errs, &ConversionError{Path: path, Expected: expected, Value: value})
+}
+
 func ConvertToMMDBTypeMap(data map[string]interface{}, useDefaultSchema bool, schema map[string]interface{}) mmdbtype.Map {
-	mmdbMap := mmdbtype.Map{}
+	mmdbMap, errs := ConvertToMMDBTypeMapStrict(data, useDefaultSchema, schema)
+	for _, err := range errs {
+		slog.Warn(err.Error())
+	}
+	return mmdbMap
+}
+
+// ConvertToMMDBTypeMapStrict converts data the same way as
+// ConvertToMMDBTypeMap, but returns every value that does not match its schema
+// instead of logging it. The returned map still holds the fallback values.
+func ConvertToMMDBTypeMapStrict(data map[string]interface{}, useDefaultSchema bool, schema map[string]interface{}) (mmdbtype.Map, []*ConversionError) {
+	c := &converter{}
 
 	if !useDefaultSchema && schema != nil {
 		// Use user-defined schema
-		return convertWithSchema(data, schema)
+		return c.convertWithSchema(data, schema, ""), c.errs
 	}
 
-	// Use default schema (original switch case logic)
+	return c.convertMapDefault(data, ""), c.errs
+}
+
+func (c *converter) convertMapDefault(data map[string]interface{}, path string) mmdbtype.Map {
+	mmdbMap := mmdbtype.Map{}
+
 	for key, value := range data {
 		mmdbKey := mmdbtype.String(key)
+		keyPath := joinPath(path, key)
 		switch mmdbValue := value.(type) {
 		case string:
 			mmdbMap[mmdbKey] = mmdbtype.String(mmdbValue)
@@ -44,19 +115,22 @@ func ConvertToMMDBTypeMap(data map[string]interface{}, useDefaultSchema bool, sc
 			mmdbMap[mmdbKey] = mmdbtype.Int32(mmdbValue)
 		case map[string]interface{}:
 			// Recursively convert nested maps
-			mmdbMap[mmdbKey] = ConvertToMMDBTypeMap(mmdbValue, useDefaultSchema, schema)
+			mmdbMap[mmdbKey] = c.convertMapDefault(mmdbValue, keyPath)
+		case []interface{}:
+			mmdbMap[mmdbKey] = c.convertSliceDefault(mmdbValue, keyPath)
 		default:
-			log.Printf("Unsupported data type for key %v", key)
+			c.fail(keyPath, "a supported data type", value)
 		}
 	}
 	return mmdbMap
 }
 
-func convertWithSchema(data map[string]interface{}, schema map[string]interface{}) mmdbtype.Map {
+func (c *converter) convertWithSchema(data map[string]interface{}, schema map[string]interface{}, path string) mmdbtype.Map {
 	mmdbMap := mmdbtype.Map{}
 
 	for key, value := range data {
 		mmdbKey := mmdbtype.String(key)
+		keyPath := joinPath(path, key)
 
 		// Get the schema for this key
 		var schemaForKey interface{}
@@ -66,90 +140,168 @@ func convertWithSchema(data map[string]interface{}, schema map[string]interface{
 		}
 
 		if hasSchema {
-			// Handle schema-defined conversion
-			switch schemaValue := schemaForKey.(type) {
-			case string:
-				// Simple type definition
-				mmdbMap[mmdbKey] = convertValueWithType(value, schemaValue, key)
-			case map[string]interface{}:
-				// Nested object with its own schema
-				if nestedData, ok := value.(map[string]interface{}); ok {
-					mmdbMap[mmdbKey] = convertWithSchema(nestedData, schemaValue)
-				} else {
-					log.Printf("Expected nested object for key %s, got %T", key, value)
-				}
-			default:
-				// Schema value is not string or map, fall back to default
-				mmdbMap[mmdbKey] = convertValueDefault(value, key)
+			// Handle schema-defined conversion, values that cannot be
+			// converted at all are left out of the record
+			if mmdbValue := c.convertValueWithSchema(value, schemaForKey, keyPath); mmdbValue != nil {
+				mmdbMap[mmdbKey] = mmdbValue
 			}
 		} else {
 			// No schema for this key, use default conversion
-			mmdbMap[mmdbKey] = convertValueDefault(value, key)
+			mmdbMap[mmdbKey] = c.convertValueDefault(value, keyPath)
 		}
 	}
 
 	return mmdbMap
 }
 
-func convertValueWithType(value interface{}, expectedType string, key string) mmdbtype.DataType {
+// convertValueWithSchema converts a single value according to its schema
+// definition, which is either a type name, a nested object schema or a
+// single-element list holding the schema of the array elements. It returns
+// nil when the value does not have the shape required by the schema.
+func (c *converter) convertValueWithSchema(value interface{}, schemaForKey interface{}, path string) mmdbtype.DataType {
+	switch schemaValue := schemaForKey.(type) {
+	case string:
+		// Simple type definition
+		return c.convertValueWithType(value, schemaValue, path)
+	case map[string]interface{}:
+		// Nested object with its own schema
+		if nestedData, ok := value.(map[string]interface{}); ok {
+			return c.convertWithSchema(nestedData, schemaValue, path)
+		}
+		c.fail(path, "object", value)
+		return nil
+	case []interface{}:
+		// Array with a schema for its elements
+		items, ok := value.([]interface{})
+		if !ok {
+			c.fail(path, "array", value)
+			return nil
+		}
+		if len(schemaValue) == 0 {
+			return c.convertSliceDefault(items, path)
+		}
+		if len(schemaValue) > 1 {
+			slog.Warn("Array schema has several element types, only the first one is used", "key", path, "types", len(schemaValue))
+		}
+		mmdbSlice := make(mmdbtype.Slice, 0, len(items))
+		for i, item := range items {
+			mmdbItem := c.convertValueWithSchema(item, schemaValue[0], fmt.Sprintf("%s[%d]", path, i))
+			if mmdbItem == nil {
+				// The element keeps its slot so that the following elements
+				// keep their index
+				mmdbItem = zeroValue(schemaValue[0])
+			}
+			mmdbSlice = append(mmdbSlice, mmdbItem)
+		}
+		return mmdbSlice
+	default:
+		// Schema value is not a string, map or array, fall back to default
+		return c.convertValueDefault(value, path)
+	}
+}
+
+// zeroValue returns the empty value of an object or array schema, written in
+// place of an array element that does not have the shape of its schema.
+func zeroValue(schema interface{}) mmdbtype.DataType {
+	if _, isArray := schema.([]interface{}); isArray {
+		return mmdbtype.Slice{}
+	}
+	return mmdbtype.Map{}
+}
+
+// schemaTypes are the type names a dataset schema can give to a value.
+var schemaTypes = map[string]bool{
+	"string": true, "bool": true, "boolean": true,
+	"float": true, "float64": true, "float32": true,
+	"uint16": true, "int": true, "int32": true, "uint": true, "uint32": true,
+	"uint64": true, "uint128": true, "bytes": true,
+}
+
+// IsSchemaType reports whether name is a type supported in dataset schemas.
+func IsSchemaType(name string) bool {
+	return schemaTypes[name]
+}
+
+func (c *converter) convertValueWithType(value interface{}, expectedType string, path string) mmdbtype.DataType {
 	switch expectedType {
 	case "string":
 		if str, ok := value.(string); ok {
 			return mmdbtype.String(str)
 		} else {
-			log.Printf("Expected string for key %s, got %T", key, value)
+			c.fail(path, "string", value)
 			return mmdbtype.String("")
 		}
 	case "bool", "boolean":
 		if b, ok := value.(bool); ok {
 			return mmdbtype.Bool(b)
 		} else {
-			log.Printf("Expected bool for key %s, got %T", key, value)
+			c.fail(path, "bool", value)
 			return mmdbtype.Bool(false)
 		}
 	case "float", "float64":
 		if f, ok := value.(float64); ok {
 			return mmdbtype.Float64(f)
 		} else {
-			log.Printf("Expected float64 for key %s, got %T", key, value)
+			c.fail(path, "float64", value)
 			return mmdbtype.Float64(0)
 		}
+	case "float32":
+		if f, ok := value.(float64); ok && !math.IsInf(f, 0) && !math.IsNaN(f) && math.Abs(f) <= math.MaxFloat32 {
+			return mmdbtype.Float32(f)
+		} else {
+			c.fail(path, "float32", value)
+			return mmdbtype.Float32(0)
+		}
 	case "uint16":
-		if i, ok := value.(int16); ok {
-			return mmdbtype.Uint16(uint16(i))
-		} else if i, ok := value.(int); ok {
-			return mmdbtype.Uint16(uint16(i))
-		} else if f, ok := value.(float64); ok {
-			return mmdbtype.Uint16(uint16(f))
+		if i, ok := integerInRange(value, big.NewInt(0), big.NewInt(math.MaxUint16), false); ok {
+			return mmdbtype.Uint16(i.Uint64())
 		} else {
-			log.Printf("Expected int16 for key %s, got %T", key, value)
+			c.fail(path, fmt.Sprintf("uint16 (0 to %d)", math.MaxUint16), value)
 			return mmdbtype.Uint16(0)
 		}
 	case "int", "int32":
-		if i, ok := value.(int); ok {
-			return mmdbtype.Int32(i)
-		} else if f, ok := value.(float64); ok {
-			return mmdbtype.Int32(int(f))
+		if i, ok := integerInRange(value, big.NewInt(math.MinInt32), big.NewInt(math.MaxInt32), false); ok {
+			return mmdbtype.Int32(i.Int64())
 		} else {
-			log.Printf("Expected int for key %s, got %T", key, value)
+			c.fail(path, fmt.Sprintf("int32 (%d to %d)", math.MinInt32, math.MaxInt32), value)
 			return mmdbtype.Int32(0)
 		}
 	case "uint", "uint32":
-		if i, ok := value.(int); ok && i >= 0 {
-			return mmdbtype.Uint32(uint32(i))
-		} else if f, ok := value.(float64); ok && f >= 0 {
-			return mmdbtype.Uint32(uint32(f))
+		if i, ok := integerInRange(value, big.NewInt(0), big.NewInt(math.MaxUint32), false); ok {
+			return mmdbtype.Uint32(i.Uint64())
 		} else {
-			log.Printf("Expected uint for key %s, got %T", key, value)
+			c.fail(path, fmt.Sprintf("uint32 (0 to %d)", uint32(math.MaxUint32)), value)
 			return mmdbtype.Uint32(0)
 		}
+	case "uint64":
+		if i, ok := integerInRange(value, big.NewInt(0), maxUint64, true); ok {
+			return mmdbtype.Uint64(i.Uint64())
+		} else {
+			c.fail(path, fmt.Sprintf("uint64 (0 to %s)", maxUint64), value)
+			return mmdbtype.Uint64(0)
+		}
+	case "uint128":
+		if i, ok := integerInRange(value, big.NewInt(0), maxUint128, true); ok {
+			return (*mmdbtype.Uint128)(i)
+		} else {
+			c.fail(path, fmt.Sprintf("uint128 (0 to %s)", maxUint128), value)
+			return (*mmdbtype.Uint128)(big.NewInt(0))
+		}
+	case "bytes":
+		if str, ok := value.(string); ok {
+			if b, err := base64.StdEncoding.DecodeString(str); err == nil {
+				return mmdbtype.Bytes(b)
+			}
+		}
+		c.fail(path, "base64 encoded bytes", value)
+		return mmdbtype.Bytes{}
 	default:
 		// Unknown type in schema, fall back to default
-		return convertValueDefault(value, key)
+		return c.convertValueDefault(value, path)
 	}
 }
 
-func convertValueDefault(value interface{}, key string) mmdbtype.DataType {
+func (c *converter) convertValueDefault(value interface{}, path string) mmdbtype.DataType {
 	switch v := value.(type) {
 	case string:
 		return mmdbtype.String(v)
@@ -161,9 +313,68 @@ func convertValueDefault(value interface{}, key string) mmdbtype.DataType {
 		return mmdbtype.Int32(v)
 	case map[string]interface{}:
 		// For nested maps without schema, use default conversion
-		return ConvertToMMDBTypeMap(v, true, nil)
+		return c.convertMapDefault(v, path)
+	case []interface{}:
+		return c.convertSliceDefault(v, path)
 	default:
-		log.Printf("Unsupported data type for key %s: %T", key, value)
+		c.fail(path, "a supported data type", value)
 		return mmdbtype.String("")
 	}
 }
+
+func (c *converter) convertSliceDefault(items []interface{}, path string) mmdbtype.Slice {
+	mmdbSlice := make(mmdbtype.Slice, 0, len(items))
+	for i, item := range items {
+		mmdbSlice = append(mmdbSlice, c.convertValueDefault(item, fmt.Sprintf("%s[%d]", path, i)))
+	}
+	return mmdbSlice
+}
+
+// joinPath appends key to the dotted JSON key path of its parent.
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// integerInRange returns the integer held by value when it lies within
+// [lower, upper]. JSON numbers arrive as float64 and are only accepted when
+// they have no fractional part. When allowString is set, decimal strings and
+// hex strings with a 0x prefix are accepted too, which is how values that do
+// not fit in a float64 without losing precision are expected to be written.
+func integerInRange(value interface{}, lower, upper *big.Int, allowString bool) (*big.Int, bool) {
+	var i *big.Int
+
+	switch v := value.(type) {
+	case int:
+		i = big.NewInt(int64(v))
+	case int16:
+		i = big.NewInt(int64(v))
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) || v != math.Trunc(v) {
+			return nil, false
+		}
+		i, _ = big.NewFloat(v).Int(nil)
+	case string:
+		if !allowString {
+			return nil, false
+		}
+		var ok bool
+		if hex, isHex := strings.CutPrefix(strings.ToLower(v), "0x"); isHex {
+			i, ok = new(big.Int).SetString(hex, 16)
+		} else {
+			i, ok = new(big.Int).SetString(v, 10)
+		}
+		if !ok {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+
+	if i.Cmp(lower) < 0 || i.Cmp(upper) > 0 {
+		return nil, false
+	}
+	return i, true
+}
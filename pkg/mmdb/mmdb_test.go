@@ -17,6 +17,8 @@ limitations under the License.
 package mmdb
 
 import (
+	"bytes"
+	"math/big"
 	"testing"
 
 	"github.com/maxmind/mmdbwriter/mmdbtype"
@@ -61,10 +63,35 @@ func compareMMDBTypes(a, b mmdbtype.DataType) bool {
 		if b, ok := b.(mmdbtype.Uint32); ok {
 			return a == b
 		}
+	case mmdbtype.Float32:
+		if b, ok := b.(mmdbtype.Float32); ok {
+			return a == b
+		}
+	case mmdbtype.Uint64:
+		if b, ok := b.(mmdbtype.Uint64); ok {
+			return a == b
+		}
+	case *mmdbtype.Uint128:
+		if b, ok := b.(*mmdbtype.Uint128); ok {
+			return (*big.Int)(a).Cmp((*big.Int)(b)) == 0
+		}
+	case mmdbtype.Bytes:
+		if b, ok := b.(mmdbtype.Bytes); ok {
+			return bytes.Equal(a, b)
+		}
 	case mmdbtype.Map:
 		if b, ok := b.(mmdbtype.Map); ok {
 			return compareMMDBTypeMaps(a, b)
 		}
+	case mmdbtype.Slice:
+		if b, ok := b.(mmdbtype.Slice); ok && len(a) == len(b) {
+			for i := range a {
+				if !compareMMDBTypes(a[i], b[i]) {
+					return false
+				}
+			}
+			return true
+		}
 	}
 	return false
 }
@@ -134,6 +161,43 @@ func TestConvertToMMDBTypeMap(t *testing.T) {
 			},
 			want: mmdbtype.Map{},
 		},
+		{
+			name: "array of scalars",
+			data: map[string]interface{}{
+				"tags": []interface{}{"anycast", "cdn", true, 1.5},
+			},
+			want: mmdbtype.Map{
+				mmdbtype.String("tags"): mmdbtype.Slice{
+					mmdbtype.String("anycast"),
+					mmdbtype.String("cdn"),
+					mmdbtype.Bool(true),
+					mmdbtype.Float64(1.5),
+				},
+			},
+		},
+		{
+			name: "array of maps and nested arrays",
+			data: map[string]interface{}{
+				"subdivisions": []interface{}{
+					map[string]interface{}{"iso_code": "CA"},
+					map[string]interface{}{"iso_code": "NY"},
+				},
+				"matrix": []interface{}{
+					[]interface{}{"a", "b"},
+					[]interface{}{},
+				},
+			},
+			want: mmdbtype.Map{
+				mmdbtype.String("subdivisions"): mmdbtype.Slice{
+					mmdbtype.Map{mmdbtype.String("iso_code"): mmdbtype.String("CA")},
+					mmdbtype.Map{mmdbtype.String("iso_code"): mmdbtype.String("NY")},
+				},
+				mmdbtype.String("matrix"): mmdbtype.Slice{
+					mmdbtype.Slice{mmdbtype.String("a"), mmdbtype.String("b")},
+					mmdbtype.Slice{},
+				},
+			},
+		},
 		{
 			name: "empty map",
 			data: map[string]interface{}{},
@@ -343,6 +407,101 @@ func TestConvertWithSchema(t *testing.T) {
 				mmdbtype.String("unknown"): mmdbtype.String("fallback"),
 			},
 		},
+		{
+			name: "typed array schema",
+			data: map[string]interface{}{
+				"asns": []interface{}{float64(13335), float64(15169)},
+			},
+			schema: map[string]interface{}{
+				"asns": []interface{}{"uint32"},
+			},
+			want: mmdbtype.Map{
+				mmdbtype.String("asns"): mmdbtype.Slice{mmdbtype.Uint32(13335), mmdbtype.Uint32(15169)},
+			},
+		},
+		{
+			name: "array of object schemas",
+			data: map[string]interface{}{
+				"subdivisions": []interface{}{
+					map[string]interface{}{"iso_code": "CA", "geoname_id": float64(5332921)},
+				},
+			},
+			schema: map[string]interface{}{
+				"subdivisions": []interface{}{
+					map[string]interface{}{"geoname_id": "uint32"},
+				},
+			},
+			want: mmdbtype.Map{
+				mmdbtype.String("subdivisions"): mmdbtype.Slice{
+					mmdbtype.Map{
+						mmdbtype.String("iso_code"):   mmdbtype.String("CA"),
+						mmdbtype.String("geoname_id"): mmdbtype.Uint32(5332921),
+					},
+				},
+			},
+		},
+		{
+			name: "nested array schema",
+			data: map[string]interface{}{
+				"ranges": []interface{}{
+					[]interface{}{float64(80), float64(443)},
+				},
+			},
+			schema: map[string]interface{}{
+				"ranges": []interface{}{[]interface{}{"uint16"}},
+			},
+			want: mmdbtype.Map{
+				mmdbtype.String("ranges"): mmdbtype.Slice{
+					mmdbtype.Slice{mmdbtype.Uint16(80), mmdbtype.Uint16(443)},
+				},
+			},
+		},
+		{
+			name: "array elements with the wrong shape keep their index",
+			data: map[string]interface{}{
+				"subdivisions": []interface{}{"CA", map[string]interface{}{"geoname_id": float64(5332921)}},
+				"ranges":       []interface{}{float64(80), []interface{}{float64(443)}},
+				"asns":         []interface{}{"one", float64(2)},
+			},
+			schema: map[string]interface{}{
+				"subdivisions": []interface{}{map[string]interface{}{"geoname_id": "uint32"}},
+				"ranges":       []interface{}{[]interface{}{"uint16"}},
+				"asns":         []interface{}{"uint32"},
+			},
+			want: mmdbtype.Map{
+				mmdbtype.String("subdivisions"): mmdbtype.Slice{
+					mmdbtype.Map{},
+					mmdbtype.Map{mmdbtype.String("geoname_id"): mmdbtype.Uint32(5332921)},
+				},
+				mmdbtype.String("ranges"): mmdbtype.Slice{
+					mmdbtype.Slice{},
+					mmdbtype.Slice{mmdbtype.Uint16(443)},
+				},
+				mmdbtype.String("asns"): mmdbtype.Slice{mmdbtype.Uint32(0), mmdbtype.Uint32(2)},
+			},
+		},
+		{
+			name: "empty array schema uses default element conversion",
+			data: map[string]interface{}{
+				"tags": []interface{}{"a", float64(1)},
+			},
+			schema: map[string]interface{}{
+				"tags": []interface{}{},
+			},
+			want: mmdbtype.Map{
+				mmdbtype.String("tags"): mmdbtype.Slice{mmdbtype.String("a"), mmdbtype.Float64(1)},
+			},
+		},
+		{
+			name: "array schema with non-array value is skipped",
+			data: map[string]interface{}{
+				"tags": "not-an-array",
+			},
+			schema: map[string]interface{}{
+				"tags": []interface{}{"string"},
+			},
+			want: mmdbtype.Map{},
+		},
 		{
 			name: "type mismatch falls back to default value",
 			data: map[string]interface{}{
@@ -394,6 +553,11 @@ func TestConvertValueDefault(t *testing.T) {
 			value: 42,
 			want:  mmdbtype.Int32(42),
 		},
+		{
+			name:  "array value",
+			value: []interface{}{"a", float64(2)},
+			want:  mmdbtype.Slice{mmdbtype.String("a"), mmdbtype.Float64(2)},
+		},
 		{
 			name:  "unsupported type returns empty string",
 			value: []string{"a", "b"},
@@ -404,7 +568,7 @@ func TestConvertValueDefault(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			got := convertValueDefault(tt.value, "test_key")
+			got := new(converter).convertValueDefault(tt.value, "test_key")
 			assert.True(t, compareMMDBTypes(got, tt.want), "convertValueDefault() = %v, want %v", got, tt.want)
 		})
 	}
@@ -521,6 +685,132 @@ func TestConvertValueWithType(t *testing.T) {
 			expectedType: "uint32",
 			want:         mmdbtype.Uint32(0),
 		},
+		{
+			name:         "uint16 out of range",
+			value:        float64(70000),
+			expectedType: "uint16",
+			want:         mmdbtype.Uint16(0),
+		},
+		{
+			name:         "uint16 negative",
+			value:        float64(-1),
+			expectedType: "uint16",
+			want:         mmdbtype.Uint16(0),
+		},
+		{
+			name:         "uint16 with fractional part",
+			value:        1.5,
+			expectedType: "uint16",
+			want:         mmdbtype.Uint16(0),
+		},
+		{
+			name:         "int32 lower bound",
+			value:        float64(-2147483648),
+			expectedType: "int32",
+			want:         mmdbtype.Int32(-2147483648),
+		},
+		{
+			name:         "int32 out of range",
+			value:        float64(2147483648),
+			expectedType: "int32",
+			want:         mmdbtype.Int32(0),
+		},
+		{
+			name:         "uint32 upper bound",
+			value:        float64(4294967295),
+			expectedType: "uint32",
+			want:         mmdbtype.Uint32(4294967295),
+		},
+		{
+			name:         "uint32 out of range",
+			value:        float64(4294967296),
+			expectedType: "uint32",
+			want:         mmdbtype.Uint32(0),
+		},
+		{
+			name:         "uint64 from float64",
+			value:        float64(4294967296),
+			expectedType: "uint64",
+			want:         mmdbtype.Uint64(4294967296),
+		},
+		{
+			name:         "uint64 from decimal string",
+			value:        "18446744073709551615",
+			expectedType: "uint64",
+			want:         mmdbtype.Uint64(18446744073709551615),
+		},
+		{
+			name:         "uint64 out of range",
+			value:        "18446744073709551616",
+			expectedType: "uint64",
+			want:         mmdbtype.Uint64(0),
+		},
+		{
+			name:         "uint64 negative",
+			value:        float64(-1),
+			expectedType: "uint64",
+			want:         mmdbtype.Uint64(0),
+		},
+		{
+			name:         "uint128 from decimal string",
+			value:        "340282366920938463463374607431768211455",
+			expectedType: "uint128",
+			want:         (*mmdbtype.Uint128)(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))),
+		},
+		{
+			name:         "uint128 from hex string",
+			value:        "0xFF",
+			expectedType: "uint128",
+			want:         (*mmdbtype.Uint128)(big.NewInt(255)),
+		},
+		{
+			name:         "uint128 from float64",
+			value:        float64(42),
+			expectedType: "uint128",
+			want:         (*mmdbtype.Uint128)(big.NewInt(42)),
+		},
+		{
+			name:         "uint128 out of range",
+			value:        "340282366920938463463374607431768211456",
+			expectedType: "uint128",
+			want:         (*mmdbtype.Uint128)(big.NewInt(0)),
+		},
+		{
+			name:         "uint128 with invalid string",
+			value:        "not_a_number",
+			expectedType: "uint128",
+			want:         (*mmdbtype.Uint128)(big.NewInt(0)),
+		},
+		{
+			name:         "float32 type",
+			value:        1.5,
+			expectedType: "float32",
+			want:         mmdbtype.Float32(1.5),
+		},
+		{
+			name:         "float32 out of range",
+			value:        1e39,
+			expectedType: "float32",
+			want:         mmdbtype.Float32(0),
+		},
+		{
+			name:         "bytes from base64",
+			value:        "aGVsbG8=",
+			expectedType: "bytes",
+			want:         mmdbtype.Bytes("hello"),
+		},
+		{
+			name:         "bytes with invalid base64",
+			value:        "not base64!",
+			expectedType: "bytes",
+			want:         mmdbtype.Bytes{},
+		},
+		{
+			name:         "bytes with wrong type",
+			value:        float64(1),
+			expectedType: "bytes",
+			want:         mmdbtype.Bytes{},
+		},
 		{
 			name:         "unknown schema type falls back to default",
 			value:        "hello",
@@ -532,8 +822,111 @@ func TestConvertValueWithType(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			got := convertValueWithType(tt.value, tt.expectedType, "test_key")
+			got := new(converter).convertValueWithType(tt.value, tt.expectedType, "test_key")
 			assert.True(t, compareMMDBTypes(got, tt.want), "convertValueWithType() = %v, want %v", got, tt.want)
 		})
 	}
 }
+
+func TestConvertToMMDBTypeMapStrict(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		data      map[string]interface{}
+		schema    map[string]interface{}
+		wantPaths []string
+	}{
+		{
+			name: "no violations",
+			data: map[string]interface{}{
+				"asn": float64(13335),
+			},
+			schema: map[string]interface{}{
+				"asn": "uint32",
+			},
+		},
+		{
+			name: "type mismatch",
+			data: map[string]interface{}{
+				"asn": "13335",
+			},
+			schema: map[string]interface{}{
+				"asn": "uint32",
+			},
+			wantPaths: []string{"asn"},
+		},
+		{
+			name: "nested key path",
+			data: map[string]interface{}{
+				"country": map[string]interface{}{
+					"geoname_id": float64(-1),
+				},
+			},
+			schema: map[string]interface{}{
+				"country": map[string]interface{}{
+					"geoname_id": "uint32",
+				},
+			},
+			wantPaths: []string{"country.geoname_id"},
+		},
+		{
+			name: "array element path",
+			data: map[string]interface{}{
+				"asns": []interface{}{float64(1), "two", float64(3)},
+			},
+			schema: map[string]interface{}{
+				"asns": []interface{}{"uint32"},
+			},
+			wantPaths: []string{"asns[1]"},
+		},
+		{
+			name: "object expected",
+			data: map[string]interface{}{
+				"country": "US",
+			},
+			schema: map[string]interface{}{
+				"country": map[string]interface{}{"iso_code": "string"},
+			},
+			wantPaths: []string{"country"},
+		},
+		{
+			name: "unsupported type without schema",
+			data: map[string]interface{}{
+				"location": map[string]interface{}{
+					"accuracy": nil,
+				},
+			},
+			schema: map[string]interface{}{
+				"name": "string",
+			},
+			wantPaths: []string{"location.accuracy"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, errs := ConvertToMMDBTypeMapStrict(tt.data, false, tt.schema)
+			gotPaths := []string{}
+			for _, err := range errs {
+				gotPaths = append(gotPaths, err.Path)
+			}
+			assert.ElementsMatch(t, tt.wantPaths, gotPaths)
+		})
+	}
+}
+
+func TestSchemaViolationsError(t *testing.T) {
+	t.Parallel()
+
+	violations := SchemaViolations{
+		{Position: 1, Network: "1.0.0.0/24", Path: "asn", Expected: "uint32", Value: "x"},
+		{Position: 3, Network: "2.0.0.0/24", Path: "country.iso_code", Expected: "string", Value: float64(1)},
+	}
+
+	msg := violations.Error()
+	assert.Contains(t, msg, "2 schema violation(s)")
+	assert.Contains(t, msg, "record 1 (network: 1.0.0.0/24): expected uint32 for key asn, got x (string)")
+	assert.Contains(t, msg, "record 3 (network: 2.0.0.0/24): expected string for key country.iso_code, got 1 (float64)")
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mmdb
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// ParseNetwork parses the network key of a dataset entry into the networks it
+// covers. The key is either a CIDR, a bare IP address, which covers a /32 or
+// /128 network, or an inclusive "first-last" IP range, which is decomposed
+// into the minimal set of CIDRs.
+func ParseNetwork(value string) ([]*net.IPNet, error) {
+	value = strings.TrimSpace(value)
+
+	if first, last, isRange := strings.Cut(value, "-"); isRange {
+		firstIP := net.ParseIP(strings.TrimSpace(first))
+		lastIP := net.ParseIP(strings.TrimSpace(last))
+		if firstIP == nil || lastIP == nil {
+			return nil, fmt.Errorf("invalid IP range %s", value)
+		}
+		return RangeToCIDRs(firstIP, lastIP)
+	}
+
+	if strings.Contains(value, "/") {
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		return []*net.IPNet{network}, nil
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid network %s, expected a CIDR, an IP address or a first-last IP range", value)
+	}
+	bits := 8 * net.IPv6len
+	if ipv4 := ip.To4(); ipv4 != nil {
+		ip = ipv4
+		bits = 8 * net.IPv4len
+	}
+	return []*net.IPNet{{IP: ip, Mask: net.CIDRMask(bits, bits)}}, nil
+}
+
+// RangeToCIDRs returns the minimal set of CIDRs covering the inclusive range
+// of addresses from first to last.
+func RangeToCIDRs(first, last net.IP) ([]*net.IPNet, error) {
+	firstAddr, firstOk := netip.AddrFromSlice(first)
+	lastAddr, lastOk := netip.AddrFromSlice(last)
+	if !firstOk || !lastOk {
+		return nil, fmt.Errorf("invalid IP range %s-%s", first, last)
+	}
+	firstAddr, lastAddr = firstAddr.Unmap(), lastAddr.Unmap()
+
+	if firstAddr.Is4() != lastAddr.Is4() {
+		return nil, fmt.Errorf("IP range %s-%s mixes IPv4 and IPv6 addresses", first, last)
+	}
+	if lastAddr.Less(firstAddr) {
+		return nil, fmt.Errorf("IP range %s-%s ends before it starts", first, last)
+	}
+
+	var networks []*net.IPNet
+	for {
+		// The largest network starting at firstAddr that ends within the range
+		bits := firstAddr.BitLen()
+		prefix := netip.PrefixFrom(firstAddr, bits)
+		for prefixLength := 0; prefixLength < bits; prefixLength++ {
+			candidate := netip.PrefixFrom(firstAddr, prefixLength).Masked()
+			if candidate.Addr() == firstAddr && !lastAddr.Less(lastAddrOf(candidate)) {
+				prefix = candidate
+				break
+			}
+		}
+
+		networks = append(networks, &net.IPNet{
+			IP:   net.IP(prefix.Addr().AsSlice()),
+			Mask: net.CIDRMask(prefix.Bits(), bits),
+		})
+
+		end := lastAddrOf(prefix)
+		if end == lastAddr {
+			return networks, nil
+		}
+		firstAddr = end.Next()
+	}
+}
+
+// lastAddrOf returns the last address of prefix.
+func lastAddrOf(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(bytes)*8; i++ {
+		bytes[i/8] |= 0x80 >> (i % 8)
+	}
+	addr, _ := netip.AddrFromSlice(bytes)
+	return addr
+}
+
+// FormatNetworks joins the CIDRs of networks for display.
+func FormatNetworks(networks []*net.IPNet) string {
+	cidrs := make([]string, 0, len(networks))
+	for _, network := range networks {
+		cidrs = append(cidrs, network.String())
+	}
+	return strings.Join(cidrs, ", ")
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mmdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNetwork(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "IPv4 CIDR", value: "10.0.0.0/24", want: "10.0.0.0/24"},
+		{name: "IPv6 CIDR", value: "2001:db8::/32", want: "2001:db8::/32"},
+		{name: "bare IPv4", value: "10.0.0.5", want: "10.0.0.5/32"},
+		{name: "bare IPv6", value: "2001:db8::1", want: "2001:db8::1/128"},
+		{name: "aligned range", value: "10.0.0.0-10.0.3.255", want: "10.0.0.0/22"},
+		{name: "unaligned range", value: "10.0.0.5-10.0.0.20", want: "10.0.0.5/32, 10.0.0.6/31, 10.0.0.8/29, 10.0.0.16/30, 10.0.0.20/32"},
+		{name: "range with spaces", value: "10.0.0.0 - 10.0.0.1", want: "10.0.0.0/31"},
+		{name: "single address range", value: "10.0.0.7-10.0.0.7", want: "10.0.0.7/32"},
+		{name: "whole IPv4 space", value: "0.0.0.0-255.255.255.255", want: "0.0.0.0/0"},
+		{name: "IPv6 range", value: "2001:db8::-2001:db8::1:ffff", want: "2001:db8::/111"},
+		{name: "reversed range", value: "10.0.0.9-10.0.0.1", wantErr: true},
+		{name: "mixed families", value: "10.0.0.0-2001:db8::1", wantErr: true},
+		{name: "invalid range", value: "10.0.0.0-foo", wantErr: true},
+		{name: "invalid CIDR", value: "10.0.0.0/33", wantErr: true},
+		{name: "invalid address", value: "not-a-network", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			networks, err := ParseNetwork(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, FormatNetworks(networks))
+		})
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mmdb
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/InfraZ/mmdb-cli/internal/files"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// DatabaseExtensions are the extensions of the database files OpenDatabase
+// reads, plain or compressed with gzip or zstd.
+var DatabaseExtensions = files.WithCompression(".mmdb")
+
+// OpenDatabase opens the database file at path, or reads the whole database
+// from the standard input when path is files.Stdio. Compressed databases are
+// decompressed to memory.
+func OpenDatabase(path string) (*maxminddb.Reader, error) {
+	if !files.IsStdio(path) && files.Compression(path) == "" {
+		return maxminddb.Open(path)
+	}
+
+	source, err := files.OpenDecompressed(path)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	content, err := io.ReadAll(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database %s: %w", path, err)
+	}
+	return maxminddb.FromBytes(content)
+}
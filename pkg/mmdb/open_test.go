@@ -0,0 +1,60 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mmdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/InfraZ/mmdb-cli/internal/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenDatabase(t *testing.T) {
+	t.Parallel()
+
+	content, err := os.ReadFile("../../test/metadata.mmdb")
+	require.NoError(t, err)
+
+	for _, compression := range []string{"", files.ExtensionGzip, files.ExtensionZstd} {
+		t.Run("compression "+compression, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "metadata.mmdb"+compression)
+			output, err := os.Create(path)
+			require.NoError(t, err)
+			writer, err := files.Compress(output, compression)
+			require.NoError(t, err)
+			_, err = writer.Write(content)
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+			require.NoError(t, output.Close())
+
+			db, err := OpenDatabase(path)
+			require.NoError(t, err)
+			defer db.Close()
+
+			assert.Equal(t, "Metadata Test", db.Metadata.DatabaseType)
+			assert.NoError(t, db.Verify())
+		})
+	}
+
+	_, err = OpenDatabase(filepath.Join(t.TempDir(), "missing.mmdb.gz"))
+	assert.Error(t, err)
+}
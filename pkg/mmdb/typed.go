@@ -0,0 +1,438 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mmdb
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// MaxExactFloat64 is the largest integer below which every integer can be
+// represented by a float64, i.e. read back from JSON without losing precision.
+const MaxExactFloat64 = 1 << 53
+
+// TypedRecord decodes an MMDB record while keeping the MMDB type of every
+// value. It implements the deserializer interface of maxminddb-golang, so a
+// pointer to it can be passed to Networks.Network or Reader.Lookup in place
+// of a map.
+type TypedRecord struct {
+	value mmdbtype.DataType
+	stack []*typedContainer
+}
+
+type typedContainer struct {
+	isMap  bool
+	m      mmdbtype.Map
+	s      mmdbtype.Slice
+	key    mmdbtype.String
+	hasKey bool
+}
+
+// Value returns the decoded record.
+func (r *TypedRecord) Value() mmdbtype.DataType {
+	return r.value
+}
+
+func (r *TypedRecord) add(value mmdbtype.DataType) error {
+	if len(r.stack) == 0 {
+		r.value = value
+		return nil
+	}
+
+	top := r.stack[len(r.stack)-1]
+	if !top.isMap {
+		top.s = append(top.s, value)
+		return nil
+	}
+
+	if !top.hasKey {
+		key, ok := value.(mmdbtype.String)
+		if !ok {
+			return fmt.Errorf("unexpected map key of type %T", value)
+		}
+		top.key = key
+		top.hasKey = true
+		return nil
+	}
+
+	top.m[top.key] = value
+	top.hasKey = false
+	return nil
+}
+
+func (r *TypedRecord) ShouldSkip(offset uintptr) (bool, error) { return false, nil }
+
+func (r *TypedRecord) StartSlice(size uint) error {
+	r.stack = append(r.stack, &typedContainer{s: make(mmdbtype.Slice, 0, size)})
+	return nil
+}
+
+func (r *TypedRecord) StartMap(size uint) error {
+	r.stack = append(r.stack, &typedContainer{isMap: true, m: make(mmdbtype.Map, size)})
+	return nil
+}
+
+func (r *TypedRecord) End() error {
+	if len(r.stack) == 0 {
+		return fmt.Errorf("unexpected end of map or slice")
+	}
+
+	top := r.stack[len(r.stack)-1]
+	r.stack = r.stack[:len(r.stack)-1]
+	if top.isMap {
+		return r.add(top.m)
+	}
+	return r.add(top.s)
+}
+
+func (r *TypedRecord) String(v string) error   { return r.add(mmdbtype.String(v)) }
+func (r *TypedRecord) Float64(v float64) error { return r.add(mmdbtype.Float64(v)) }
+func (r *TypedRecord) Bytes(v []byte) error    { return r.add(mmdbtype.Bytes(append([]byte{}, v...))) }
+func (r *TypedRecord) Uint16(v uint16) error   { return r.add(mmdbtype.Uint16(v)) }
+func (r *TypedRecord) Uint32(v uint32) error   { return r.add(mmdbtype.Uint32(v)) }
+func (r *TypedRecord) Int32(v int32) error     { return r.add(mmdbtype.Int32(v)) }
+func (r *TypedRecord) Uint64(v uint64) error   { return r.add(mmdbtype.Uint64(v)) }
+func (r *TypedRecord) Uint128(v *big.Int) error {
+	return r.add((*mmdbtype.Uint128)(new(big.Int).Set(v)))
+}
+func (r *TypedRecord) Bool(v bool) error       { return r.add(mmdbtype.Bool(v)) }
+func (r *TypedRecord) Float32(v float32) error { return r.add(mmdbtype.Float32(v)) }
+
+// ToJSONValue converts an MMDB value into a value that encoding/json writes
+// in the form expected by the dataset schema: uint64 values that do not fit
+// in a float64 and all uint128 values become decimal strings, and bytes are
+// written as base64 by encoding/json.
+func ToJSONValue(value mmdbtype.DataType) interface{} {
+	switch v := value.(type) {
+	case mmdbtype.String:
+		return string(v)
+	case mmdbtype.Bool:
+		return bool(v)
+	case mmdbtype.Float64:
+		return float64(v)
+	case mmdbtype.Float32:
+		return float32(v)
+	case mmdbtype.Int32:
+		return int(v)
+	case mmdbtype.Uint16:
+		return uint64(v)
+	case mmdbtype.Uint32:
+		return uint64(v)
+	case mmdbtype.Uint64:
+		if v > MaxExactFloat64 {
+			return fmt.Sprintf("%d", uint64(v))
+		}
+		return uint64(v)
+	case *mmdbtype.Uint128:
+		return (*big.Int)(v).String()
+	case mmdbtype.Bytes:
+		return []byte(v)
+	case mmdbtype.Map:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[string(key)] = ToJSONValue(item)
+		}
+		return m
+	case mmdbtype.Slice:
+		s := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			s = append(s, ToJSONValue(item))
+		}
+		return s
+	default:
+		return nil
+	}
+}
+
+// SchemaOf returns the dataset schema describing value: a type name for
+// scalars, an object schema for maps and a single-element list for arrays.
+// Array elements of different types get the widest of them, and arrays whose
+// element types cannot be widened get an empty list, which makes generate
+// fall back to the default conversion for them.
+func SchemaOf(value mmdbtype.DataType) interface{} {
+	return stripConflicts(schemaOf(value, "", nil))
+}
+
+func schemaOf(value mmdbtype.DataType, path string, conflicts map[string]bool) interface{} {
+	switch v := value.(type) {
+	case mmdbtype.Map:
+		schema := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			schema[string(key)] = schemaOf(item, joinPath(path, string(key)), conflicts)
+		}
+		return schema
+	case mmdbtype.Slice:
+		var element interface{}
+		for _, item := range v {
+			merged, ok := mergeSchema(element, schemaOf(item, path+"[]", conflicts), path+"[]", conflicts)
+			if !ok {
+				if conflicts != nil {
+					conflicts[path+"[]"] = true
+				}
+				return []interface{}{conflictMarker{}}
+			}
+			element = merged
+		}
+		if element == nil {
+			return []interface{}{}
+		}
+		return []interface{}{element}
+	default:
+		return TypeName(value)
+	}
+}
+
+// TypeName returns the schema type name of a scalar MMDB value.
+func TypeName(value mmdbtype.DataType) string {
+	switch value.(type) {
+	case mmdbtype.String:
+		return "string"
+	case mmdbtype.Bool:
+		return "bool"
+	case mmdbtype.Float64:
+		return "float64"
+	case mmdbtype.Float32:
+		return "float32"
+	case mmdbtype.Int32:
+		return "int32"
+	case mmdbtype.Uint16:
+		return "uint16"
+	case mmdbtype.Uint32:
+		return "uint32"
+	case mmdbtype.Uint64:
+		return "uint64"
+	case *mmdbtype.Uint128:
+		return "uint128"
+	case mmdbtype.Bytes:
+		return "bytes"
+	case mmdbtype.Map:
+		return "object"
+	case mmdbtype.Slice:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// unsignedRank orders the unsigned integer types from narrowest to widest.
+var unsignedRank = map[string]int{"uint16": 1, "uint32": 2, "uint64": 3, "uint128": 4}
+
+// WidenType returns the narrowest type able to hold values of both types
+// without losing information, or false when there is none.
+func WidenType(a, b string) (string, bool) {
+	if a == b {
+		return a, true
+	}
+
+	rankA, unsignedA := unsignedRank[a]
+	rankB, unsignedB := unsignedRank[b]
+	switch {
+	case unsignedA && unsignedB:
+		if rankA > rankB {
+			return a, true
+		}
+		return b, true
+	case a == "int32" && b == "uint16", a == "uint16" && b == "int32":
+		return "int32", true
+	case a == "int32" && fitsFloat64(b), b == "int32" && fitsFloat64(a):
+		// A signed type with a wider unsigned type, which float64 holds
+		// exactly
+		return "float64", true
+	case isFloat(a) && isFloat(b):
+		return "float64", true
+	case isFloat(a) && fitsFloat64(b), isFloat(b) && fitsFloat64(a):
+		return "float64", true
+	}
+
+	return "", false
+}
+
+func isFloat(t string) bool {
+	return t == "float32" || t == "float64"
+}
+
+// fitsFloat64 reports whether every value of the integer type t can be held
+// by a float64 without losing precision.
+func fitsFloat64(t string) bool {
+	return t == "uint16" || t == "uint32" || t == "int32"
+}
+
+// SchemaBuilder accumulates the schema of many records. Keys whose types
+// differ between records get the widest of them, see WidenType. Keys whose
+// types cannot be widened, such as a string and a number, are left out of the
+// final schema and reported as conflicts, so generate converts them with the
+// default conversion.
+type SchemaBuilder struct {
+	schema    map[string]interface{}
+	conflicts map[string]bool
+}
+
+// conflictMarker takes the place of a key or array element whose type differs
+// between records, so that later records cannot add it back to the schema.
+type conflictMarker struct{}
+
+func NewSchemaBuilder() *SchemaBuilder {
+	return &SchemaBuilder{
+		schema:    map[string]interface{}{},
+		conflicts: map[string]bool{},
+	}
+}
+
+// Add merges the schema of a record into the builder.
+func (b *SchemaBuilder) Add(record mmdbtype.Map) {
+	merged, _ := mergeSchema(b.schema, schemaOf(record, "", b.conflicts), "", b.conflicts)
+	b.schema = merged.(map[string]interface{})
+}
+
+// AddSchema merges a dataset schema into the builder, so that the schemas of
+// several datasets can be combined. The keys declared with types that cannot
+// be widened are reported as conflicts.
+func (b *SchemaBuilder) AddSchema(schema map[string]interface{}) {
+	merged, _ := mergeSchema(b.schema, copySchema(schema), "", b.conflicts)
+	b.schema = merged.(map[string]interface{})
+}
+
+// Schema returns the merged schema without the conflicting keys.
+func (b *SchemaBuilder) Schema() map[string]interface{} {
+	return stripConflicts(b.schema).(map[string]interface{})
+}
+
+// Conflicts returns the sorted key paths whose types across records cannot
+// be widened to a common type.
+func (b *SchemaBuilder) Conflicts() []string {
+	conflicts := make([]string, 0, len(b.conflicts))
+	for path := range b.conflicts {
+		conflicts = append(conflicts, path)
+	}
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+// mergeSchema merges two schemas and reports false when they cannot be
+// merged at this level. Conflicting keys of nested objects are replaced with
+// a conflictMarker and recorded in conflicts when it is not nil.
+func mergeSchema(a, b interface{}, path string, conflicts map[string]bool) (interface{}, bool) {
+	if a == nil {
+		return b, true
+	}
+	if b == nil {
+		return a, true
+	}
+	if _, isConflict := a.(conflictMarker); isConflict {
+		return a, true
+	}
+	if _, isConflict := b.(conflictMarker); isConflict {
+		return b, true
+	}
+
+	switch aValue := a.(type) {
+	case string:
+		if bValue, ok := b.(string); ok {
+			if widest, ok := WidenType(aValue, bValue); ok {
+				return widest, true
+			}
+		}
+	case map[string]interface{}:
+		bValue, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for key, bSchema := range bValue {
+			aSchema, exists := aValue[key]
+			if !exists {
+				aValue[key] = bSchema
+				continue
+			}
+			merged, ok := mergeSchema(aSchema, bSchema, joinPath(path, key), conflicts)
+			if !ok {
+				if conflicts != nil {
+					conflicts[joinPath(path, key)] = true
+				}
+				aValue[key] = conflictMarker{}
+				continue
+			}
+			aValue[key] = merged
+		}
+		return aValue, true
+	case []interface{}:
+		bValue, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		if len(aValue) == 0 {
+			return b, true
+		}
+		if len(bValue) == 0 {
+			return a, true
+		}
+		merged, ok := mergeSchema(aValue[0], bValue[0], path+"[]", conflicts)
+		if !ok {
+			return nil, false
+		}
+		return []interface{}{merged}, true
+	}
+
+	return nil, false
+}
+
+// copySchema returns a deep copy of schema, as merging schemas modifies their
+// objects.
+func copySchema(schema interface{}) interface{} {
+	switch v := schema.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			copied[key] = copySchema(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copySchema(item)
+		}
+		return copied
+	default:
+		return schema
+	}
+}
+
+func stripConflicts(schema interface{}) interface{} {
+	switch v := schema.(type) {
+	case map[string]interface{}:
+		stripped := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if _, isConflict := item.(conflictMarker); isConflict {
+				continue
+			}
+			stripped[key] = stripConflicts(item)
+		}
+		return stripped
+	case []interface{}:
+		if len(v) == 0 {
+			return v
+		}
+		if _, isConflict := v[0].(conflictMarker); isConflict {
+			return []interface{}{}
+		}
+		return []interface{}{stripConflicts(v[0])}
+	default:
+		return schema
+	}
+}
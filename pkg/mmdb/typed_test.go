@@ -0,0 +1,237 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mmdb
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedRecord(t *testing.T) {
+	t.Parallel()
+
+	var record TypedRecord
+	require.NoError(t, record.StartMap(2))
+	require.NoError(t, record.String("asn"))
+	require.NoError(t, record.Uint32(13335))
+	require.NoError(t, record.String("tags"))
+	require.NoError(t, record.StartSlice(2))
+	require.NoError(t, record.String("cdn"))
+	require.NoError(t, record.Uint16(1))
+	require.NoError(t, record.End())
+	require.NoError(t, record.End())
+
+	want := mmdbtype.Map{
+		"asn":  mmdbtype.Uint32(13335),
+		"tags": mmdbtype.Slice{mmdbtype.String("cdn"), mmdbtype.Uint16(1)},
+	}
+	assert.True(t, want.Equal(record.Value()), "got %v, want %v", record.Value(), want)
+
+	t.Run("non-string map key", func(t *testing.T) {
+		t.Parallel()
+		var record TypedRecord
+		require.NoError(t, record.StartMap(1))
+		assert.Error(t, record.Uint32(1))
+	})
+
+	t.Run("unbalanced end", func(t *testing.T) {
+		t.Parallel()
+		var record TypedRecord
+		assert.Error(t, record.End())
+	})
+}
+
+func TestToJSONValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value mmdbtype.DataType
+		want  interface{}
+	}{
+		{name: "uint32", value: mmdbtype.Uint32(42), want: uint64(42)},
+		{name: "int32", value: mmdbtype.Int32(-42), want: -42},
+		{name: "small uint64", value: mmdbtype.Uint64(42), want: uint64(42)},
+		{name: "large uint64", value: mmdbtype.Uint64(18446744073709551615), want: "18446744073709551615"},
+		{name: "uint128", value: (*mmdbtype.Uint128)(big.NewInt(255)), want: "255"},
+		{name: "float32", value: mmdbtype.Float32(0.5), want: float32(0.5)},
+		{name: "bytes", value: mmdbtype.Bytes("hi"), want: []byte("hi")},
+		{
+			name:  "map with slice",
+			value: mmdbtype.Map{"tags": mmdbtype.Slice{mmdbtype.String("cdn")}},
+			want:  map[string]interface{}{"tags": []interface{}{"cdn"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, ToJSONValue(tt.value))
+		})
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value mmdbtype.DataType
+		want  interface{}
+	}{
+		{
+			name:  "scalar",
+			value: mmdbtype.Uint16(1),
+			want:  "uint16",
+		},
+		{
+			name: "nested map",
+			value: mmdbtype.Map{
+				"country": mmdbtype.Map{"geoname_id": mmdbtype.Uint32(1), "iso_code": mmdbtype.String("US")},
+			},
+			want: map[string]interface{}{
+				"country": map[string]interface{}{"geoname_id": "uint32", "iso_code": "string"},
+			},
+		},
+		{
+			name:  "typed array",
+			value: mmdbtype.Slice{mmdbtype.Uint32(1), mmdbtype.Uint32(2)},
+			want:  []interface{}{"uint32"},
+		},
+		{
+			name: "array of maps with different keys",
+			value: mmdbtype.Slice{
+				mmdbtype.Map{"a": mmdbtype.String("x")},
+				mmdbtype.Map{"b": mmdbtype.Int32(1)},
+			},
+			want: []interface{}{map[string]interface{}{"a": "string", "b": "int32"}},
+		},
+		{
+			name:  "array of widened integers",
+			value: mmdbtype.Slice{mmdbtype.Uint16(1), mmdbtype.Uint32(70000)},
+			want:  []interface{}{"uint32"},
+		},
+		{
+			name:  "mixed array",
+			value: mmdbtype.Slice{mmdbtype.String("a"), mmdbtype.Uint32(2)},
+			want:  []interface{}{},
+		},
+		{
+			name:  "empty array",
+			value: mmdbtype.Slice{},
+			want:  []interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, SchemaOf(tt.value))
+		})
+	}
+}
+
+func TestWidenType(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		a, b   string
+		want   string
+		wantOk bool
+	}{
+		{"uint16", "uint16", "uint16", true},
+		{"uint16", "uint32", "uint32", true},
+		{"uint64", "uint32", "uint64", true},
+		{"uint128", "uint16", "uint128", true},
+		{"int32", "uint16", "int32", true},
+		{"int32", "uint32", "float64", true},
+		{"uint32", "int32", "float64", true},
+		{"int32", "uint64", "", false},
+		{"uint128", "int32", "", false},
+		{"float32", "float64", "float64", true},
+		{"uint32", "float64", "float64", true},
+		{"uint64", "float64", "", false},
+		{"string", "uint16", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := WidenType(tt.a, tt.b)
+		assert.Equal(t, tt.wantOk, ok, "WidenType(%s, %s)", tt.a, tt.b)
+		assert.Equal(t, tt.want, got, "WidenType(%s, %s)", tt.a, tt.b)
+	}
+}
+
+func TestSchemaBuilder(t *testing.T) {
+	t.Parallel()
+
+	builder := NewSchemaBuilder()
+	builder.Add(mmdbtype.Map{
+		"asn":  mmdbtype.Uint32(1),
+		"name": mmdbtype.String("a"),
+		"tags": mmdbtype.Slice{},
+	})
+	builder.Add(mmdbtype.Map{
+		"asn":  mmdbtype.String("AS1"),
+		"tags": mmdbtype.Slice{mmdbtype.String("cdn")},
+		"location": mmdbtype.Map{
+			"accuracy_radius": mmdbtype.Uint16(10),
+		},
+	})
+	builder.Add(mmdbtype.Map{
+		"asn": mmdbtype.Uint32(2),
+		"location": mmdbtype.Map{
+			"accuracy_radius": mmdbtype.Uint32(10),
+		},
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"name":     "string",
+		"tags":     []interface{}{"string"},
+		"location": map[string]interface{}{"accuracy_radius": "uint32"},
+	}, builder.Schema())
+	assert.Equal(t, []string{"asn"}, builder.Conflicts())
+}
+
+func TestSchemaBuilderAddSchema(t *testing.T) {
+	t.Parallel()
+
+	base := map[string]interface{}{
+		"asn":     "uint32",
+		"country": map[string]interface{}{"iso_code": "string"},
+	}
+	builder := NewSchemaBuilder()
+	builder.AddSchema(base)
+	builder.AddSchema(map[string]interface{}{
+		"asn":     "uint32",
+		"country": map[string]interface{}{"iso_code": "bool", "name": "string"},
+		"tags":    []interface{}{"string"},
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"asn":     "uint32",
+		"country": map[string]interface{}{"name": "string"},
+		"tags":    []interface{}{"string"},
+	}, builder.Schema())
+	assert.Equal(t, []string{"country.iso_code"}, builder.Conflicts())
+
+	// The added schemas are left untouched
+	assert.Equal(t, map[string]interface{}{"iso_code": "string"}, base["country"])
+}
@@ -20,18 +20,24 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 )
 
 func JsonOutput(data []byte, options OutputOptions) error {
+	return writeJSON(os.Stdout, data, options)
+}
+
+func writeJSON(w io.Writer, data []byte, options OutputOptions) error {
 	if options.JsonPretty {
 		var prettyJSON bytes.Buffer
 		err := json.Indent(&prettyJSON, data, "", "    ")
 		if err != nil {
 			return err
 		}
-		fmt.Println(string(prettyJSON.Bytes()))
+		fmt.Fprintln(w, prettyJSON.String())
 	} else {
-		fmt.Println(string(data))
+		fmt.Fprintln(w, string(data))
 	}
 	return nil
 }
@@ -16,7 +16,11 @@ limitations under the License.
 
 package output
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"os"
+)
 
 type OutputOptions struct {
 	Format     string
@@ -24,6 +28,11 @@ type OutputOptions struct {
 }
 
 func Output(byteData []byte, options OutputOptions) error {
+	return Write(os.Stdout, byteData, options)
+}
+
+// Write writes the JSON document byteData to w in the format of options.
+func Write(w io.Writer, byteData []byte, options OutputOptions) error {
 
 	if (options.JsonPretty) && (options.Format != "json") {
 		return fmt.Errorf("Pretty print is only supported for JSON output")
@@ -36,11 +45,11 @@ func Output(byteData []byte, options OutputOptions) error {
 
 	switch options.Format {
 	case "json":
-		return JsonOutput(byteData, options)
+		return writeJSON(w, byteData, options)
 	case "yaml":
-		return YamlOutput(byteData, options)
+		return writeYAML(w, byteData, options)
 	case "xml":
-		return XmlOutput(byteData, options)
+		return writeXML(w, byteData, options)
 	default:
 		return fmt.Errorf("Unsupported output format: %s", options.Format)
 	}
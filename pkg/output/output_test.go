@@ -152,3 +152,27 @@ func TestOutputYamlContent(t *testing.T) {
 	assert.Contains(t, output, "age: 30")
 	assert.Contains(t, output, "name: John")
 }
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "json", want: "{\"test\":\"data\"}\n"},
+		{format: "json-pretty", want: "{\n    \"test\": \"data\"\n}\n"},
+		{format: "yaml", want: "test: data\n\n"},
+		{format: "xml", want: "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" + "<root>\n  <test>data</test>\n</root>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			require.NoError(t, Write(&buf, []byte(`{"test":"data"}`), OutputOptions{Format: tt.format}))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
@@ -20,19 +20,24 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 )
 
 func XmlOutput(data []byte, options OutputOptions) error {
+	return writeXML(os.Stdout, data, options)
+}
+
+func writeXML(w io.Writer, data []byte, options OutputOptions) error {
 	var jsonData interface{}
 	if err := json.Unmarshal(data, &jsonData); err != nil {
 		return err
 	}
 
-	fmt.Print(xml.Header)
+	fmt.Fprint(w, xml.Header)
 
-	encoder := xml.NewEncoder(os.Stdout)
+	encoder := xml.NewEncoder(w)
 	encoder.Indent("", "  ")
 
 	rootElement := xml.StartElement{Name: xml.Name{Local: "root"}}
@@ -18,11 +18,17 @@ package output
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"gopkg.in/yaml.v3"
 )
 
 func YamlOutput(data []byte, options OutputOptions) error {
+	return writeYAML(os.Stdout, data, options)
+}
+
+func writeYAML(w io.Writer, data []byte, options OutputOptions) error {
 	var jsonData interface{}
 
 	// Unmarshal the JSON data into a Go data structure
@@ -38,7 +44,7 @@ func YamlOutput(data []byte, options OutputOptions) error {
 	}
 
 	// Print the YAML data
-	fmt.Println(string(yamlData))
+	fmt.Fprintln(w, string(yamlData))
 
 	return nil
 }
@@ -0,0 +1,149 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package progress defines how the library operations of mmdb-cli report
+// their progress to the caller.
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Reporter receives the progress of an operation. The operations call it from
+// the goroutine they run on.
+type Reporter interface {
+	// Step is called when the operation starts a new step, such as writing
+	// the database once every record is inserted
+	Step(message string)
+
+	// Record is called for every processed record with its 1-based position,
+	// its network and its data
+	Record(position int, network string, data interface{})
+
+	// Expanded is called before Record when the network of a record is a
+	// range that was expanded into several CIDRs, listed in networks
+	Expanded(position int, network string, networks string)
+
+	// Warning is called for a problem that does not stop the operation. The
+	// warnings are also returned in the result of the operation.
+	Warning(message string)
+}
+
+// Nop is a Reporter ignoring the progress.
+type Nop struct{}
+
+func (Nop) Step(string)                     {}
+func (Nop) Record(int, string, interface{}) {}
+func (Nop) Expanded(int, string, string)    {}
+func (Nop) Warning(string)                  {}
+
+// OrNop returns reporter, or Nop when reporter is nil.
+func OrNop(reporter Reporter) Reporter {
+	if reporter == nil {
+		return Nop{}
+	}
+	return reporter
+}
+
+// Logger is the Reporter of the command line. It logs steps at the info
+// level and warnings at the warn level, and records either at the info level
+// in verbose mode or at the debug level with a count of the records processed
+// shown on Bar.
+type Logger struct {
+	// Log receives the progress, slog.Default() when nil
+	Log *slog.Logger
+
+	Verbose bool
+
+	// Bar shows the number of records processed on a single line, it is nil
+	// unless the progress goes to a terminal, see TerminalBar
+	Bar io.Writer
+
+	// CountFormat formats the number of records processed so far on Bar, and
+	// RecordMessage is the message of the logged records
+	CountFormat   string
+	RecordMessage string
+
+	counting bool
+}
+
+func (l *Logger) log() *slog.Logger {
+	if l.Log == nil {
+		return slog.Default()
+	}
+	return l.Log
+}
+
+func (l *Logger) Step(message string) {
+	l.EndLine()
+	l.log().Info(message)
+}
+
+func (l *Logger) Record(position int, network string, data interface{}) {
+	level := slog.LevelDebug
+	if l.Verbose {
+		level = slog.LevelInfo
+	}
+	if l.log().Enabled(context.Background(), level) {
+		l.EndLine()
+		l.log().Log(context.Background(), level, l.RecordMessage, "position", position, "network", network, "data", data)
+		return
+	}
+	if l.Bar != nil {
+		fmt.Fprintf(l.Bar, "\r"+l.CountFormat, position)
+		l.counting = true
+	}
+}
+
+// Expanded logs the CIDRs of an expanded range at the level of the records.
+func (l *Logger) Expanded(position int, network string, networks string) {
+	level := slog.LevelDebug
+	if l.Verbose {
+		level = slog.LevelInfo
+	}
+	if l.log().Enabled(context.Background(), level) {
+		l.EndLine()
+		l.log().Log(context.Background(), level, "Network expanded", "position", position, "network", network, "networks", networks)
+	}
+}
+
+func (l *Logger) Warning(message string) {
+	l.EndLine()
+	l.log().Warn(message)
+}
+
+// EndLine ends the line of the record count, if any, so that the next log
+// starts on a line of its own.
+func (l *Logger) EndLine() {
+	if l.counting {
+		fmt.Fprintln(l.Bar)
+		l.counting = false
+	}
+}
+
+// TerminalBar returns f when it is a terminal, for use as the Bar of a
+// Logger, and nil otherwise so that redirected output holds no progress bar.
+func TerminalBar(f *os.File) io.Writer {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	return f
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package progress
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLogger(level slog.Level) (*slog.Logger, *bytes.Buffer) {
+	var logs bytes.Buffer
+	handler := slog.NewTextHandler(&logs, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if attr.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return attr
+		},
+	})
+	return slog.New(handler), &logs
+}
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+
+	t.Run("counts records on the bar", func(t *testing.T) {
+		t.Parallel()
+
+		log, logs := newTestLogger(slog.LevelInfo)
+		var bar bytes.Buffer
+		logger := &Logger{Log: log, Bar: &bar, CountFormat: "%d records"}
+		logger.Step("start")
+		logger.Record(1, "1.0.0.0/24", nil)
+		logger.Record(2, "2.0.0.0/24", nil)
+		logger.Warning("careful")
+		logger.Record(3, "3.0.0.0/24", nil)
+		logger.EndLine()
+		logger.EndLine()
+
+		assert.Equal(t, "level=INFO msg=start\nlevel=WARN msg=careful\n", logs.String())
+		assert.Equal(t, "\r1 records\r2 records\n\r3 records\n", bar.String())
+	})
+
+	t.Run("logs every record in verbose mode", func(t *testing.T) {
+		t.Parallel()
+
+		log, logs := newTestLogger(slog.LevelInfo)
+		var bar bytes.Buffer
+		logger := &Logger{Log: log, Bar: &bar, Verbose: true, RecordMessage: "Record inserted"}
+		logger.Record(1, "1.0.0.0/24", "a")
+
+		assert.Equal(t, "level=INFO msg=\"Record inserted\" position=1 network=1.0.0.0/24 data=a\n", logs.String())
+		assert.Empty(t, bar.String())
+	})
+
+	t.Run("logs expanded ranges in verbose mode", func(t *testing.T) {
+		t.Parallel()
+
+		log, logs := newTestLogger(slog.LevelInfo)
+		logger := &Logger{Log: log, Verbose: true}
+		logger.Expanded(1, "1.0.0.0-1.0.2.255", "1.0.0.0/23, 1.0.2.0/24")
+
+		assert.Equal(t, "level=INFO msg=\"Network expanded\" position=1 network=1.0.0.0-1.0.2.255 networks=\"1.0.0.0/23, 1.0.2.0/24\"\n", logs.String())
+
+		log, logs = newTestLogger(slog.LevelInfo)
+		logger = &Logger{Log: log}
+		logger.Expanded(1, "1.0.0.0-1.0.2.255", "1.0.0.0/23, 1.0.2.0/24")
+		assert.Empty(t, logs.String())
+	})
+
+	t.Run("logs every record at the debug level", func(t *testing.T) {
+		t.Parallel()
+
+		log, logs := newTestLogger(slog.LevelDebug)
+		logger := &Logger{Log: log, RecordMessage: "Record inserted"}
+		logger.Record(1, "1.0.0.0/24", "a")
+
+		assert.Contains(t, logs.String(), "level=DEBUG")
+	})
+
+	t.Run("no bar without a terminal", func(t *testing.T) {
+		t.Parallel()
+
+		log, logs := newTestLogger(slog.LevelInfo)
+		logger := &Logger{Log: log}
+		logger.Record(1, "1.0.0.0/24", nil)
+		logger.EndLine()
+
+		assert.Empty(t, logs.String())
+	})
+}
+
+func TestTerminalBar(t *testing.T) {
+	t.Parallel()
+
+	file, err := os.CreateTemp(t.TempDir(), "progress")
+	assert.NoError(t, err)
+	defer file.Close()
+
+	assert.Nil(t, TerminalBar(file))
+}
+
+func TestOrNop(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, Nop{}, OrNop(nil))
+
+	logger := &Logger{}
+	assert.Same(t, logger, OrNop(logger))
+}
@@ -0,0 +1,265 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
+	"github.com/InfraZ/mmdb-cli/pkg/verify"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// generation is a reader of the database file, closed once it has been
+// replaced and its last lookup released it.
+type generation struct {
+	reader    *maxminddb.Reader
+	refs      atomic.Int64
+	retired   atomic.Bool
+	closeOnce sync.Once
+}
+
+func (g *generation) release() {
+	if g.refs.Add(-1) == 0 && g.retired.Load() {
+		g.close()
+	}
+}
+
+// retire closes the reader right away when no lookup holds it, otherwise the
+// last release closes it.
+func (g *generation) retire() {
+	g.retired.Store(true)
+	if g.refs.Load() == 0 {
+		g.close()
+	}
+}
+
+func (g *generation) close() {
+	g.closeOnce.Do(func() {
+		if err := g.reader.Close(); err != nil {
+			slog.Warn("Failed to close replaced database reader", "error", err)
+		}
+	})
+}
+
+// fileState identifies a version of a database file, a replaced file having
+// a different identity, size or modification time.
+type fileState struct {
+	info os.FileInfo
+}
+
+func (s fileState) changed(info os.FileInfo) bool {
+	return s.info == nil || !os.SameFile(s.info, info) || s.info.Size() != info.Size() || !s.info.ModTime().Equal(info.ModTime())
+}
+
+// Database is a database whose reader is swapped for a new one when its file
+// is replaced. The lookups in flight keep the reader they acquired, which is
+// closed once the last of them released it.
+type Database struct {
+	path    string
+	current atomic.Pointer[generation]
+	reloads atomic.Int64
+
+	// mu serializes the reloads and guards state, closed and the start of
+	// the watchers
+	mu     sync.Mutex
+	state  fileState
+	closed bool
+
+	// stop is closed by Close to stop the watchers, which Close waits for
+	stop     chan struct{}
+	watchers sync.WaitGroup
+}
+
+// ErrClosed is returned for a database used after it was closed.
+var ErrClosed = errors.New("database is closed")
+
+// Open opens the database file at path.
+func Open(path string) (*Database, error) {
+	d := &Database{path: path, stop: make(chan struct{})}
+	if _, err := d.reload(true); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// New wraps an open reader in a Database that cannot be reloaded.
+func New(reader *maxminddb.Reader) *Database {
+	d := &Database{stop: make(chan struct{})}
+	d.current.Store(&generation{reader: reader})
+	return d
+}
+
+// Path returns the path of the database file, empty when the database cannot
+// be reloaded.
+func (d *Database) Path() string {
+	return d.path
+}
+
+// Acquire returns the current reader of the database, which stays open until
+// release is called, or ErrClosed once the database is closed.
+func (d *Database) Acquire() (reader *maxminddb.Reader, release func(), err error) {
+	for {
+		current := d.current.Load()
+		if current == nil {
+			return nil, nil, ErrClosed
+		}
+		current.refs.Add(1)
+		// The reader may have been swapped and retired in between, in which
+		// case the new one is acquired instead
+		if d.current.Load() == current {
+			return current.reader, current.release, nil
+		}
+		current.release()
+	}
+}
+
+// Reloads returns the number of times the database file was reloaded since it
+// was opened.
+func (d *Database) Reloads() int64 {
+	return d.reloads.Load()
+}
+
+// BuildEpoch returns the build timestamp of the current reader, zero once the
+// database is closed.
+func (d *Database) BuildEpoch() uint {
+	reader, release, err := d.Acquire()
+	if err != nil {
+		return 0
+	}
+	defer release()
+	return reader.Metadata.BuildEpoch
+}
+
+// Reload verifies the database file and swaps the current reader for a new
+// one, even if the file did not change. The current reader is kept when the
+// file is invalid.
+func (d *Database) Reload() error {
+	_, err := d.reload(true)
+	return err
+}
+
+// ReloadIfChanged reloads the database file when it was replaced or modified
+// since it was last loaded, and reports whether it was reloaded. A file that
+// failed to load is only tried again once it changes.
+func (d *Database) ReloadIfChanged() (bool, error) {
+	return d.reload(false)
+}
+
+func (d *Database) reload(force bool) (bool, error) {
+	if d.path == "" {
+		return false, fmt.Errorf("the database has no file to reload")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return false, ErrClosed
+	}
+
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read database file %s: %w", d.path, err)
+	}
+	if !force && !d.state.changed(info) {
+		return false, nil
+	}
+	d.state = fileState{info: info}
+
+	if _, err := verify.VerifyMMDB(verify.CmdVerifyConfig{InputFile: d.path}); err != nil {
+		return false, fmt.Errorf("invalid database file %s: %w", d.path, err)
+	}
+	reader, err := mmdb.OpenDatabase(d.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open database file %s: %w", d.path, err)
+	}
+
+	previous := d.current.Swap(&generation{reader: reader})
+	if previous != nil {
+		previous.retire()
+		d.reloads.Add(1)
+	}
+
+	return true, nil
+}
+
+// Watch reloads the database file every time it changes, checking it at
+// every interval until ctx is done or the database is closed. Failed reloads
+// are logged and the current reader is kept.
+func (d *Database) Watch(ctx context.Context, interval time.Duration) {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.watchers.Add(1)
+	d.mu.Unlock()
+	defer d.watchers.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := d.ReloadIfChanged()
+		if errors.Is(err, ErrClosed) {
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to reload database", "path", d.path, "error", err)
+			continue
+		}
+		if reloaded {
+			slog.Info("Database reloaded", "path", d.path, "build_epoch", d.BuildEpoch(), "reloads", d.Reloads())
+		}
+	}
+}
+
+// Close stops the watchers and closes the current reader once the lookups in
+// flight released it. The database cannot be acquired or reloaded afterwards.
+func (d *Database) Close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	close(d.stop)
+	d.mu.Unlock()
+
+	d.watchers.Wait()
+
+	if current := d.current.Swap(nil); current != nil {
+		current.retire()
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reload
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	inspectMMDB  = "../../test/inspect.mmdb"
+	metadataMMDB = "../../test/metadata.mmdb"
+	invalidMMDB  = "../../test/verify-invalid.mmdb"
+)
+
+// replaceFile replaces the file at path with a copy of source, the way
+// database updates are usually deployed.
+func replaceFile(t *testing.T, source, path string) {
+	t.Helper()
+	content, err := os.ReadFile(source)
+	require.NoError(t, err)
+	tempPath := path + ".tmp"
+	require.NoError(t, os.WriteFile(tempPath, content, 0644))
+	require.NoError(t, os.Rename(tempPath, path))
+}
+
+func openCopy(t *testing.T, source string) (*Database, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "database.mmdb")
+	replaceFile(t, source, path)
+
+	db, err := Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db, path
+}
+
+func databaseType(db *Database) string {
+	reader, release, err := db.Acquire()
+	if err != nil {
+		return ""
+	}
+	defer release()
+	return reader.Metadata.DatabaseType
+}
+
+func TestReload(t *testing.T) {
+	t.Parallel()
+
+	t.Run("swaps the reader when the file changes", func(t *testing.T) {
+		t.Parallel()
+
+		db, path := openCopy(t, inspectMMDB)
+		assert.Equal(t, "Inspect Test", databaseType(db))
+
+		reloaded, err := db.ReloadIfChanged()
+		require.NoError(t, err)
+		assert.False(t, reloaded)
+
+		// A lookup in flight keeps the reader it acquired
+		previous, release, err := db.Acquire()
+		require.NoError(t, err)
+
+		replaceFile(t, metadataMMDB, path)
+		reloaded, err = db.ReloadIfChanged()
+		require.NoError(t, err)
+		assert.True(t, reloaded)
+		assert.Equal(t, int64(1), db.Reloads())
+		assert.Equal(t, "Metadata Test", databaseType(db))
+
+		var record any
+		assert.NoError(t, previous.Lookup(net.ParseIP("1.1.1.1"), &record))
+		assert.Equal(t, "Inspect Test", previous.Metadata.DatabaseType)
+		release()
+
+		require.NoError(t, db.Reload())
+		assert.Equal(t, int64(2), db.Reloads())
+	})
+
+	t.Run("keeps the reader when the file is invalid", func(t *testing.T) {
+		t.Parallel()
+
+		db, path := openCopy(t, inspectMMDB)
+
+		replaceFile(t, invalidMMDB, path)
+		_, err := db.ReloadIfChanged()
+		assert.Error(t, err)
+		assert.Zero(t, db.Reloads())
+		assert.Equal(t, "Inspect Test", databaseType(db))
+
+		// The invalid file is only tried again once it changes
+		reloaded, err := db.ReloadIfChanged()
+		assert.NoError(t, err)
+		assert.False(t, reloaded)
+	})
+
+	t.Run("watches the file", func(t *testing.T) {
+		t.Parallel()
+
+		db, path := openCopy(t, inspectMMDB)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		watched := make(chan struct{})
+		go func() {
+			db.Watch(ctx, 10*time.Millisecond)
+			close(watched)
+		}()
+
+		replaceFile(t, metadataMMDB, path)
+		assert.Eventually(t, func() bool { return db.Reloads() == 1 }, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, "Metadata Test", databaseType(db))
+
+		cancel()
+		<-watched
+	})
+
+	t.Run("readers without a file cannot be reloaded", func(t *testing.T) {
+		t.Parallel()
+
+		reader, err := mmdb.OpenDatabase(inspectMMDB)
+		require.NoError(t, err)
+		db := New(reader)
+		defer db.Close()
+
+		assert.Empty(t, db.Path())
+		assert.Error(t, db.Reload())
+		assert.Equal(t, "Inspect Test", databaseType(db))
+	})
+
+	t.Run("fails after being closed", func(t *testing.T) {
+		t.Parallel()
+
+		db, _ := openCopy(t, inspectMMDB)
+
+		watched := make(chan struct{})
+		go func() {
+			db.Watch(context.Background(), 10*time.Millisecond)
+			close(watched)
+		}()
+
+		require.NoError(t, db.Close())
+		// Close stops the watchers without their context being canceled
+		<-watched
+
+		_, _, err := db.Acquire()
+		assert.ErrorIs(t, err, ErrClosed)
+		assert.ErrorIs(t, db.Reload(), ErrClosed)
+		assert.Zero(t, db.BuildEpoch())
+		assert.NoError(t, db.Close())
+	})
+
+	_, err := Open(filepath.Join(t.TempDir(), "missing.mmdb"))
+	assert.Error(t, err)
+}
+
+func TestReloadConcurrentLookups(t *testing.T) {
+	t.Parallel()
+
+	db, path := openCopy(t, inspectMMDB)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				reader, release, err := db.Acquire()
+				if !assert.NoError(t, err) {
+					return
+				}
+				var record any
+				assert.NoError(t, reader.Lookup(net.ParseIP("1.1.1.1"), &record))
+				release()
+			}
+		}()
+	}
+
+	sources := []string{metadataMMDB, inspectMMDB}
+	for i := 0; i < 10; i++ {
+		replaceFile(t, sources[i%2], path)
+		require.NoError(t, db.Reload())
+	}
+	cancel()
+	wg.Wait()
+
+	assert.Equal(t, int64(10), db.Reloads())
+}
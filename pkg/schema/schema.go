@@ -0,0 +1,449 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+
+	"github.com/InfraZ/mmdb-cli/internal/files"
+	"github.com/InfraZ/mmdb-cli/pkg/dataset"
+	"github.com/InfraZ/mmdb-cli/pkg/dump"
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+const (
+	FormatDataset    = "dataset"
+	FormatJSONSchema = "json-schema"
+)
+
+type CmdSchemaInferConfig struct {
+	InputFile    string
+	SchemaFormat string
+	SchemaOnly   bool
+}
+
+// PathReport describes the values found at one key path. Array elements are
+// reported under the path of the array followed by [].
+type PathReport struct {
+	Path     string         `json:"path"`
+	Type     string         `json:"type,omitempty"`
+	Types    map[string]int `json:"types"`
+	Records  int            `json:"records"`
+	Presence float64        `json:"presence"`
+	Conflict bool           `json:"conflict"`
+}
+
+type InferResult struct {
+	Records int          `json:"records"`
+	Paths   []PathReport `json:"paths"`
+	Schema  interface{}  `json:"schema"`
+}
+
+// node collects the types observed at one key path across all records.
+type node struct {
+	types      map[string]int
+	records    int
+	lastRecord int
+	children   map[string]*node
+	element    *node
+}
+
+func newNode() *node {
+	return &node{types: map[string]int{}, lastRecord: -1}
+}
+
+func (n *node) child(key string) *node {
+	if n.children == nil {
+		n.children = map[string]*node{}
+	}
+	if _, exists := n.children[key]; !exists {
+		n.children[key] = newNode()
+	}
+	return n.children[key]
+}
+
+// observe records value, either an MMDB value or a value decoded from JSON,
+// as seen in the record with the given index.
+func (n *node) observe(value interface{}, record int) {
+	if n.lastRecord != record {
+		n.records++
+		n.lastRecord = record
+	}
+
+	switch v := value.(type) {
+	case mmdbtype.Map:
+		n.types["object"]++
+		for key, item := range v {
+			n.child(string(key)).observe(item, record)
+		}
+	case map[string]interface{}:
+		n.types["object"]++
+		for key, item := range v {
+			n.child(key).observe(item, record)
+		}
+	case mmdbtype.Slice:
+		n.types["array"]++
+		for _, item := range v {
+			n.elementNode().observe(item, record)
+		}
+	case []interface{}:
+		n.types["array"]++
+		for _, item := range v {
+			n.elementNode().observe(item, record)
+		}
+	case mmdbtype.DataType:
+		n.types[mmdb.TypeName(v)]++
+	default:
+		n.types[jsonTypeName(v)]++
+	}
+}
+
+func (n *node) elementNode() *node {
+	if n.element == nil {
+		n.element = newNode()
+	}
+	return n.element
+}
+
+// jsonTypeName returns the narrowest schema type able to hold a value decoded
+// from a JSON dataset. JSON does not tell integers from floats, so an integral
+// float such as 45.0 is reported as an integer type. This is only safe because
+// resolve widens the integer type with the float types seen at the same path
+// to float64.
+func jsonTypeName(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case float64:
+		if v != math.Trunc(v) || math.IsInf(v, 0) {
+			return "float64"
+		}
+		switch {
+		case v >= 0 && v <= math.MaxUint16:
+			return "uint16"
+		case v >= 0 && v <= math.MaxUint32:
+			return "uint32"
+		case v >= 0 && v <= 1<<53:
+			return "uint64"
+		case v < 0 && v >= math.MinInt32:
+			return "int32"
+		default:
+			return "float64"
+		}
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// resolve returns the widest consistent type of the node and its dataset
+// schema, or false when the observed types conflict.
+func (n *node) resolve() (string, interface{}, bool) {
+	var widest string
+	names := make([]string, 0, len(n.types))
+	for name := range n.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if widest == "" {
+			widest = name
+			continue
+		}
+		if name == "object" || name == "array" || widest == "object" || widest == "array" {
+			return "", nil, false
+		}
+		merged, ok := mmdb.WidenType(widest, name)
+		if !ok {
+			return "", nil, false
+		}
+		widest = merged
+	}
+
+	switch widest {
+	case "object":
+		schema := map[string]interface{}{}
+		for key, child := range n.children {
+			if _, childSchema, ok := child.resolve(); ok {
+				schema[key] = childSchema
+			}
+		}
+		return widest, schema, true
+	case "array":
+		if n.element == nil {
+			return widest, []interface{}{}, true
+		}
+		if _, elementSchema, ok := n.element.resolve(); ok {
+			return widest, []interface{}{elementSchema}, true
+		}
+		return widest, []interface{}{}, true
+	case "null":
+		return "", nil, false
+	default:
+		return widest, widest, true
+	}
+}
+
+// report appends the report of the node and its descendants to reports.
+func (n *node) report(path string, total int, reports []PathReport) []PathReport {
+	widest, _, ok := n.resolve()
+	reports = append(reports, PathReport{
+		Path:     path,
+		Type:     widest,
+		Types:    n.types,
+		Records:  n.records,
+		Presence: math.Round(float64(n.records)/float64(total)*10000) / 100,
+		Conflict: !ok,
+	})
+
+	keys := make([]string, 0, len(n.children))
+	for key := range n.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		childPath := key
+		if path != "" {
+			childPath = path + "." + key
+		}
+		reports = n.children[key].report(childPath, total, reports)
+	}
+
+	if n.element != nil {
+		reports = n.element.report(path+"[]", total, reports)
+	}
+
+	return reports
+}
+
+// ToJSONSchema converts a dataset schema into a JSON Schema describing the
+// records.
+func ToJSONSchema(datasetSchema interface{}) map[string]interface{} {
+	jsonSchema := jsonSchemaOf(datasetSchema)
+	jsonSchema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	return jsonSchema
+}
+
+func jsonSchemaOf(datasetSchema interface{}) map[string]interface{} {
+	switch v := datasetSchema.(type) {
+	case map[string]interface{}:
+		properties := map[string]interface{}{}
+		for key, item := range v {
+			properties[key] = jsonSchemaOf(item)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	case []interface{}:
+		if len(v) == 0 {
+			return map[string]interface{}{"type": "array"}
+		}
+		return map[string]interface{}{"type": "array", "items": jsonSchemaOf(v[0])}
+	case string:
+		switch v {
+		case "string":
+			return map[string]interface{}{"type": "string"}
+		case "bool":
+			return map[string]interface{}{"type": "boolean"}
+		case "float32", "float64":
+			return map[string]interface{}{"type": "number"}
+		case "uint16":
+			return map[string]interface{}{"type": "integer", "minimum": 0, "maximum": math.MaxUint16}
+		case "int32":
+			return map[string]interface{}{"type": "integer", "minimum": math.MinInt32, "maximum": math.MaxInt32}
+		case "uint32":
+			return map[string]interface{}{"type": "integer", "minimum": 0, "maximum": uint32(math.MaxUint32)}
+		case "uint64":
+			return map[string]interface{}{"type": []string{"integer", "string"}, "minimum": 0}
+		case "uint128":
+			return map[string]interface{}{"type": "string", "pattern": "^(0[xX][0-9a-fA-F]+|[0-9]+)$"}
+		case "bytes":
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+	}
+	return map[string]interface{}{}
+}
+
+// addRequired marks the keys found in every record as required in the JSON
+// Schema built from the node.
+func addRequired(jsonSchema map[string]interface{}, n *node) {
+	properties, ok := jsonSchema["properties"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	required := []string{}
+	for key, child := range n.children {
+		childSchema, exists := properties[key].(map[string]interface{})
+		if !exists {
+			continue
+		}
+		if child.records == n.records {
+			required = append(required, key)
+		}
+		addRequired(childSchema, child)
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		jsonSchema["required"] = required
+	}
+}
+
+// observeJSONDataset records the values of every record of the JSON dataset
+// at inputFile in root and returns the number of records.
+func observeJSONDataset(inputFile string, root *node) (int, error) {
+	datasetReader, err := dataset.Open(inputFile)
+	if err != nil {
+		return 0, fmt.Errorf("error reading dataset: %w", err)
+	}
+	defer datasetReader.Close()
+
+	if version, ok := datasetReader.Header["version"].(string); ok && version != "v1" {
+		return 0, fmt.Errorf("unsupported dataset version: %s (supported: v1)", version)
+	}
+
+	var records int
+	for {
+		entry, err := datasetReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error reading dataset: %w", err)
+		}
+
+		// generate datasets hold the record in "record", update datasets in "data"
+		record, ok := entry["record"].(map[string]interface{})
+		if !ok {
+			if record, ok = entry["data"].(map[string]interface{}); !ok {
+				return 0, fmt.Errorf("dataset item %d has no record", datasetReader.Position())
+			}
+		}
+
+		root.observe(record, records)
+		records++
+	}
+
+	return records, nil
+}
+
+// observeMMDB records the values of every record of the MMDB database at
+// inputFile in root and returns the number of records.
+func observeMMDB(inputFile string, root *node) (int, error) {
+	db, err := maxminddb.Open(inputFile)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open database: %s - %w", inputFile, err)
+	}
+	defer db.Close()
+
+	var records int
+	err = dump.WalkRecords(db, func(subnet *net.IPNet, record mmdbtype.Map) error {
+		root.observe(record, records)
+		records++
+		return nil
+	})
+	return records, err
+}
+
+// Infer walks every record of an MMDB database or a v1 JSON dataset and
+// reports the types found at each key path together with the inferred schema.
+func Infer(cfg CmdSchemaInferConfig) (*InferResult, error) {
+	if cfg.SchemaFormat == "" {
+		cfg.SchemaFormat = FormatDataset
+	}
+	if cfg.SchemaFormat != FormatDataset && cfg.SchemaFormat != FormatJSONSchema {
+		return nil, fmt.Errorf("unsupported schema format: %s (supported: %s, %s)", cfg.SchemaFormat, FormatDataset, FormatJSONSchema)
+	}
+
+	if !files.CheckFileExists(cfg.InputFile) {
+		return nil, fmt.Errorf("[!] File %s does not exist", cfg.InputFile)
+	}
+
+	root := newNode()
+	var records int
+	var err error
+	switch {
+	case files.CheckFileExtension(cfg.InputFile, ".mmdb"):
+		records, err = observeMMDB(cfg.InputFile, root)
+	case files.CheckFileExtensions(cfg.InputFile, dataset.Extensions):
+		records, err = observeJSONDataset(cfg.InputFile, root)
+	default:
+		return nil, fmt.Errorf("[!] File %s must have a .mmdb, .json, .ndjson or .jsonl extension", cfg.InputFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &InferResult{
+		Records: records,
+		Paths:   []PathReport{},
+		Schema:  map[string]interface{}{},
+	}
+	if records == 0 {
+		return result, nil
+	}
+
+	keys := make([]string, 0, len(root.children))
+	for key := range root.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result.Paths = root.children[key].report(key, records, result.Paths)
+	}
+
+	_, datasetSchema, _ := root.resolve()
+	if cfg.SchemaFormat == FormatJSONSchema {
+		jsonSchema := ToJSONSchema(datasetSchema)
+		addRequired(jsonSchema, root)
+		result.Schema = jsonSchema
+	} else {
+		result.Schema = datasetSchema
+	}
+
+	return result, nil
+}
+
+// InferSchema runs Infer and returns the result as JSON for the output
+// package. With SchemaOnly set only the schema is returned.
+func InferSchema(cfg CmdSchemaInferConfig) ([]byte, error) {
+	result, err := Infer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{} = result
+	if cfg.SchemaOnly {
+		data = result.Schema
+	}
+
+	resultJSON, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	return resultJSON, nil
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMMDB = "../../test/inspect.mmdb"
+
+func writeTestJSON(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dataset.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func findPath(t *testing.T, result *InferResult, path string) PathReport {
+	t.Helper()
+	for _, report := range result.Paths {
+		if report.Path == path {
+			return report
+		}
+	}
+	require.Failf(t, "path not found", "path %q is not in the report", path)
+	return PathReport{}
+}
+
+func TestJSONTypeName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{"a", "string"},
+		{true, "bool"},
+		{float64(80), "uint16"},
+		{float64(70000), "uint32"},
+		{float64(5000000000), "uint64"},
+		{float64(-1), "int32"},
+		{float64(-5000000000), "float64"},
+		{1.5, "float64"},
+		{nil, "null"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, jsonTypeName(tt.value), "jsonTypeName(%v)", tt.value)
+	}
+}
+
+func TestInferFromJSONDataset(t *testing.T) {
+	t.Parallel()
+
+	path := writeTestJSON(t, `{
+		"version": "v1",
+		"dataset": [
+			{"network": "1.0.0.0/24", "record": {"asn": 13335, "name": "A", "tags": ["cdn"], "offset": -5, "location": {"latitude": 1.5}}},
+			{"network": "2.0.0.0/24", "record": {"asn": 70000, "name": 1, "offset": 3000000000, "location": {"latitude": 2}}},
+			{"network": "3.0.0.0/24", "record": {"asn": 1, "name": "C"}},
+			{"network": "4.0.0.0/24", "data": {"asn": 2, "name": "D"}}
+		]
+	}`)
+
+	result, err := Infer(CmdSchemaInferConfig{InputFile: path})
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, result.Records)
+	assert.Equal(t, map[string]interface{}{
+		"asn":      "uint32",
+		"tags":     []interface{}{"string"},
+		"offset":   "float64",
+		"location": map[string]interface{}{"latitude": "float64"},
+	}, result.Schema)
+
+	asn := findPath(t, result, "asn")
+	assert.Equal(t, "uint32", asn.Type)
+	assert.Equal(t, map[string]int{"uint16": 3, "uint32": 1}, asn.Types)
+	assert.Equal(t, float64(100), asn.Presence)
+	assert.False(t, asn.Conflict)
+
+	name := findPath(t, result, "name")
+	assert.True(t, name.Conflict)
+	assert.Equal(t, map[string]int{"string": 3, "uint16": 1}, name.Types)
+
+	// A signed type with a wider unsigned type widens to float64
+	offset := findPath(t, result, "offset")
+	assert.Equal(t, "float64", offset.Type)
+	assert.Equal(t, map[string]int{"int32": 1, "uint32": 1}, offset.Types)
+	assert.False(t, offset.Conflict)
+
+	tags := findPath(t, result, "tags")
+	assert.Equal(t, float64(25), tags.Presence)
+	findPath(t, result, "tags[]")
+
+	location := findPath(t, result, "location.latitude")
+	assert.Equal(t, "float64", location.Type)
+	assert.Equal(t, float64(50), location.Presence)
+}
+
+func TestInferFromMMDB(t *testing.T) {
+	t.Parallel()
+
+	result, err := Infer(CmdSchemaInferConfig{InputFile: testMMDB})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, result.Records)
+	geonameID := findPath(t, result, "registered_country.geoname_id")
+	assert.Equal(t, "float64", geonameID.Type)
+	assert.Equal(t, float64(100), geonameID.Presence)
+
+	schema, ok := result.Schema.(map[string]interface{})
+	require.True(t, ok)
+	assert.Contains(t, schema, "registered_country")
+}
+
+func TestInferJSONSchema(t *testing.T) {
+	t.Parallel()
+
+	path := writeTestJSON(t, `{
+		"dataset": [
+			{"network": "1.0.0.0/24", "record": {"asn": 13335, "country": {"iso_code": "US"}}},
+			{"network": "2.0.0.0/24", "record": {"country": {"iso_code": "AU"}}}
+		]
+	}`)
+
+	result, err := Infer(CmdSchemaInferConfig{InputFile: path, SchemaFormat: FormatJSONSchema})
+	require.NoError(t, err)
+
+	jsonSchema, ok := result.Schema.(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, "https://json-schema.org/draft/2020-12/schema", jsonSchema["$schema"])
+	assert.Equal(t, "object", jsonSchema["type"])
+	assert.Equal(t, []string{"country"}, jsonSchema["required"])
+
+	properties := jsonSchema["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 65535}, properties["asn"])
+}
+
+func TestInferSchema(t *testing.T) {
+	t.Parallel()
+
+	t.Run("schema only", func(t *testing.T) {
+		t.Parallel()
+		path := writeTestJSON(t, `{"dataset": [{"network": "1.0.0.0/24", "record": {"asn": 1}}]}`)
+
+		data, err := InferSchema(CmdSchemaInferConfig{InputFile: path, SchemaOnly: true})
+		require.NoError(t, err)
+
+		var schema map[string]interface{}
+		require.NoError(t, json.Unmarshal(data, &schema))
+		assert.Equal(t, map[string]interface{}{"asn": "uint16"}, schema)
+	})
+
+	t.Run("unsupported schema format", func(t *testing.T) {
+		t.Parallel()
+		_, err := InferSchema(CmdSchemaInferConfig{InputFile: testMMDB, SchemaFormat: "xsd"})
+		assert.Error(t, err)
+	})
+
+	t.Run("unsupported extension", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "dataset.csv")
+		require.NoError(t, os.WriteFile(path, []byte("a,b"), 0644))
+		_, err := InferSchema(CmdSchemaInferConfig{InputFile: path})
+		assert.Error(t, err)
+	})
+
+	t.Run("non-existent file", func(t *testing.T) {
+		t.Parallel()
+		_, err := InferSchema(CmdSchemaInferConfig{InputFile: "/nonexistent/file.mmdb"})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,318 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/InfraZ/mmdb-cli/pkg/inspect"
+	"github.com/InfraZ/mmdb-cli/pkg/metadata"
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
+	"github.com/InfraZ/mmdb-cli/pkg/serve/lookuppb"
+)
+
+// grpcService implements the gRPC lookup service on the databases of a
+// server.
+type grpcService struct {
+	lookuppb.UnimplementedLookupServiceServer
+
+	server *Server
+}
+
+// grpcError converts err to a gRPC status error.
+func grpcError(err error) error {
+	var requestErr *requestError
+	if errors.As(err, &requestErr) {
+		code := codes.InvalidArgument
+		switch requestErr.status {
+		case http.StatusNotFound:
+			code = codes.NotFound
+		case http.StatusServiceUnavailable:
+			code = codes.Unavailable
+		}
+		return status.Error(code, requestErr.message)
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// recordStruct converts a record decoded from a database to a protobuf
+// Struct. The numbers of a Struct are float64 values, so unsigned 128-bit
+// integers and the unsigned 64-bit integers that a float64 cannot hold
+// exactly are converted to decimal strings, like mmdb.ToJSONValue does. A
+// record that is not a map is held by the "value" field of the Struct.
+func recordStruct(record any) (*structpb.Struct, error) {
+	if record == nil {
+		return nil, nil
+	}
+	recordMap, ok := structValue(record).(map[string]any)
+	if !ok {
+		recordMap = map[string]any{"value": structValue(record)}
+	}
+	return structpb.NewStruct(recordMap)
+}
+
+func structValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		converted := make(map[string]any, len(v))
+		for key, item := range v {
+			converted[key] = structValue(item)
+		}
+		return converted
+	case []any:
+		converted := make([]any, len(v))
+		for i, item := range v {
+			converted[i] = structValue(item)
+		}
+		return converted
+	case uint64:
+		if v > mmdb.MaxExactFloat64 {
+			return strconv.FormatUint(v, 10)
+		}
+		return v
+	case *big.Int:
+		return v.String()
+	default:
+		return value
+	}
+}
+
+func (g *grpcService) lookup(request *lookuppb.LookupRequest) (*lookuppb.LookupResponse, error) {
+	databases, err := g.server.databasesNamed(request.GetDatabase())
+	if err != nil {
+		return nil, err
+	}
+	expression, err := validJSONPath(request.GetJsonpath())
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(request.GetIp()) == nil {
+		return nil, badRequest("invalid IP address: %s", request.GetIp())
+	}
+
+	dbs, release, err := acquire(databases)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	result, err := dbs.Lookup(request.GetIp(), expression)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := recordStruct(result.Record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert record of %s: %w", result.Network, err)
+	}
+
+	response := &lookuppb.LookupResponse{
+		Ip:      result.IP,
+		Found:   result.Found,
+		Network: result.Network,
+		Record:  record,
+	}
+	if result.PrefixLen != nil {
+		response.PrefixLen = int32(*result.PrefixLen)
+	}
+	return response, nil
+}
+
+func (g *grpcService) Lookup(ctx context.Context, request *lookuppb.LookupRequest) (*lookuppb.LookupResponse, error) {
+	response, err := g.lookup(request)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return response, nil
+}
+
+func (g *grpcService) BatchLookup(stream grpc.BidiStreamingServer[lookuppb.LookupRequest, lookuppb.LookupResponse]) error {
+	for {
+		request, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		response, err := g.lookup(request)
+		if err != nil {
+			return grpcError(err)
+		}
+		if err := stream.Send(response); err != nil {
+			return err
+		}
+	}
+}
+
+func (g *grpcService) NetworksWithin(request *lookuppb.NetworksWithinRequest, stream grpc.ServerStreamingServer[lookuppb.Network]) error {
+	databases, err := g.server.databasesNamed(request.GetDatabase())
+	if err != nil {
+		return grpcError(err)
+	}
+	expression, err := validJSONPath(request.GetJsonpath())
+	if err != nil {
+		return grpcError(err)
+	}
+	if _, _, err := net.ParseCIDR(request.GetCidr()); err != nil && net.ParseIP(request.GetCidr()) == nil {
+		return grpcError(badRequest("invalid CIDR: %s", request.GetCidr()))
+	}
+
+	dbs, release, err := acquire(databases)
+	if err != nil {
+		return grpcError(err)
+	}
+	defer release()
+
+	err = dbs.NetworksWithin(stream.Context(), request.GetCidr(), expression, func(network inspect.Record) error {
+		record, err := recordStruct(network.Record)
+		if err != nil {
+			return fmt.Errorf("failed to convert record of %s: %w", network.Network, err)
+		}
+		return stream.Send(&lookuppb.Network{Network: network.Network, Record: record})
+	})
+	if err != nil {
+		return grpcError(err)
+	}
+	return nil
+}
+
+func (g *grpcService) Metadata(ctx context.Context, request *lookuppb.MetadataRequest) (*lookuppb.DatabaseMetadata, error) {
+	db, err := g.server.databaseNamed(request.GetDatabase())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	reader, release, err := acquireReader(db)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	databaseMetadata := metadata.Metadata(reader)
+	release()
+
+	return &lookuppb.DatabaseMetadata{
+		Description:              databaseMetadata.Description,
+		DatabaseType:             databaseMetadata.DatabaseType,
+		Languages:                databaseMetadata.Languages,
+		BinaryFormatMajorVersion: uint32(databaseMetadata.BinaryFormatMajorVersion),
+		BinaryFormatMinorVersion: uint32(databaseMetadata.BinaryFormatMinorVersion),
+		BuildEpoch:               uint64(databaseMetadata.BuildEpoch),
+		IpVersion:                uint32(databaseMetadata.IPVersion),
+		NodeCount:                uint64(databaseMetadata.NodeCount),
+		RecordSize:               uint32(databaseMetadata.RecordSize),
+	}, nil
+}
+
+func (g *grpcService) Status(ctx context.Context, request *lookuppb.StatusRequest) (*lookuppb.StatusResponse, error) {
+	response := &lookuppb.StatusResponse{}
+	for _, databaseStatus := range g.server.Status() {
+		response.Databases = append(response.Databases, &lookuppb.DatabaseStatus{
+			Name:         databaseStatus.Name,
+			Path:         databaseStatus.Path,
+			DatabaseType: databaseStatus.DatabaseType,
+			BuildEpoch:   uint64(databaseStatus.BuildEpoch),
+			Reloads:      databaseStatus.Reloads,
+		})
+	}
+	return response, nil
+}
+
+// GRPCServer returns a gRPC server offering the lookup service and the
+// standard health service, whose status follows the readiness of the server.
+func (s *Server) GRPCServer() (*grpc.Server, *health.Server) {
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(logUnary), grpc.ChainStreamInterceptor(logStream))
+	lookuppb.RegisterLookupServiceServer(grpcServer, &grpcService{server: s})
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	return grpcServer, healthServer
+}
+
+// ServeGRPC answers the gRPC requests of listener until ctx is done, and then
+// waits for the requests in flight to complete.
+func (s *Server) ServeGRPC(ctx context.Context, listener net.Listener) error {
+	grpcServer, healthServer := s.GRPCServer()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- grpcServer.Serve(listener)
+	}()
+	s.ready.Store(true)
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+
+	select {
+	case err := <-serveErr:
+		s.ready.Store(false)
+		return fmt.Errorf("server stopped: %w", err)
+	case <-ctx.Done():
+	}
+
+	s.ready.Store(false)
+	healthServer.Shutdown()
+	slog.Info("Shutting down the server")
+
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(shutdownTimeout):
+		grpcServer.Stop()
+		return fmt.Errorf("failed to shut down the server: requests still in flight after %s", shutdownTimeout)
+	}
+
+	return nil
+}
+
+// logUnary logs every unary call at the debug level.
+func logUnary(ctx context.Context, request any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	response, err := handler(ctx, request)
+	slog.Debug("Call served", "method", info.FullMethod, "code", status.Code(err).String(), "duration", time.Since(start))
+	return response, err
+}
+
+// logStream logs every streaming call at the debug level.
+func logStream(server any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(server, stream)
+	slog.Debug("Call served", "method", info.FullMethod, "code", status.Code(err).String(), "duration", time.Since(start))
+	return err
+}
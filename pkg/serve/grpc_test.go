@@ -0,0 +1,251 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serve
+
+import (
+	"context"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/InfraZ/mmdb-cli/pkg/serve/lookuppb"
+)
+
+// newGRPCClient serves the test databases over gRPC on a local listener and
+// returns a client connection to it.
+func newGRPCClient(t *testing.T) (*Server, *grpc.ClientConn, context.CancelFunc, chan error) {
+	t.Helper()
+
+	server, err := NewServer([]Database{openDatabase(t, inspectMMDB), openDatabase(t, metadataMMDB)}, Options{})
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- server.ServeGRPC(ctx, listener)
+	}()
+	t.Cleanup(cancel)
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return server, conn, cancel, served
+}
+
+func TestGRPC(t *testing.T) {
+	t.Parallel()
+
+	_, conn, _, _ := newGRPCClient(t)
+	client := lookuppb.NewLookupServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	t.Run("lookup", func(t *testing.T) {
+		t.Parallel()
+
+		response, err := client.Lookup(ctx, &lookuppb.LookupRequest{Ip: "1.1.1.1"})
+		require.NoError(t, err)
+		assert.True(t, response.GetFound())
+		assert.Equal(t, "1.1.1.1/32", response.GetNetwork())
+		assert.Equal(t, int32(32), response.GetPrefixLen())
+		country := response.GetRecord().GetFields()["registered_country"].GetStructValue()
+		assert.Equal(t, "AU", country.GetFields()["iso_code"].GetStringValue())
+
+		response, err = client.Lookup(ctx, &lookuppb.LookupRequest{Ip: "1.1.1.1", Jsonpath: `{[?(@.registered_country.iso_code=="US")]}`})
+		require.NoError(t, err)
+		assert.False(t, response.GetFound())
+		assert.Nil(t, response.GetRecord())
+	})
+
+	t.Run("lookup errors", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := client.Lookup(ctx, &lookuppb.LookupRequest{Ip: "invalid"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		_, err = client.Lookup(ctx, &lookuppb.LookupRequest{Ip: "1.1.1.1", Database: "unknown"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("batch lookup", func(t *testing.T) {
+		t.Parallel()
+
+		stream, err := client.BatchLookup(ctx)
+		require.NoError(t, err)
+		for _, ip := range []string{"1.1.1.1", "10.0.0.1"} {
+			require.NoError(t, stream.Send(&lookuppb.LookupRequest{Ip: ip}))
+		}
+		require.NoError(t, stream.CloseSend())
+
+		var responses []*lookuppb.LookupResponse
+		for {
+			response, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			responses = append(responses, response)
+		}
+		require.Len(t, responses, 2)
+		assert.True(t, responses[0].GetFound())
+		assert.Equal(t, "10.0.0.1", responses[1].GetIp())
+		assert.False(t, responses[1].GetFound())
+	})
+
+	t.Run("networks within", func(t *testing.T) {
+		t.Parallel()
+
+		stream, err := client.NetworksWithin(ctx, &lookuppb.NetworksWithinRequest{Cidr: "1.0.0.0/24"})
+		require.NoError(t, err)
+
+		var networks []string
+		for {
+			network, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, network.GetRecord())
+			networks = append(networks, network.GetNetwork())
+		}
+		assert.NotEmpty(t, networks)
+
+		stream, err = client.NetworksWithin(ctx, &lookuppb.NetworksWithinRequest{Cidr: "1.0.0.0/33"})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		t.Parallel()
+
+		response, err := client.Metadata(ctx, &lookuppb.MetadataRequest{Database: "metadata"})
+		require.NoError(t, err)
+		assert.Equal(t, "Metadata Test", response.GetDatabaseType())
+	})
+
+	t.Run("status", func(t *testing.T) {
+		t.Parallel()
+
+		response, err := client.Status(ctx, &lookuppb.StatusRequest{})
+		require.NoError(t, err)
+		require.Len(t, response.GetDatabases(), 2)
+		assert.Equal(t, "metadata", response.GetDatabases()[1].GetName())
+		assert.Equal(t, uint64(1741881777), response.GetDatabases()[1].GetBuildEpoch())
+		assert.Zero(t, response.GetDatabases()[1].GetReloads())
+	})
+
+	t.Run("health", func(t *testing.T) {
+		t.Parallel()
+
+		response, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, response.GetStatus())
+	})
+}
+
+func TestServeGRPCShutdown(t *testing.T) {
+	t.Parallel()
+
+	server, conn, cancel, served := newGRPCClient(t)
+
+	ctx, cancelCall := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelCall()
+	_, err := lookuppb.NewLookupServiceClient(conn).Lookup(ctx, &lookuppb.LookupRequest{Ip: "1.1.1.1"})
+	require.NoError(t, err)
+
+	cancel()
+	select {
+	case err := <-served:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop")
+	}
+	assert.False(t, server.ready.Load())
+}
+
+func TestRecordStruct(t *testing.T) {
+	t.Parallel()
+
+	record, err := recordStruct(map[string]any{
+		"asn":   uint64(13335),
+		"id":    uint64(1<<53 + 1),
+		"big":   new(big.Int).Lsh(big.NewInt(1), 100),
+		"tags":  []any{"cdn", uint64(math.MaxUint64)},
+		"valid": true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"asn":   float64(13335),
+		"id":    "9007199254740993",
+		"big":   "1267650600228229401496703205376",
+		"tags":  []any{"cdn", "18446744073709551615"},
+		"valid": true,
+	}, record.AsMap())
+
+	record, err = recordStruct(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+
+	record, err = recordStruct("not a map")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"value": "not a map"}, record.AsMap())
+}
+
+func TestGRPCScalarRecords(t *testing.T) {
+	t.Parallel()
+
+	writer, err := mmdbwriter.New(mmdbwriter.Options{DatabaseType: "Scalar-Test", Description: map[string]string{"en": "Scalar records"}, RecordSize: 24})
+	require.NoError(t, err)
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+	require.NoError(t, writer.Insert(network, mmdbtype.Uint64(1<<60+1)))
+	path := filepath.Join(t.TempDir(), "scalar.mmdb")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	_, err = writer.WriteTo(file)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	server, err := NewServer([]Database{openDatabase(t, path)}, Options{})
+	require.NoError(t, err)
+
+	response, err := (&grpcService{server: server}).Lookup(context.Background(), &lookuppb.LookupRequest{Ip: "1.1.1.1"})
+	require.NoError(t, err)
+	assert.True(t, response.GetFound())
+	assert.Equal(t, "1152921504606846977", response.GetRecord().GetFields()["value"].GetStringValue())
+}
@@ -0,0 +1,417 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serve
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/InfraZ/mmdb-cli/pkg/inspect"
+	"github.com/InfraZ/mmdb-cli/pkg/jsonpath"
+	"github.com/InfraZ/mmdb-cli/pkg/metadata"
+	"github.com/InfraZ/mmdb-cli/pkg/output"
+	"github.com/InfraZ/mmdb-cli/pkg/reload"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// formats are the output formats of the responses.
+var formats = []string{"json", "json-pretty", "yaml", "xml"}
+
+// contentTypes are the content types of the output formats.
+var contentTypes = map[string]string{
+	"json":        "application/json",
+	"json-pretty": "application/json",
+	"yaml":        "application/yaml",
+	"xml":         "application/xml",
+}
+
+func isFormat(format string) bool {
+	_, ok := contentTypes[format]
+	return ok
+}
+
+// maxBatchBody is the maximum size of the body of a batch lookup.
+const maxBatchBody = 1 << 20
+
+// errorResponse is the body of the failed requests.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// statusResponse is the body of the health and readiness requests.
+type statusResponse struct {
+	Status string `json:"status"`
+}
+
+// Handler returns the handler of the server endpoints:
+//
+//	GET  /lookup/{ip}      record of the network holding an IP address
+//	POST /lookup           records of a JSON array of IP addresses
+//	GET  /networks/{cidr}  networks of the database within a CIDR, up to
+//	                       Options.MaxNetworks
+//	GET  /metadata         metadata of the database
+//	GET  /status           build epoch and reload count of every database
+//	GET  /healthz          health of the server
+//	GET  /readyz           readiness of the server
+//
+// The "database" query parameter selects the database by name, "format" the
+// output format and "jsonpath" filters the records. The lookups and networks
+// of several databases, whose names are separated by commas, have their
+// records merged, see inspect.Databases.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /lookup/{ip}", s.handleLookup)
+	mux.HandleFunc("POST /lookup", s.handleBatchLookup)
+	mux.HandleFunc("GET /networks/{cidr...}", s.handleNetworks)
+	mux.HandleFunc("GET /metadata", s.handleMetadata)
+	mux.HandleFunc("GET /status", s.handleStatus)
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("GET /readyz", s.handleReady)
+
+	return logRequests(mux)
+}
+
+// requestError is an error answered with its status code.
+type requestError struct {
+	status  int
+	message string
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &requestError{status: http.StatusBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+// database returns the database selected by the "database" query parameter.
+func (s *Server) database(r *http.Request) (*Database, error) {
+	return s.databaseNamed(r.URL.Query().Get("database"))
+}
+
+// databaseNamed returns the database named name, the first database when
+// name is empty.
+func (s *Server) databaseNamed(name string) (*Database, error) {
+	if name == "" {
+		return &s.databases[0], nil
+	}
+	for i := range s.databases {
+		if s.databases[i].Name == name {
+			return &s.databases[i], nil
+		}
+	}
+	return nil, &requestError{status: http.StatusNotFound, message: fmt.Sprintf("unknown database %s", name)}
+}
+
+// databasesNamed returns the databases whose names are separated by commas in
+// names, the first database when names is empty.
+func (s *Server) databasesNamed(names string) ([]*Database, error) {
+	var databases []*Database
+	for _, name := range strings.Split(names, ",") {
+		database, err := s.databaseNamed(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		databases = append(databases, database)
+	}
+	return databases, nil
+}
+
+// acquireReader acquires the reader of database, a closed database being
+// answered as unavailable.
+func acquireReader(database *Database) (*maxminddb.Reader, func(), error) {
+	reader, release, err := database.Acquire()
+	if errors.Is(err, reload.ErrClosed) {
+		return nil, nil, &requestError{status: http.StatusServiceUnavailable, message: fmt.Sprintf("database %s is closed", database.Name)}
+	}
+	return reader, release, err
+}
+
+// acquire acquires the readers of databases, which are released by the
+// returned function.
+func acquire(databases []*Database) (inspect.Databases, func(), error) {
+	dbs := make(inspect.Databases, 0, len(databases))
+	releases := make([]func(), 0, len(databases))
+	releaseAll := func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+
+	for _, database := range databases {
+		reader, release, err := acquireReader(database)
+		if err != nil {
+			releaseAll()
+			return nil, nil, err
+		}
+		dbs = append(dbs, inspect.Database{Alias: database.Alias, Reader: reader})
+		releases = append(releases, release)
+	}
+
+	return dbs, releaseAll, nil
+}
+
+// jsonPath returns the validated "jsonpath" query parameter.
+func jsonPath(r *http.Request) (string, error) {
+	return validJSONPath(r.URL.Query().Get("jsonpath"))
+}
+
+// validJSONPath returns expression when it is empty or valid.
+func validJSONPath(expression string) (string, error) {
+	if expression != "" {
+		if err := jsonpath.ValidateExpression(expression); err != nil {
+			return "", badRequest("%s", err)
+		}
+	}
+	return expression, nil
+}
+
+func (s *Server) handleLookup(w http.ResponseWriter, r *http.Request) {
+	databases, err := s.databasesNamed(r.URL.Query().Get("database"))
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	expression, err := jsonPath(r)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	ip := r.PathValue("ip")
+	if net.ParseIP(ip) == nil {
+		s.fail(w, r, badRequest("invalid IP address: %s", ip))
+		return
+	}
+
+	dbs, release, err := acquire(databases)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	defer release()
+
+	result, err := dbs.Lookup(ip, expression)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	s.respond(w, r, http.StatusOK, result)
+}
+
+func (s *Server) handleBatchLookup(w http.ResponseWriter, r *http.Request) {
+	databases, err := s.databasesNamed(r.URL.Query().Get("database"))
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	expression, err := jsonPath(r)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var ips []string
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody)).Decode(&ips); err != nil {
+		s.fail(w, r, badRequest("the body must be a JSON array of IP addresses: %s", err))
+		return
+	}
+	if len(ips) > s.opts.MaxBatch {
+		s.fail(w, r, badRequest("batch of %d addresses exceeds the limit of %d", len(ips), s.opts.MaxBatch))
+		return
+	}
+	for _, ip := range ips {
+		if net.ParseIP(ip) == nil {
+			s.fail(w, r, badRequest("invalid IP address: %s", ip))
+			return
+		}
+	}
+
+	// The whole batch is answered by the same readers
+	dbs, release, err := acquire(databases)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	defer release()
+
+	results := make([]*inspect.LookupResult, 0, len(ips))
+	for _, ip := range ips {
+		if err := r.Context().Err(); err != nil {
+			return
+		}
+		result, err := dbs.Lookup(ip, expression)
+		if err != nil {
+			s.fail(w, r, err)
+			return
+		}
+		results = append(results, result)
+	}
+
+	s.respond(w, r, http.StatusOK, results)
+}
+
+func (s *Server) handleNetworks(w http.ResponseWriter, r *http.Request) {
+	databases, err := s.databasesNamed(r.URL.Query().Get("database"))
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	expression, err := jsonPath(r)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	cidr := r.PathValue("cidr")
+	if _, _, err := net.ParseCIDR(cidr); err != nil && net.ParseIP(cidr) == nil {
+		s.fail(w, r, badRequest("invalid CIDR: %s", cidr))
+		return
+	}
+
+	dbs, release, err := acquire(databases)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	defer release()
+
+	// The response is built in memory, so the requests for more networks
+	// than allowed are stopped as soon as the limit is reached
+	result := inspect.Result{Query: cidr, Records: []inspect.Record{}}
+	err = dbs.NetworksWithin(r.Context(), cidr, expression, func(record inspect.Record) error {
+		if len(result.Records) == s.opts.MaxNetworks {
+			return &requestError{
+				status:  http.StatusRequestEntityTooLarge,
+				message: fmt.Sprintf("more than %d networks within %s, use a smaller CIDR", s.opts.MaxNetworks, cidr),
+			}
+		}
+		result.Records = append(result.Records, record)
+		return nil
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	s.respond(w, r, http.StatusOK, result)
+}
+
+func (s *Server) handleMetadata(w http.ResponseWriter, r *http.Request) {
+	db, err := s.database(r)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	reader, release, err := acquireReader(db)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	defer release()
+
+	s.respond(w, r, http.StatusOK, metadata.Metadata(reader))
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	s.respond(w, r, http.StatusOK, s.Status())
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	s.respond(w, r, http.StatusOK, statusResponse{Status: "ok"})
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if !s.ready.Load() {
+		s.respond(w, r, http.StatusServiceUnavailable, statusResponse{Status: "not ready"})
+		return
+	}
+	s.respond(w, r, http.StatusOK, statusResponse{Status: "ready"})
+}
+
+// fail answers err with its status code, internal errors being logged.
+func (s *Server) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if requestErr, ok := err.(*requestError); ok {
+		s.respond(w, r, requestErr.status, errorResponse{Error: requestErr.message})
+		return
+	}
+
+	slog.Error("Request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+	s.respond(w, r, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+}
+
+// respond writes data in the format of the "format" query parameter. An
+// unsupported format is answered in the default format.
+func (s *Server) respond(w http.ResponseWriter, r *http.Request, status int, data any) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = s.opts.Format
+	}
+	if !isFormat(format) {
+		format = s.opts.Format
+		status = http.StatusBadRequest
+		data = errorResponse{Error: fmt.Sprintf("unsupported output format: %s (supported: %s)", r.URL.Query().Get("format"), strings.Join(formats, ", "))}
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("Failed to marshal response", "path", r.URL.Path, "error", err)
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	var body strings.Builder
+	if err := output.Write(&body, jsonData, output.OutputOptions{Format: format}); err != nil {
+		slog.Error("Failed to format response", "path", r.URL.Path, "format", format, "error", err)
+		http.Error(w, "failed to format response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypes[format])
+	w.WriteHeader(status)
+	fmt.Fprint(w, body.String())
+}
+
+// statusRecorder keeps the status code written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// logRequests logs every request at the debug level.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		slog.Debug("Request served", "method", r.Method, "path", r.URL.Path, "status", recorder.status, "duration", time.Since(start))
+	})
+}
@@ -0,0 +1,735 @@
+// Copyright 2024 The InfraZ Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: lookup.proto
+
+package lookuppb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type LookupRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Ip    string                 `protobuf:"bytes,1,opt,name=ip,proto3" json:"ip,omitempty"`
+	// Name of the database, the first database when empty. The records of
+	// several databases, whose names are separated by commas, are merged.
+	Database string `protobuf:"bytes,2,opt,name=database,proto3" json:"database,omitempty"`
+	// JSONPath filter, the records that do not match are not found
+	Jsonpath      string `protobuf:"bytes,3,opt,name=jsonpath,proto3" json:"jsonpath,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupRequest) Reset() {
+	*x = LookupRequest{}
+	mi := &file_lookup_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupRequest) ProtoMessage() {}
+
+func (x *LookupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lookup_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupRequest.ProtoReflect.Descriptor instead.
+func (*LookupRequest) Descriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LookupRequest) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *LookupRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *LookupRequest) GetJsonpath() string {
+	if x != nil {
+		return x.Jsonpath
+	}
+	return ""
+}
+
+type LookupResponse struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Ip        string                 `protobuf:"bytes,1,opt,name=ip,proto3" json:"ip,omitempty"`
+	Found     bool                   `protobuf:"varint,2,opt,name=found,proto3" json:"found,omitempty"`
+	Network   string                 `protobuf:"bytes,3,opt,name=network,proto3" json:"network,omitempty"`
+	PrefixLen int32                  `protobuf:"varint,4,opt,name=prefix_len,json=prefixLen,proto3" json:"prefix_len,omitempty"`
+	// The unsigned integers above 2^53 are decimal strings, and a record that
+	// is not a map is held by the "value" field
+	Record        *structpb.Struct `protobuf:"bytes,5,opt,name=record,proto3" json:"record,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupResponse) Reset() {
+	*x = LookupResponse{}
+	mi := &file_lookup_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupResponse) ProtoMessage() {}
+
+func (x *LookupResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_lookup_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupResponse.ProtoReflect.Descriptor instead.
+func (*LookupResponse) Descriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *LookupResponse) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *LookupResponse) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+func (x *LookupResponse) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *LookupResponse) GetPrefixLen() int32 {
+	if x != nil {
+		return x.PrefixLen
+	}
+	return 0
+}
+
+func (x *LookupResponse) GetRecord() *structpb.Struct {
+	if x != nil {
+		return x.Record
+	}
+	return nil
+}
+
+type NetworksWithinRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// CIDR or IP address
+	Cidr string `protobuf:"bytes,1,opt,name=cidr,proto3" json:"cidr,omitempty"`
+	// Name of the database, the first database when empty. The records of
+	// several databases, whose names are separated by commas, are merged.
+	Database string `protobuf:"bytes,2,opt,name=database,proto3" json:"database,omitempty"`
+	// JSONPath filter applied to each record
+	Jsonpath      string `protobuf:"bytes,3,opt,name=jsonpath,proto3" json:"jsonpath,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NetworksWithinRequest) Reset() {
+	*x = NetworksWithinRequest{}
+	mi := &file_lookup_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NetworksWithinRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NetworksWithinRequest) ProtoMessage() {}
+
+func (x *NetworksWithinRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lookup_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NetworksWithinRequest.ProtoReflect.Descriptor instead.
+func (*NetworksWithinRequest) Descriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *NetworksWithinRequest) GetCidr() string {
+	if x != nil {
+		return x.Cidr
+	}
+	return ""
+}
+
+func (x *NetworksWithinRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *NetworksWithinRequest) GetJsonpath() string {
+	if x != nil {
+		return x.Jsonpath
+	}
+	return ""
+}
+
+type Network struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Network string                 `protobuf:"bytes,1,opt,name=network,proto3" json:"network,omitempty"`
+	// Converted like the record of a LookupResponse
+	Record        *structpb.Struct `protobuf:"bytes,2,opt,name=record,proto3" json:"record,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Network) Reset() {
+	*x = Network{}
+	mi := &file_lookup_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Network) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Network) ProtoMessage() {}
+
+func (x *Network) ProtoReflect() protoreflect.Message {
+	mi := &file_lookup_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Network.ProtoReflect.Descriptor instead.
+func (*Network) Descriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Network) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *Network) GetRecord() *structpb.Struct {
+	if x != nil {
+		return x.Record
+	}
+	return nil
+}
+
+type MetadataRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Name of the database, the first database when empty
+	Database      string `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MetadataRequest) Reset() {
+	*x = MetadataRequest{}
+	mi := &file_lookup_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MetadataRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MetadataRequest) ProtoMessage() {}
+
+func (x *MetadataRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lookup_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MetadataRequest.ProtoReflect.Descriptor instead.
+func (*MetadataRequest) Descriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *MetadataRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+type DatabaseMetadata struct {
+	state                    protoimpl.MessageState `protogen:"open.v1"`
+	Description              map[string]string      `protobuf:"bytes,1,rep,name=description,proto3" json:"description,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	DatabaseType             string                 `protobuf:"bytes,2,opt,name=database_type,json=databaseType,proto3" json:"database_type,omitempty"`
+	Languages                []string               `protobuf:"bytes,3,rep,name=languages,proto3" json:"languages,omitempty"`
+	BinaryFormatMajorVersion uint32                 `protobuf:"varint,4,opt,name=binary_format_major_version,json=binaryFormatMajorVersion,proto3" json:"binary_format_major_version,omitempty"`
+	BinaryFormatMinorVersion uint32                 `protobuf:"varint,5,opt,name=binary_format_minor_version,json=binaryFormatMinorVersion,proto3" json:"binary_format_minor_version,omitempty"`
+	BuildEpoch               uint64                 `protobuf:"varint,6,opt,name=build_epoch,json=buildEpoch,proto3" json:"build_epoch,omitempty"`
+	IpVersion                uint32                 `protobuf:"varint,7,opt,name=ip_version,json=ipVersion,proto3" json:"ip_version,omitempty"`
+	NodeCount                uint64                 `protobuf:"varint,8,opt,name=node_count,json=nodeCount,proto3" json:"node_count,omitempty"`
+	RecordSize               uint32                 `protobuf:"varint,9,opt,name=record_size,json=recordSize,proto3" json:"record_size,omitempty"`
+	unknownFields            protoimpl.UnknownFields
+	sizeCache                protoimpl.SizeCache
+}
+
+func (x *DatabaseMetadata) Reset() {
+	*x = DatabaseMetadata{}
+	mi := &file_lookup_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DatabaseMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DatabaseMetadata) ProtoMessage() {}
+
+func (x *DatabaseMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_lookup_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DatabaseMetadata.ProtoReflect.Descriptor instead.
+func (*DatabaseMetadata) Descriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DatabaseMetadata) GetDescription() map[string]string {
+	if x != nil {
+		return x.Description
+	}
+	return nil
+}
+
+func (x *DatabaseMetadata) GetDatabaseType() string {
+	if x != nil {
+		return x.DatabaseType
+	}
+	return ""
+}
+
+func (x *DatabaseMetadata) GetLanguages() []string {
+	if x != nil {
+		return x.Languages
+	}
+	return nil
+}
+
+func (x *DatabaseMetadata) GetBinaryFormatMajorVersion() uint32 {
+	if x != nil {
+		return x.BinaryFormatMajorVersion
+	}
+	return 0
+}
+
+func (x *DatabaseMetadata) GetBinaryFormatMinorVersion() uint32 {
+	if x != nil {
+		return x.BinaryFormatMinorVersion
+	}
+	return 0
+}
+
+func (x *DatabaseMetadata) GetBuildEpoch() uint64 {
+	if x != nil {
+		return x.BuildEpoch
+	}
+	return 0
+}
+
+func (x *DatabaseMetadata) GetIpVersion() uint32 {
+	if x != nil {
+		return x.IpVersion
+	}
+	return 0
+}
+
+func (x *DatabaseMetadata) GetNodeCount() uint64 {
+	if x != nil {
+		return x.NodeCount
+	}
+	return 0
+}
+
+func (x *DatabaseMetadata) GetRecordSize() uint32 {
+	if x != nil {
+		return x.RecordSize
+	}
+	return 0
+}
+
+type StatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusRequest) Reset() {
+	*x = StatusRequest{}
+	mi := &file_lookup_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusRequest) ProtoMessage() {}
+
+func (x *StatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lookup_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusRequest.ProtoReflect.Descriptor instead.
+func (*StatusRequest) Descriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{6}
+}
+
+type DatabaseStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	DatabaseType  string                 `protobuf:"bytes,3,opt,name=database_type,json=databaseType,proto3" json:"database_type,omitempty"`
+	BuildEpoch    uint64                 `protobuf:"varint,4,opt,name=build_epoch,json=buildEpoch,proto3" json:"build_epoch,omitempty"`
+	Reloads       int64                  `protobuf:"varint,5,opt,name=reloads,proto3" json:"reloads,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DatabaseStatus) Reset() {
+	*x = DatabaseStatus{}
+	mi := &file_lookup_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DatabaseStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DatabaseStatus) ProtoMessage() {}
+
+func (x *DatabaseStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_lookup_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DatabaseStatus.ProtoReflect.Descriptor instead.
+func (*DatabaseStatus) Descriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DatabaseStatus) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *DatabaseStatus) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *DatabaseStatus) GetDatabaseType() string {
+	if x != nil {
+		return x.DatabaseType
+	}
+	return ""
+}
+
+func (x *DatabaseStatus) GetBuildEpoch() uint64 {
+	if x != nil {
+		return x.BuildEpoch
+	}
+	return 0
+}
+
+func (x *DatabaseStatus) GetReloads() int64 {
+	if x != nil {
+		return x.Reloads
+	}
+	return 0
+}
+
+type StatusResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Databases     []*DatabaseStatus      `protobuf:"bytes,1,rep,name=databases,proto3" json:"databases,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusResponse) Reset() {
+	*x = StatusResponse{}
+	mi := &file_lookup_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusResponse) ProtoMessage() {}
+
+func (x *StatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_lookup_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusResponse.ProtoReflect.Descriptor instead.
+func (*StatusResponse) Descriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *StatusResponse) GetDatabases() []*DatabaseStatus {
+	if x != nil {
+		return x.Databases
+	}
+	return nil
+}
+
+var File_lookup_proto protoreflect.FileDescriptor
+
+const file_lookup_proto_rawDesc = "" +
+	"\n" +
+	"\flookup.proto\x12\x11mmdbcli.lookup.v1\x1a\x1cgoogle/protobuf/struct.proto\"W\n" +
+	"\rLookupRequest\x12\x0e\n" +
+	"\x02ip\x18\x01 \x01(\tR\x02ip\x12\x1a\n" +
+	"\bdatabase\x18\x02 \x01(\tR\bdatabase\x12\x1a\n" +
+	"\bjsonpath\x18\x03 \x01(\tR\bjsonpath\"\xa0\x01\n" +
+	"\x0eLookupResponse\x12\x0e\n" +
+	"\x02ip\x18\x01 \x01(\tR\x02ip\x12\x14\n" +
+	"\x05found\x18\x02 \x01(\bR\x05found\x12\x18\n" +
+	"\anetwork\x18\x03 \x01(\tR\anetwork\x12\x1d\n" +
+	"\n" +
+	"prefix_len\x18\x04 \x01(\x05R\tprefixLen\x12/\n" +
+	"\x06record\x18\x05 \x01(\v2\x17.google.protobuf.StructR\x06record\"c\n" +
+	"\x15NetworksWithinRequest\x12\x12\n" +
+	"\x04cidr\x18\x01 \x01(\tR\x04cidr\x12\x1a\n" +
+	"\bdatabase\x18\x02 \x01(\tR\bdatabase\x12\x1a\n" +
+	"\bjsonpath\x18\x03 \x01(\tR\bjsonpath\"T\n" +
+	"\aNetwork\x12\x18\n" +
+	"\anetwork\x18\x01 \x01(\tR\anetwork\x12/\n" +
+	"\x06record\x18\x02 \x01(\v2\x17.google.protobuf.StructR\x06record\"-\n" +
+	"\x0fMetadataRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\"\xeb\x03\n" +
+	"\x10DatabaseMetadata\x12V\n" +
+	"\vdescription\x18\x01 \x03(\v24.mmdbcli.lookup.v1.DatabaseMetadata.DescriptionEntryR\vdescription\x12#\n" +
+	"\rdatabase_type\x18\x02 \x01(\tR\fdatabaseType\x12\x1c\n" +
+	"\tlanguages\x18\x03 \x03(\tR\tlanguages\x12=\n" +
+	"\x1bbinary_format_major_version\x18\x04 \x01(\rR\x18binaryFormatMajorVersion\x12=\n" +
+	"\x1bbinary_format_minor_version\x18\x05 \x01(\rR\x18binaryFormatMinorVersion\x12\x1f\n" +
+	"\vbuild_epoch\x18\x06 \x01(\x04R\n" +
+	"buildEpoch\x12\x1d\n" +
+	"\n" +
+	"ip_version\x18\a \x01(\rR\tipVersion\x12\x1d\n" +
+	"\n" +
+	"node_count\x18\b \x01(\x04R\tnodeCount\x12\x1f\n" +
+	"\vrecord_size\x18\t \x01(\rR\n" +
+	"recordSize\x1a>\n" +
+	"\x10DescriptionEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x0f\n" +
+	"\rStatusRequest\"\x98\x01\n" +
+	"\x0eDatabaseStatus\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\x12#\n" +
+	"\rdatabase_type\x18\x03 \x01(\tR\fdatabaseType\x12\x1f\n" +
+	"\vbuild_epoch\x18\x04 \x01(\x04R\n" +
+	"buildEpoch\x12\x18\n" +
+	"\areloads\x18\x05 \x01(\x03R\areloads\"Q\n" +
+	"\x0eStatusResponse\x12?\n" +
+	"\tdatabases\x18\x01 \x03(\v2!.mmdbcli.lookup.v1.DatabaseStatusR\tdatabases2\xb4\x03\n" +
+	"\rLookupService\x12M\n" +
+	"\x06Lookup\x12 .mmdbcli.lookup.v1.LookupRequest\x1a!.mmdbcli.lookup.v1.LookupResponse\x12V\n" +
+	"\vBatchLookup\x12 .mmdbcli.lookup.v1.LookupRequest\x1a!.mmdbcli.lookup.v1.LookupResponse(\x010\x01\x12X\n" +
+	"\x0eNetworksWithin\x12(.mmdbcli.lookup.v1.NetworksWithinRequest\x1a\x1a.mmdbcli.lookup.v1.Network0\x01\x12S\n" +
+	"\bMetadata\x12\".mmdbcli.lookup.v1.MetadataRequest\x1a#.mmdbcli.lookup.v1.DatabaseMetadata\x12M\n" +
+	"\x06Status\x12 .mmdbcli.lookup.v1.StatusRequest\x1a!.mmdbcli.lookup.v1.StatusResponseB/Z-github.com/InfraZ/mmdb-cli/pkg/serve/lookuppbb\x06proto3"
+
+var (
+	file_lookup_proto_rawDescOnce sync.Once
+	file_lookup_proto_rawDescData []byte
+)
+
+func file_lookup_proto_rawDescGZIP() []byte {
+	file_lookup_proto_rawDescOnce.Do(func() {
+		file_lookup_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_lookup_proto_rawDesc), len(file_lookup_proto_rawDesc)))
+	})
+	return file_lookup_proto_rawDescData
+}
+
+var file_lookup_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_lookup_proto_goTypes = []any{
+	(*LookupRequest)(nil),         // 0: mmdbcli.lookup.v1.LookupRequest
+	(*LookupResponse)(nil),        // 1: mmdbcli.lookup.v1.LookupResponse
+	(*NetworksWithinRequest)(nil), // 2: mmdbcli.lookup.v1.NetworksWithinRequest
+	(*Network)(nil),               // 3: mmdbcli.lookup.v1.Network
+	(*MetadataRequest)(nil),       // 4: mmdbcli.lookup.v1.MetadataRequest
+	(*DatabaseMetadata)(nil),      // 5: mmdbcli.lookup.v1.DatabaseMetadata
+	(*StatusRequest)(nil),         // 6: mmdbcli.lookup.v1.StatusRequest
+	(*DatabaseStatus)(nil),        // 7: mmdbcli.lookup.v1.DatabaseStatus
+	(*StatusResponse)(nil),        // 8: mmdbcli.lookup.v1.StatusResponse
+	nil,                           // 9: mmdbcli.lookup.v1.DatabaseMetadata.DescriptionEntry
+	(*structpb.Struct)(nil),       // 10: google.protobuf.Struct
+}
+var file_lookup_proto_depIdxs = []int32{
+	10, // 0: mmdbcli.lookup.v1.LookupResponse.record:type_name -> google.protobuf.Struct
+	10, // 1: mmdbcli.lookup.v1.Network.record:type_name -> google.protobuf.Struct
+	9,  // 2: mmdbcli.lookup.v1.DatabaseMetadata.description:type_name -> mmdbcli.lookup.v1.DatabaseMetadata.DescriptionEntry
+	7,  // 3: mmdbcli.lookup.v1.StatusResponse.databases:type_name -> mmdbcli.lookup.v1.DatabaseStatus
+	0,  // 4: mmdbcli.lookup.v1.LookupService.Lookup:input_type -> mmdbcli.lookup.v1.LookupRequest
+	0,  // 5: mmdbcli.lookup.v1.LookupService.BatchLookup:input_type -> mmdbcli.lookup.v1.LookupRequest
+	2,  // 6: mmdbcli.lookup.v1.LookupService.NetworksWithin:input_type -> mmdbcli.lookup.v1.NetworksWithinRequest
+	4,  // 7: mmdbcli.lookup.v1.LookupService.Metadata:input_type -> mmdbcli.lookup.v1.MetadataRequest
+	6,  // 8: mmdbcli.lookup.v1.LookupService.Status:input_type -> mmdbcli.lookup.v1.StatusRequest
+	1,  // 9: mmdbcli.lookup.v1.LookupService.Lookup:output_type -> mmdbcli.lookup.v1.LookupResponse
+	1,  // 10: mmdbcli.lookup.v1.LookupService.BatchLookup:output_type -> mmdbcli.lookup.v1.LookupResponse
+	3,  // 11: mmdbcli.lookup.v1.LookupService.NetworksWithin:output_type -> mmdbcli.lookup.v1.Network
+	5,  // 12: mmdbcli.lookup.v1.LookupService.Metadata:output_type -> mmdbcli.lookup.v1.DatabaseMetadata
+	8,  // 13: mmdbcli.lookup.v1.LookupService.Status:output_type -> mmdbcli.lookup.v1.StatusResponse
+	9,  // [9:14] is the sub-list for method output_type
+	4,  // [4:9] is the sub-list for method input_type
+	4,  // [4:4] is the sub-list for extension type_name
+	4,  // [4:4] is the sub-list for extension extendee
+	0,  // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_lookup_proto_init() }
+func file_lookup_proto_init() {
+	if File_lookup_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_lookup_proto_rawDesc), len(file_lookup_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_lookup_proto_goTypes,
+		DependencyIndexes: file_lookup_proto_depIdxs,
+		MessageInfos:      file_lookup_proto_msgTypes,
+	}.Build()
+	File_lookup_proto = out.File
+	file_lookup_proto_goTypes = nil
+	file_lookup_proto_depIdxs = nil
+}
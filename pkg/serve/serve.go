@@ -0,0 +1,341 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/InfraZ/mmdb-cli/internal/files"
+	"github.com/InfraZ/mmdb-cli/pkg/inspect"
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
+	"github.com/InfraZ/mmdb-cli/pkg/reload"
+)
+
+// DefaultMaxBatch is the default maximum number of addresses of a batch
+// lookup.
+const DefaultMaxBatch = 1000
+
+// DefaultMaxNetworks is the default maximum number of networks of a networks
+// request.
+const DefaultMaxNetworks = 10000
+
+// shutdownTimeout is how long the requests in flight are given to complete
+// when the server stops.
+const shutdownTimeout = 10 * time.Second
+
+type CmdServeConfig struct {
+	// InputFiles are the database files, each of them optionally prefixed by
+	// an alias, see inspect.ParseDatabaseInput
+	InputFiles []string
+	Address    string
+
+	// Format is the response format of the requests without a "format"
+	// query parameter
+	Format string
+
+	// MaxBatch is the maximum number of addresses of a batch lookup, 0 uses
+	// DefaultMaxBatch
+	MaxBatch int
+
+	// MaxNetworks is the maximum number of networks of a networks request, 0
+	// uses DefaultMaxNetworks
+	MaxNetworks int
+
+	// GRPC serves the gRPC lookup service instead of the HTTP endpoints
+	GRPC bool
+
+	// WatchInterval is the interval at which the database files are checked
+	// for changes, zero disabling the checks. The files are also reloaded
+	// on SIGHUP.
+	WatchInterval time.Duration
+}
+
+// DefaultWatchInterval is the default interval at which the database files
+// are checked for changes.
+const DefaultWatchInterval = 10 * time.Second
+
+// Database is an open database served under its name. Its reader is acquired
+// for every request, so that it can be reloaded while it is served.
+type Database struct {
+	Name string
+
+	// Alias keys the records of the database in the lookups merging several
+	// databases, in which they are deep-merged when it is empty
+	Alias string
+
+	*reload.Database
+}
+
+// DatabaseStatus describes a served database and its reloads.
+type DatabaseStatus struct {
+	Name         string `json:"name"`
+	Path         string `json:"path,omitempty"`
+	DatabaseType string `json:"database_type"`
+	BuildEpoch   uint   `json:"build_epoch"`
+	Reloads      int64  `json:"reloads"`
+}
+
+// Status returns the status of every database of the server.
+func (s *Server) Status() []DatabaseStatus {
+	statuses := make([]DatabaseStatus, 0, len(s.databases))
+	for _, database := range s.databases {
+		status := DatabaseStatus{
+			Name:    database.Name,
+			Path:    database.Path(),
+			Reloads: database.Reloads(),
+		}
+		// A closed database is reported without its reader metadata
+		if reader, release, err := database.Acquire(); err == nil {
+			status.DatabaseType = reader.Metadata.DatabaseType
+			status.BuildEpoch = reader.Metadata.BuildEpoch
+			release()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Reload reloads the file of every database that has one. The databases
+// whose file is invalid keep their current reader.
+func (s *Server) Reload() error {
+	var errs []error
+	for _, database := range s.databases {
+		if database.Path() == "" {
+			continue
+		}
+		if err := database.Reload(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		slog.Info("Database reloaded", "name", database.Name, "build_epoch", database.BuildEpoch(), "reloads", database.Reloads())
+	}
+	return errors.Join(errs...)
+}
+
+// Watch reloads the file of every database that has one when it changes,
+// checking them at every interval until ctx is done.
+func (s *Server) Watch(ctx context.Context, interval time.Duration) {
+	var wg sync.WaitGroup
+	for _, database := range s.databases {
+		if database.Path() == "" {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			database.Watch(ctx, interval)
+		}()
+	}
+	wg.Wait()
+}
+
+// DatabaseName returns the name a database file is served under, its base
+// name without the database and compression extensions.
+func DatabaseName(path string) string {
+	return strings.TrimSuffix(files.TrimCompression(filepath.Base(path)), ".mmdb")
+}
+
+// Options configures a Server.
+type Options struct {
+	// Format is the response format of the requests without a "format"
+	// query parameter, one of the output formats (default json)
+	Format string
+
+	// MaxBatch is the maximum number of addresses of a batch lookup. The
+	// limit cannot be turned off, 0 uses DefaultMaxBatch
+	MaxBatch int
+
+	// MaxNetworks is the maximum number of networks of a networks request,
+	// the larger ones being answered with 413. The limit cannot be turned
+	// off, 0 uses DefaultMaxNetworks
+	MaxNetworks int
+}
+
+// Server answers lookups in open databases over HTTP. The first database is
+// used by the requests without a "database" query parameter.
+type Server struct {
+	databases []Database
+	opts      Options
+	ready     atomic.Bool
+}
+
+// NewServer creates a server for the databases, which stay open for as long
+// as the server is used.
+func NewServer(databases []Database, opts Options) (*Server, error) {
+	if len(databases) == 0 {
+		return nil, fmt.Errorf("at least one database is required")
+	}
+
+	names := make(map[string]bool, len(databases))
+	for _, database := range databases {
+		if names[database.Name] {
+			return nil, fmt.Errorf("several databases are named %s", database.Name)
+		}
+		names[database.Name] = true
+	}
+
+	if opts.Format == "" {
+		opts.Format = "json"
+	}
+	if !isFormat(opts.Format) {
+		return nil, fmt.Errorf("unsupported output format: %s (supported: %s)", opts.Format, strings.Join(formats, ", "))
+	}
+	if opts.MaxBatch == 0 {
+		opts.MaxBatch = DefaultMaxBatch
+	}
+	if opts.MaxBatch < 0 {
+		return nil, fmt.Errorf("invalid maximum batch size %d", opts.MaxBatch)
+	}
+	if opts.MaxNetworks == 0 {
+		opts.MaxNetworks = DefaultMaxNetworks
+	}
+	if opts.MaxNetworks < 0 {
+		return nil, fmt.Errorf("invalid maximum number of networks %d", opts.MaxNetworks)
+	}
+
+	return &Server{databases: databases, opts: opts}, nil
+}
+
+// Serve answers the requests of listener until ctx is done, and then waits
+// for the requests in flight to complete. The server is reported ready while
+// it accepts requests.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	server := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+	s.ready.Store(true)
+
+	select {
+	case err := <-serveErr:
+		s.ready.Store(false)
+		return fmt.Errorf("server stopped: %w", err)
+	case <-ctx.Done():
+	}
+
+	s.ready.Store(false)
+	slog.Info("Shutting down the server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down the server: %w", err)
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server stopped: %w", err)
+	}
+
+	return nil
+}
+
+// ServeMMDB serves the database files of the configuration until the process
+// is interrupted or terminated. The files are reloaded when they change and
+// on SIGHUP.
+func ServeMMDB(cfg CmdServeConfig) error {
+
+	if len(cfg.InputFiles) == 0 {
+		return fmt.Errorf("at least one database is required")
+	}
+
+	var filesToCheck []files.FilesListValidation
+	for _, input := range cfg.InputFiles {
+		_, inputFile := inspect.ParseDatabaseInput(input)
+		filesToCheck = append(filesToCheck, files.FilesListValidation{FilePath: inputFile, ExpectedExtensions: mmdb.DatabaseExtensions, ShouldExist: true})
+	}
+
+	if err := files.FilesValidation(filesToCheck); err != nil {
+		return err
+	}
+
+	var databases []Database
+	for _, input := range cfg.InputFiles {
+		alias, inputFile := inspect.ParseDatabaseInput(input)
+		db, err := reload.Open(inputFile)
+		if err != nil {
+			return fmt.Errorf("failed to open database: %s - %w", inputFile, err)
+		}
+		defer db.Close()
+
+		name := alias
+		if name == "" {
+			name = DatabaseName(inputFile)
+		}
+		databases = append(databases, Database{Name: name, Alias: alias, Database: db})
+		slog.Info("Database opened", "name", name, "path", inputFile, "build_epoch", db.BuildEpoch())
+	}
+
+	server, err := NewServer(databases, Options{Format: cfg.Format, MaxBatch: cfg.MaxBatch, MaxNetworks: cfg.MaxNetworks})
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", cfg.Address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", cfg.Address, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.WatchInterval > 0 {
+		go server.Watch(ctx, cfg.WatchInterval)
+	}
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				slog.Info("Reloading databases on SIGHUP")
+				if err := server.Reload(); err != nil {
+					slog.Error("Failed to reload databases", "error", err)
+				}
+			}
+		}
+	}()
+
+	if cfg.GRPC {
+		slog.Info("Serving databases over gRPC", "address", listener.Addr().String())
+		return server.ServeGRPC(ctx, listener)
+	}
+
+	slog.Info("Serving databases", "address", listener.Addr().String())
+
+	return server.Serve(ctx, listener)
+}
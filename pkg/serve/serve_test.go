@@ -0,0 +1,385 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/InfraZ/mmdb-cli/pkg/reload"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	inspectMMDB  = "../../test/inspect.mmdb"
+	metadataMMDB = "../../test/metadata.mmdb"
+)
+
+func openDatabase(t *testing.T, path string) Database {
+	t.Helper()
+	db, err := reload.Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return Database{Name: DatabaseName(path), Database: db}
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server, err := NewServer([]Database{openDatabase(t, inspectMMDB), openDatabase(t, metadataMMDB)}, Options{MaxBatch: 2})
+	require.NoError(t, err)
+	server.ready.Store(true)
+
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+	return httpServer
+}
+
+// request sends a request to the server and decodes its JSON response.
+func request(t *testing.T, method, url string, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&decoded))
+	return resp.StatusCode, decoded
+}
+
+func TestDatabaseName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "GeoLite2-ASN", DatabaseName("/data/GeoLite2-ASN.mmdb"))
+	assert.Equal(t, "GeoLite2-City", DatabaseName("GeoLite2-City.mmdb.gz"))
+}
+
+func TestNewServer(t *testing.T) {
+	t.Parallel()
+
+	db := openDatabase(t, inspectMMDB)
+
+	_, err := NewServer(nil, Options{})
+	assert.Error(t, err)
+	_, err = NewServer([]Database{db, db}, Options{})
+	assert.ErrorContains(t, err, "several databases are named inspect")
+	_, err = NewServer([]Database{db}, Options{Format: "csv"})
+	assert.Error(t, err)
+	_, err = NewServer([]Database{db}, Options{MaxBatch: -1})
+	assert.Error(t, err)
+	_, err = NewServer([]Database{db}, Options{MaxNetworks: -1})
+	assert.Error(t, err)
+
+	// A zero limit uses the default one
+	server, err := NewServer([]Database{db}, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultMaxBatch, server.opts.MaxBatch)
+	assert.Equal(t, DefaultMaxNetworks, server.opts.MaxNetworks)
+}
+
+func TestHandlerMaxNetworks(t *testing.T) {
+	t.Parallel()
+
+	server, err := NewServer([]Database{openDatabase(t, inspectMMDB)}, Options{MaxNetworks: 1})
+	require.NoError(t, err)
+
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	status, body := request(t, http.MethodGet, httpServer.URL+"/networks/1.0.0.0/8", "")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+	assert.Contains(t, body["error"], "more than 1 networks within 1.0.0.0/8")
+
+	status, body = request(t, http.MethodGet, httpServer.URL+"/networks/1.0.0.0/24", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, body["records"], 1)
+}
+
+func TestHandlerClosedDatabase(t *testing.T) {
+	t.Parallel()
+
+	db := openDatabase(t, inspectMMDB)
+	server, err := NewServer([]Database{db}, Options{})
+	require.NoError(t, err)
+
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	require.NoError(t, db.Close())
+
+	status, body := request(t, http.MethodGet, httpServer.URL+"/lookup/1.0.0.1", "")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Contains(t, body["error"], "database inspect is closed")
+
+	status, _ = request(t, http.MethodGet, httpServer.URL+"/metadata", "")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+}
+
+func TestHandlerMergedDatabases(t *testing.T) {
+	t.Parallel()
+
+	inspectDB := openDatabase(t, inspectMMDB)
+	inspectDB.Name, inspectDB.Alias = "geo", "geo"
+	server, err := NewServer([]Database{inspectDB, openDatabase(t, metadataMMDB)}, Options{})
+	require.NoError(t, err)
+
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	status, body := request(t, http.MethodGet, httpServer.URL+"/lookup/1.0.0.1?database=geo,metadata", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, body["found"])
+	assert.Equal(t, "1.0.0.0/24", body["network"])
+	record, ok := body["record"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Contains(t, record, "geo")
+	assert.Contains(t, record, "registered_country")
+
+	status, body = request(t, http.MethodGet, httpServer.URL+"/networks/1.0.0.0/24?database=geo,metadata", "")
+	assert.Equal(t, http.StatusOK, status)
+	records, ok := body["records"].([]interface{})
+	require.True(t, ok)
+	require.Len(t, records, 1)
+	assert.Contains(t, records[0].(map[string]interface{})["record"], "geo")
+
+	status, _ = request(t, http.MethodGet, httpServer.URL+"/lookup/1.0.0.1?database=geo,unknown", "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+
+	t.Run("lookup", func(t *testing.T) {
+		t.Parallel()
+
+		status, body := request(t, http.MethodGet, server.URL+"/lookup/1.1.1.1", "")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, true, body["found"])
+		assert.Equal(t, "1.1.1.1/32", body["network"])
+		assert.NotNil(t, body["record"])
+
+		status, body = request(t, http.MethodGet, server.URL+"/lookup/10.0.0.1", "")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, false, body["found"])
+	})
+
+	t.Run("lookup with jsonpath", func(t *testing.T) {
+		t.Parallel()
+
+		status, body := request(t, http.MethodGet, server.URL+`/lookup/1.1.1.1?jsonpath={[?(@.registered_country.iso_code=="US")]}`, "")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, false, body["found"])
+
+		status, body = request(t, http.MethodGet, server.URL+"/lookup/1.1.1.1?jsonpath={[?(@.a==}", "")
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Contains(t, body["error"], "invalid jsonpath expression")
+	})
+
+	t.Run("invalid lookup", func(t *testing.T) {
+		t.Parallel()
+
+		status, body := request(t, http.MethodGet, server.URL+"/lookup/invalid", "")
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "invalid IP address: invalid", body["error"])
+
+		status, body = request(t, http.MethodGet, server.URL+"/lookup/1.1.1.1?database=unknown", "")
+		assert.Equal(t, http.StatusNotFound, status)
+		assert.Equal(t, "unknown database unknown", body["error"])
+	})
+
+	t.Run("batch lookup", func(t *testing.T) {
+		t.Parallel()
+
+		resp, err := http.Post(server.URL+"/lookup", "application/json", strings.NewReader(`["1.1.1.1", "10.0.0.1"]`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var results []map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&results))
+		require.Len(t, results, 2)
+		assert.Equal(t, true, results[0]["found"])
+		assert.Equal(t, "10.0.0.1", results[1]["ip"])
+		assert.Equal(t, false, results[1]["found"])
+
+		status, body := request(t, http.MethodPost, server.URL+"/lookup", `["1.1.1.1", "1.0.0.1", "8.8.8.8"]`)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "batch of 3 addresses exceeds the limit of 2", body["error"])
+
+		status, _ = request(t, http.MethodPost, server.URL+"/lookup", `{"ip": "1.1.1.1"}`)
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("networks", func(t *testing.T) {
+		t.Parallel()
+
+		status, body := request(t, http.MethodGet, server.URL+"/networks/1.0.0.0/24", "")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "1.0.0.0/24", body["query"])
+		assert.NotEmpty(t, body["records"])
+
+		status, _ = request(t, http.MethodGet, server.URL+"/networks/1.0.0.0/33", "")
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		t.Parallel()
+
+		status, body := request(t, http.MethodGet, server.URL+"/metadata", "")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "Inspect Test", body["database_type"])
+
+		status, body = request(t, http.MethodGet, server.URL+"/metadata?database=metadata", "")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "Metadata Test", body["database_type"])
+	})
+
+	t.Run("output formats", func(t *testing.T) {
+		t.Parallel()
+
+		resp, err := http.Get(server.URL + "/metadata?format=yaml")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "application/yaml", resp.Header.Get("Content-Type"))
+		assert.Contains(t, string(body), "database_type: Inspect Test")
+
+		resp, err = http.Get(server.URL + "/metadata?format=xml")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err = io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "application/xml", resp.Header.Get("Content-Type"))
+		assert.Contains(t, string(body), "<database_type>Inspect Test</database_type>")
+
+		status, decoded := request(t, http.MethodGet, server.URL+"/metadata?format=csv", "")
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Contains(t, decoded["error"], "unsupported output format: csv")
+	})
+
+	t.Run("status", func(t *testing.T) {
+		t.Parallel()
+
+		resp, err := http.Get(server.URL + "/status")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var statuses []DatabaseStatus
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&statuses))
+		require.Len(t, statuses, 2)
+		assert.Equal(t, DatabaseStatus{Name: "inspect", Path: inspectMMDB, DatabaseType: "Inspect Test", BuildEpoch: 1741883836}, statuses[0])
+	})
+
+	t.Run("health and readiness", func(t *testing.T) {
+		t.Parallel()
+
+		status, body := request(t, http.MethodGet, server.URL+"/healthz", "")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "ok", body["status"])
+
+		status, body = request(t, http.MethodGet, server.URL+"/readyz", "")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "ready", body["status"])
+	})
+}
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+
+	server, err := NewServer([]Database{openDatabase(t, inspectMMDB)}, Options{})
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(ctx, listener)
+	}()
+
+	url := "http://" + listener.Addr().String()
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(url + "/readyz")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-served:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop")
+	}
+	assert.False(t, server.ready.Load())
+}
+
+func TestServerReload(t *testing.T) {
+	t.Parallel()
+
+	content, err := os.ReadFile(inspectMMDB)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "geo.mmdb")
+	require.NoError(t, os.WriteFile(path, content, 0644))
+
+	server, err := NewServer([]Database{openDatabase(t, path)}, Options{})
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watched := make(chan struct{})
+	go func() {
+		server.Watch(ctx, 10*time.Millisecond)
+		close(watched)
+	}()
+
+	content, err = os.ReadFile(metadataMMDB)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path+".tmp", content, 0644))
+	require.NoError(t, os.Rename(path+".tmp", path))
+
+	assert.Eventually(t, func() bool { return server.Status()[0].Reloads == 1 }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-watched
+
+	status, body := request(t, http.MethodGet, httpServer.URL+"/metadata", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "Metadata Test", body["database_type"])
+
+	require.NoError(t, server.Reload())
+	assert.Equal(t, int64(2), server.Status()[0].Reloads)
+}
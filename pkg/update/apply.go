@@ -0,0 +1,204 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package update
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/inserter"
+
+	"github.com/InfraZ/mmdb-cli/pkg/dataset"
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
+	"github.com/InfraZ/mmdb-cli/pkg/progress"
+)
+
+// Options configures the update of a database.
+type Options struct {
+	// Strict makes schema violations fail the update instead of being
+	// reported as warnings and written as zero values
+	Strict bool
+
+	// Progress receives the progress of the update, it may be nil
+	Progress progress.Reporter
+}
+
+// Result describes an applied update.
+type Result struct {
+	// Records is the number of dataset records applied
+	Records int
+
+	// Networks is the number of networks updated, ranges being expanded
+	// into several networks
+	Networks int
+
+	// Warnings lists the problems that did not stop the update
+	Warnings []string
+
+	// Size is the number of bytes written by Update
+	Size int64
+}
+
+func (r *Result) warn(reporter progress.Reporter, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	r.Warnings = append(r.Warnings, message)
+	reporter.Warning(message)
+}
+
+// Apply applies the entries of reader to tree. In strict mode, the schema
+// violations of every record are returned as mmdb.SchemaViolations, and the
+// records without violations are applied to the tree all the same.
+func Apply(ctx context.Context, tree *mmdbwriter.Tree, reader *dataset.Reader, opts Options) (*Result, error) {
+	reporter := progress.OrNop(opts.Progress)
+	result := &Result{}
+
+	inputDataSchema, inputDataVersion := datasetHeader(reader)
+
+	if inputDataVersion != "" {
+		if inputDataVersion != "v1" {
+			return nil, fmt.Errorf("unsupported version: %s (supported: v1)", inputDataVersion)
+		}
+		reporter.Step(fmt.Sprintf("Dataset version: %s", inputDataVersion))
+	}
+
+	var useDefaultSchema bool = true
+	if inputDataSchema != nil {
+		reporter.Step(fmt.Sprintf("Dataset schema: %v", inputDataSchema))
+		useDefaultSchema = false
+	} else {
+		result.warn(reporter, "No schema found in input data, using default schema")
+	}
+
+	var violations mmdb.SchemaViolations
+
+	reporter.Step("Starting update mmdb with dataset")
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		updateRequest, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing input data: %w", err)
+		}
+
+		result.Records++
+		updatePosition := result.Records
+
+		_, networkExists := updateRequest["network"]
+		if !networkExists {
+			return nil, fmt.Errorf("no 'network' found for record %d", updatePosition)
+		}
+
+		network, _ := updateRequest["network"].(string)
+		networks, err := mmdb.ParseNetwork(network)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing network for record %d (%s) - %w", updatePosition, updateRequest["network"], err)
+		}
+
+		_, dataExists := updateRequest["data"]
+		if !dataExists {
+			return nil, fmt.Errorf("no 'data' found for record %d (network: %s)", updatePosition, network)
+		}
+
+		dynamicData, exists := updateRequest["data"].(map[string]interface{})
+		if !exists {
+			return nil, fmt.Errorf("error parsing data for record %d (network: %s)", updatePosition, network)
+		}
+
+		dynamicMmdbData, conversionErrors := mmdb.ConvertToMMDBTypeMapStrict(dynamicData, useDefaultSchema, inputDataSchema)
+		for _, conversionError := range conversionErrors {
+			conversionError.Position = updatePosition
+			conversionError.Network = network
+			if !opts.Strict {
+				result.warn(reporter, "%s", conversionError)
+			}
+		}
+		if opts.Strict && len(conversionErrors) > 0 {
+			violations = append(violations, conversionErrors...)
+			continue
+		}
+
+		method, isMethodPresent := updateRequest["method"].(string)
+		if !isMethodPresent {
+			result.warn(reporter, "No 'method' found for record %d, defaulting to 'deep_merge'", updatePosition)
+			method = "deep_merge"
+		}
+
+		var insertFunc inserter.Func
+		var action string
+		switch method {
+		case "remove":
+			insertFunc, action = inserter.Remove, "removing"
+		case "replace":
+			insertFunc, action = inserter.ReplaceWith(dynamicMmdbData), "replacing"
+		case "top_level_merge":
+			insertFunc, action = inserter.TopLevelMergeWith(dynamicMmdbData), "top level merging"
+		case "deep_merge":
+			insertFunc, action = inserter.DeepMergeWith(dynamicMmdbData), "deep merging"
+		default:
+			return nil, fmt.Errorf("unsupported method '%s' for record %d (supported: remove, replace, top_level_merge, deep_merge)", method, updatePosition)
+		}
+
+		for _, subnet := range networks {
+			if err := tree.InsertFunc(subnet, insertFunc); err != nil {
+				return nil, fmt.Errorf("error %s data for record %d (network: %s) - %w", action, updatePosition, subnet, err)
+			}
+		}
+		result.Networks += len(networks)
+
+		if len(networks) > 1 {
+			reporter.Expanded(updatePosition, network, mmdb.FormatNetworks(networks))
+		}
+		reporter.Record(updatePosition, network, dynamicMmdbData)
+	}
+
+	if len(violations) > 0 {
+		return result, violations
+	}
+
+	return result, nil
+}
+
+// Update applies the v1 JSON dataset read from src to tree and writes the
+// updated database to dst. Trees of existing databases are created with
+// mmdbwriter.Load.
+func Update(ctx context.Context, tree *mmdbwriter.Tree, src io.Reader, dst io.Writer, opts Options) (*Result, error) {
+	reader, err := dataset.NewReader(src)
+	if err != nil {
+		return nil, fmt.Errorf("error reading dataset: %w", err)
+	}
+
+	result, err := Apply(ctx, tree, reader, opts)
+	if err != nil {
+		return result, err
+	}
+
+	progress.OrNop(opts.Progress).Step("Writing updated MMDB")
+	result.Size, err = tree.WriteTo(dst)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package update
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/InfraZ/mmdb-cli/pkg/progress"
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTree(t *testing.T) *mmdbwriter.Tree {
+	t.Helper()
+	tree, err := mmdbwriter.New(mmdbwriter.Options{DatabaseType: "Test-DB", RecordSize: 24})
+	require.NoError(t, err)
+	_, network, _ := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, tree.Insert(network, mmdbtype.Map{"country": mmdbtype.String("AU")}))
+	return tree
+}
+
+// expansions is a progress.Reporter keeping the expanded ranges.
+type expansions struct {
+	progress.Nop
+	networks []string
+}
+
+func (e *expansions) Expanded(position int, network string, networks string) {
+	e.networks = append(e.networks, network+": "+networks)
+}
+
+func TestUpdate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("applies the dataset and writes the database", func(t *testing.T) {
+		t.Parallel()
+
+		dataset := `{"version": "v1", "schema": {"asn": "uint32"}, "dataset": [
+			{"network": "1.1.1.0/24", "data": {"asn": 13335}, "method": "top_level_merge"},
+			{"network": "2.0.0.0-2.0.1.255", "data": {"asn": 3215}, "method": "replace"}
+		]}`
+
+		var output bytes.Buffer
+		result, err := Update(context.Background(), newTestTree(t), strings.NewReader(dataset), &output, Options{})
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, result.Records)
+		assert.Equal(t, 2, result.Networks)
+		assert.Empty(t, result.Warnings)
+		assert.Equal(t, int64(output.Len()), result.Size)
+
+		db, err := maxminddb.FromBytes(output.Bytes())
+		require.NoError(t, err)
+		defer db.Close()
+
+		var record map[string]interface{}
+		require.NoError(t, db.Lookup(net.ParseIP("1.1.1.1"), &record))
+		assert.Equal(t, map[string]interface{}{"country": "AU", "asn": uint64(13335)}, record)
+	})
+
+	t.Run("reports the ranges expanded into several networks", func(t *testing.T) {
+		t.Parallel()
+
+		dataset := `{"dataset": [
+			{"network": "2.0.0.0-2.0.1.255", "data": {"asn": 3215}, "method": "replace"},
+			{"network": "3.0.0.0-3.0.2.255", "data": {"asn": 16509}, "method": "replace"}
+		]}`
+
+		reporter := &expansions{}
+		result, err := Update(context.Background(), newTestTree(t), strings.NewReader(dataset), &bytes.Buffer{}, Options{Progress: reporter})
+		require.NoError(t, err)
+
+		assert.Equal(t, 3, result.Networks)
+		assert.Equal(t, []string{"3.0.0.0-3.0.2.255: 3.0.0.0/23, 3.0.2.0/24"}, reporter.networks)
+	})
+
+	t.Run("returns warnings", func(t *testing.T) {
+		t.Parallel()
+
+		dataset := `{"dataset": [{"network": "1.1.1.0/24", "data": {"asn": 13335}}]}`
+
+		result, err := Update(context.Background(), newTestTree(t), strings.NewReader(dataset), &bytes.Buffer{}, Options{})
+		require.NoError(t, err)
+		assert.Len(t, result.Warnings, 2)
+	})
+
+	t.Run("stops when the context is canceled", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		dataset := `{"dataset": [{"network": "1.1.1.0/24", "data": {"asn": 13335}}]}`
+
+		var output bytes.Buffer
+		_, err := Update(ctx, newTestTree(t), strings.NewReader(dataset), &output, Options{})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Zero(t, output.Len())
+	})
+}
@@ -17,17 +17,19 @@ limitations under the License.
 package update
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net"
+	"log/slog"
 	"os"
 
 	"github.com/maxmind/mmdbwriter"
-	"github.com/maxmind/mmdbwriter/inserter"
 
 	"github.com/InfraZ/mmdb-cli/internal/files"
+	"github.com/InfraZ/mmdb-cli/pkg/dataset"
 	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
+	"github.com/InfraZ/mmdb-cli/pkg/progress"
+	"github.com/InfraZ/mmdb-cli/pkg/validate"
+	"github.com/InfraZ/mmdb-cli/pkg/verify"
 )
 
 type CmdUpdateConfig struct {
@@ -38,110 +40,128 @@ type CmdUpdateConfig struct {
 
 	DisableIPv4Aliasing     bool
 	IncludeReservedNetworks bool
-}
 
-func readJsonInput(inputDataSet string) (map[string]interface{}, error) {
-	_, err := os.Stat(inputDataSet)
-	if os.IsNotExist(err) {
-		return nil, fmt.Errorf("file %s does not exist", inputDataSet)
-	}
+	// Strict makes schema violations fail the update instead of being
+	// logged and written as zero values
+	Strict bool
 
-	datasetFile, err := os.Open(inputDataSet)
-	if err != nil {
-		return nil, err
-	}
-	defer datasetFile.Close()
+	// SkipValidation skips the validation pass over the dataset that runs
+	// before the database is updated
+	SkipValidation bool
 
-	byteValue, err := io.ReadAll(datasetFile)
-	if err != nil {
-		return nil, err
-	}
+	// Verify checks the written database before it replaces the output file
+	Verify bool
 
-	var dataset map[string]interface{}
-	if err := json.Unmarshal(byteValue, &dataset); err != nil {
-		return nil, err
-	}
+	// InPlace replaces the input database with the updated one, in which
+	// case OutputDatabase is left empty
+	InPlace bool
 
-	return dataset, nil
+	// Backup keeps the replaced input database in a .bak file next to it
+	// when updating in place
+	Backup bool
 }
 
-func parseInputData(inputDataSet string) ([]map[string]interface{}, map[string]interface{}, string, error) {
-	inputData, err := readJsonInput(inputDataSet)
+func parseInputData(inputDataSet string) (*dataset.Reader, map[string]interface{}, string, error) {
+	datasetReader, err := dataset.Open(inputDataSet)
 	if err != nil {
 		return nil, nil, "", fmt.Errorf("error reading dataset: %w", err)
 	}
 
-	datasetInterface, exists := inputData["dataset"]
-	if !exists {
-		return nil, nil, "", fmt.Errorf("no 'dataset' field found in input data")
-	}
+	inputDataSchema, inputDataVersion := datasetHeader(datasetReader)
 
-	datasetSlice, ok := datasetInterface.([]interface{})
-	if !ok {
-		return nil, nil, "", fmt.Errorf("dataset field is not an array")
-	}
-
-	inputDataDataset := make([]map[string]interface{}, len(datasetSlice))
-	for i, item := range datasetSlice {
-		itemMap, ok := item.(map[string]interface{})
-		if !ok {
-			return nil, nil, "", fmt.Errorf("dataset item %d is not a valid object", i+1)
-		}
-		inputDataDataset[i] = itemMap
-	}
+	return datasetReader, inputDataSchema, inputDataVersion, nil
+}
 
+// datasetHeader returns the schema and the version of the dataset, which are
+// both optional.
+func datasetHeader(datasetReader *dataset.Reader) (map[string]interface{}, string) {
 	var inputDataSchema map[string]interface{}
-	if schemaInterface, exists := inputData["schema"]; exists {
+	if schemaInterface, exists := datasetReader.Header["schema"]; exists {
 		if schema, ok := schemaInterface.(map[string]interface{}); ok {
 			inputDataSchema = schema
 		}
 	}
 
 	var inputDataVersion string
-	if versionInterface, exists := inputData["version"]; exists {
+	if versionInterface, exists := datasetReader.Header["version"]; exists {
 		if version, ok := versionInterface.(string); ok {
 			inputDataVersion = version
 		}
 	}
 
-	return inputDataDataset, inputDataSchema, inputDataVersion, nil
+	return inputDataSchema, inputDataVersion
 }
 
 func UpdateMMDB(cfg CmdUpdateConfig) error {
 
+	if cfg.InPlace {
+		if files.Compression(cfg.InputDatabase) != "" {
+			return fmt.Errorf("a compressed database cannot be updated in place")
+		}
+		if cfg.OutputDatabase != "" && cfg.OutputDatabase != cfg.InputDatabase {
+			return fmt.Errorf("an output database cannot be set when updating in place")
+		}
+		cfg.OutputDatabase = cfg.InputDatabase
+	} else if cfg.Backup {
+		return fmt.Errorf("a backup can only be kept when updating in place")
+	}
+
+	if files.IsStdio(cfg.InputDatabase) {
+		return fmt.Errorf("the input database cannot be read from the standard input")
+	}
+
 	filesToCheck := []files.FilesListValidation{
-		{FilePath: cfg.InputDataSet, ExpectedExtension: ".json", ShouldExist: true},
-		{FilePath: cfg.InputDatabase, ExpectedExtension: ".mmdb", ShouldExist: true},
-		{FilePath: cfg.OutputDatabase, ExpectedExtension: ".mmdb", ShouldExist: false},
+		{FilePath: cfg.InputDataSet, ExpectedExtensions: dataset.Extensions, ShouldExist: true, AllowStdio: true},
+		{FilePath: cfg.InputDatabase, ExpectedExtensions: mmdb.DatabaseExtensions, ShouldExist: true},
+		{FilePath: cfg.OutputDatabase, ExpectedExtension: ".mmdb", ShouldExist: false, AllowStdio: true},
 	}
 
 	if err := files.FilesValidation(filesToCheck); err != nil {
 		return err
 	}
 
-	inputDataDataset, inputDataSchema, inputDataVersion, err := parseInputData(cfg.InputDataSet)
-	if err != nil {
-		return fmt.Errorf("error parsing input data: %w", err)
+	if cfg.Verify && files.IsStdio(cfg.OutputDatabase) {
+		return fmt.Errorf("a database written to the standard output cannot be verified")
 	}
 
-	if inputDataVersion != "" {
-		if inputDataVersion != "v1" {
-			return fmt.Errorf("unsupported version: %s (supported: v1)", inputDataVersion)
+	// The dataset is read once to validate it and once to update the
+	// database, so the standard input is kept in a temporary file
+	if files.IsStdio(cfg.InputDataSet) {
+		spoolPath, err := dataset.Spool(os.Stdin, false)
+		if err != nil {
+			return fmt.Errorf("error reading dataset from the standard input: %w", err)
 		}
-		fmt.Printf("[+] Dataset version: %s\n", inputDataVersion)
+		defer os.Remove(spoolPath)
+		cfg.InputDataSet = spoolPath
 	}
 
-	var useDefaultSchema bool = true
-	if inputDataSchema != nil {
-		fmt.Printf("[+] Dataset schema: %v\n", inputDataSchema)
-		useDefaultSchema = false
-	} else {
-		fmt.Println("[-] No schema found in input data, using default schema")
+	if !cfg.SkipValidation {
+		slog.Info("Validating dataset")
+		if _, err := validate.ValidateDataset(validate.CmdValidateDatasetConfig{
+			InputDataset: cfg.InputDataSet,
+			Kind:         validate.KindUpdate,
+		}); err != nil {
+			return err
+		}
 	}
 
-	var updatePosition int
+	datasetReader, _, _, err := parseInputData(cfg.InputDataSet)
+	if err != nil {
+		return fmt.Errorf("error parsing input data: %w", err)
+	}
+	defer datasetReader.Close()
+
+	// The writer only loads plain database files
+	inputDatabase := cfg.InputDatabase
+	if files.Compression(inputDatabase) != "" {
+		inputDatabase, err = files.DecompressToTemp(cfg.InputDatabase)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(inputDatabase)
+	}
 
-	writer, err := mmdbwriter.Load(cfg.InputDatabase, mmdbwriter.Options{
+	writer, err := mmdbwriter.Load(inputDatabase, mmdbwriter.Options{
 		DisableIPv4Aliasing:     cfg.DisableIPv4Aliasing,
 		IncludeReservedNetworks: cfg.IncludeReservedNetworks,
 	})
@@ -149,87 +169,48 @@ func UpdateMMDB(cfg CmdUpdateConfig) error {
 		return fmt.Errorf("failed to load MMDB database: %w", err)
 	}
 
-	fmt.Println("[+] Starting update mmdb with dataset")
-
-	for _, updateRequest := range inputDataDataset {
-		updatePosition++
-
-		_, networkExists := updateRequest["network"]
-		if !networkExists {
-			return fmt.Errorf("no 'network' found for record %d", updatePosition)
-		}
-
-		_, network, err := net.ParseCIDR(updateRequest["network"].(string))
-		if err != nil {
-			return fmt.Errorf("error parsing network for record %d (%s) - %w", updatePosition, updateRequest["network"], err)
-		}
-
-		_, dataExists := updateRequest["data"]
-		if !dataExists {
-			return fmt.Errorf("no 'data' found for record %d (network: %s)", updatePosition, network)
-		}
-
-		dynamicData, exists := updateRequest["data"].(map[string]interface{})
-		if !exists {
-			return fmt.Errorf("error parsing data for record %d (network: %s)", updatePosition, network)
-		}
+	logger := &progress.Logger{
+		Verbose:       cfg.Verbose,
+		Bar:           progress.TerminalBar(os.Stderr),
+		CountFormat:   "%d dataset records processed",
+		RecordMessage: "Record processed",
+	}
 
-		dynamicMmdbData := mmdb.ConvertToMMDBTypeMap(dynamicData, useDefaultSchema, inputDataSchema)
+	result, err := Apply(context.Background(), writer, datasetReader, Options{
+		Strict:   cfg.Strict,
+		Progress: logger,
+	})
+	logger.EndLine()
+	if err != nil {
+		return err
+	}
 
-		method, isMethodPresent := updateRequest["method"].(string)
-		if !isMethodPresent {
-			fmt.Printf("[!] No 'method' found for record %d, defaulting to 'deep_merge'\n", updatePosition)
-			method = "deep_merge"
-		}
+	slog.Info("Dataset records processed", "records", result.Records, "networks", result.Networks)
 
-		switch method {
-		case "remove":
-			if err := writer.InsertFunc(network, inserter.Remove); err != nil {
-				return fmt.Errorf("error removing data for record %d (network: %s) - %w", updatePosition, network, err)
-			}
-		case "replace":
-			if err := writer.InsertFunc(network, inserter.ReplaceWith(dynamicMmdbData)); err != nil {
-				return fmt.Errorf("error replacing data for record %d (network: %s) - %w", updatePosition, network, err)
-			}
-		case "top_level_merge":
-			if err := writer.InsertFunc(network, inserter.TopLevelMergeWith(dynamicMmdbData)); err != nil {
-				return fmt.Errorf("error top level merging data for record %d (network: %s) - %w", updatePosition, network, err)
-			}
-		case "deep_merge":
-			if err := writer.InsertFunc(network, inserter.DeepMergeWith(dynamicMmdbData)); err != nil {
-				return fmt.Errorf("error deep merging data for record %d (network: %s) - %w", updatePosition, network, err)
+	slog.Info("Writing updated MMDB to file", "path", cfg.OutputDatabase)
+	size, err := files.WriteOutput(cfg.OutputDatabase, writer, func(tempPath string) error {
+		if cfg.Verify {
+			slog.Info("Verifying the updated MMDB database")
+			if _, err := verify.VerifyMMDB(verify.CmdVerifyConfig{InputFile: tempPath}); err != nil {
+				return fmt.Errorf("updated MMDB database is invalid: %w", err)
 			}
-		default:
-			return fmt.Errorf("unsupported method '%s' for record %d (supported: remove, replace, top_level_merge, deep_merge)", method, updatePosition)
 		}
-
-		if cfg.Verbose {
-			fmt.Printf("[+] %d/%d dataset records processed - Data: %v\n", updatePosition, len(inputDataDataset), dynamicMmdbData)
-		} else {
-			fmt.Printf("\r[+] %d/%d dataset records processed", updatePosition, len(inputDataDataset))
+		if cfg.Backup {
+			backupPath, err := files.Backup(cfg.InputDatabase)
+			if err != nil {
+				return fmt.Errorf("failed to back up %s: %w", cfg.InputDatabase, err)
+			}
+			slog.Info("Input database backed up", "path", cfg.InputDatabase, "backup", backupPath)
 		}
-	}
-
-	fmt.Printf("\r[+] %d Dataset records processed\n", updatePosition)
-
-	fmt.Printf("[+] Writing updated MMDB to file")
-	outputFile, err := os.Create(cfg.OutputDatabase)
+		return nil
+	})
 	if err != nil {
 		return err
 	}
-	defer outputFile.Close()
 
-	if _, err = writer.WriteTo(outputFile); err != nil {
-		return err
-	}
-
-	fileSize, err := files.CheckFileSizeMb(cfg.OutputDatabase)
-	if err != nil {
-		return fmt.Errorf("failed to check output file size: %w", err)
-	}
-	fmt.Printf("\r[+] %s file size: %.2f MB\n", cfg.OutputDatabase, fileSize)
+	slog.Info("MMDB file written", "path", cfg.OutputDatabase, "size_mb", fmt.Sprintf("%.2f", float64(size)/1024/1024))
 
-	fmt.Println("[+] MMDB updated successfully")
+	slog.Info("MMDB updated successfully")
 
 	return nil
 }
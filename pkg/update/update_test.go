@@ -17,11 +17,15 @@ limitations under the License.
 package update
 
 import (
+	"compress/gzip"
+	"io"
 	"net"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/InfraZ/mmdb-cli/pkg/dataset"
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
 	"github.com/oschwald/maxminddb-golang"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -36,6 +40,20 @@ func writeTestFile(t *testing.T, dir, filename, content string) string {
 	return path
 }
 
+func readEntries(reader *dataset.Reader) ([]map[string]interface{}, error) {
+	var entries []map[string]interface{}
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+}
+
 func TestParseInputData(t *testing.T) {
 	t.Parallel()
 
@@ -89,6 +107,13 @@ func TestParseInputData(t *testing.T) {
 			wantErr:     true,
 			errContains: "error reading dataset",
 		},
+		{
+			name:          "schema after dataset",
+			content:       `{"dataset": [{"network": "1.0.0.0/8", "data": {"country": "AU"}}], "schema": {"country": "string"}, "version": "v1"}`,
+			expectedLen:   1,
+			expectSchema:  true,
+			expectVersion: "v1",
+		},
 		{
 			name:          "version without schema",
 			content:       `{"version": "v1", "dataset": [{"network": "1.0.0.0/8", "data": {"test": "value"}}]}`,
@@ -103,7 +128,12 @@ func TestParseInputData(t *testing.T) {
 			dir := t.TempDir()
 			path := writeTestFile(t, dir, "dataset.json", tt.content)
 
-			data, schema, version, err := parseInputData(path)
+			reader, schema, version, err := parseInputData(path)
+			var data []map[string]interface{}
+			if err == nil {
+				defer reader.Close()
+				data, err = readEntries(reader)
+			}
 			if tt.wantErr {
 				require.Error(t, err)
 				if tt.errContains != "" {
@@ -130,35 +160,6 @@ func TestParseInputData(t *testing.T) {
 	})
 }
 
-func TestReadJsonInput(t *testing.T) {
-	t.Parallel()
-
-	t.Run("valid JSON file", func(t *testing.T) {
-		t.Parallel()
-		dir := t.TempDir()
-		path := writeTestFile(t, dir, "data.json", `{"key": "value"}`)
-
-		result, err := readJsonInput(path)
-		require.NoError(t, err)
-		assert.Equal(t, "value", result["key"])
-	})
-
-	t.Run("invalid JSON file", func(t *testing.T) {
-		t.Parallel()
-		dir := t.TempDir()
-		path := writeTestFile(t, dir, "bad.json", `{invalid}`)
-
-		_, err := readJsonInput(path)
-		assert.Error(t, err)
-	})
-
-	t.Run("non-existent file", func(t *testing.T) {
-		t.Parallel()
-		_, err := readJsonInput("/nonexistent/file.json")
-		assert.Error(t, err)
-	})
-}
-
 func TestUpdateMMDB(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -282,6 +283,34 @@ func TestUpdateMMDB(t *testing.T) {
 				assert.Contains(t, record, "default_merge")
 			},
 		},
+		{
+			name: "replace with typed array",
+			dataset: `{
+				"schema": {"asns": ["uint32"]},
+				"dataset": [
+					{
+						"network": "1.1.1.1/32",
+						"method": "replace",
+						"data": {"asns": [13335, 15169], "tags": ["anycast"]}
+					}
+				]
+			}`,
+			verify: func(t *testing.T, outputPath string) {
+				t.Helper()
+				db, err := maxminddb.Open(outputPath)
+				require.NoError(t, err)
+				defer db.Close()
+
+				var record struct {
+					ASNs []uint32 `maxminddb:"asns"`
+					Tags []string `maxminddb:"tags"`
+				}
+				err = db.Lookup(net.ParseIP("1.1.1.1"), &record)
+				require.NoError(t, err)
+				assert.Equal(t, []uint32{13335, 15169}, record.ASNs)
+				assert.Equal(t, []string{"anycast"}, record.Tags)
+			},
+		},
 		{
 			name: "unsupported method",
 			dataset: `{
@@ -361,6 +390,176 @@ func TestUpdateMMDB(t *testing.T) {
 	}
 }
 
+func TestUpdateMMDBValidation(t *testing.T) {
+	dir := t.TempDir()
+	datasetPath := writeTestFile(t, dir, "update.json", `{
+		"dataset": [
+			{"network": "1.1.1.1/32", "method": "merge", "data": {"key": "value"}},
+			{"network": "1.1.1.2/32", "data": {"key": "value"}},
+			{"network": "1.1.1.3/33", "data": "value"}
+		]
+	}`)
+	outputPath := filepath.Join(dir, "updated.mmdb")
+
+	err := UpdateMMDB(CmdUpdateConfig{
+		InputDatabase:  testMMDB,
+		InputDataSet:   datasetPath,
+		OutputDatabase: outputPath,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "3 problem(s) found in the dataset")
+	assert.Contains(t, err.Error(), "/dataset/0/method")
+	assert.Contains(t, err.Error(), "/dataset/2/network")
+	assert.Contains(t, err.Error(), "/dataset/2/data")
+
+	// Without validation the update stops at the first invalid entry
+	err = UpdateMMDB(CmdUpdateConfig{
+		InputDatabase:  testMMDB,
+		InputDataSet:   datasetPath,
+		OutputDatabase: outputPath,
+		SkipValidation: true,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported method 'merge' for record 1")
+}
+
+func TestUpdateMMDBInPlace(t *testing.T) {
+	dir := t.TempDir()
+	original, err := os.ReadFile(testMMDB)
+	require.NoError(t, err)
+	databasePath := filepath.Join(dir, "database.mmdb")
+	require.NoError(t, os.WriteFile(databasePath, original, 0644))
+
+	datasetPath := writeTestFile(t, dir, "update.json", `{
+		"dataset": [{"network": "1.1.1.1/32", "method": "replace", "data": {"replaced": "yes"}}]
+	}`)
+
+	require.NoError(t, UpdateMMDB(CmdUpdateConfig{
+		InputDatabase: databasePath,
+		InputDataSet:  datasetPath,
+		InPlace:       true,
+		Backup:        true,
+		Verify:        true,
+	}))
+
+	backup, err := os.ReadFile(databasePath + ".bak")
+	require.NoError(t, err)
+	assert.Equal(t, original, backup)
+
+	db, err := maxminddb.Open(databasePath)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var record map[string]interface{}
+	require.NoError(t, db.Lookup(net.ParseIP("1.1.1.1"), &record))
+	assert.Equal(t, map[string]interface{}{"replaced": "yes"}, record)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3, "no temporary file should be left behind")
+
+	t.Run("output with in-place", func(t *testing.T) {
+		err := UpdateMMDB(CmdUpdateConfig{
+			InputDatabase:  databasePath,
+			InputDataSet:   datasetPath,
+			OutputDatabase: filepath.Join(dir, "other.mmdb"),
+			InPlace:        true,
+		})
+		assert.ErrorContains(t, err, "cannot be set when updating in place")
+	})
+
+	t.Run("backup without in-place", func(t *testing.T) {
+		err := UpdateMMDB(CmdUpdateConfig{
+			InputDatabase:  databasePath,
+			InputDataSet:   datasetPath,
+			OutputDatabase: filepath.Join(dir, "other.mmdb"),
+			Backup:         true,
+		})
+		assert.ErrorContains(t, err, "only be kept when updating in place")
+	})
+}
+
+func TestUpdateMMDBStrict(t *testing.T) {
+	dir := t.TempDir()
+	datasetPath := writeTestFile(t, dir, "update.json", `{
+		"schema": {"asn": "uint32"},
+		"dataset": [
+			{"network": "1.1.1.1/32", "data": {"asn": 13335}},
+			{"network": "1.0.0.1/32", "data": {"asn": 4294967296}}
+		]
+	}`)
+	outputPath := filepath.Join(dir, "out.mmdb")
+
+	cfg := CmdUpdateConfig{
+		InputDatabase:  testMMDB,
+		InputDataSet:   datasetPath,
+		OutputDatabase: outputPath,
+		Strict:         true,
+	}
+	err := UpdateMMDB(cfg)
+	require.Error(t, err)
+
+	var violations mmdb.SchemaViolations
+	require.ErrorAs(t, err, &violations)
+	require.Len(t, violations, 1)
+	assert.Equal(t, 2, violations[0].Position)
+	assert.Equal(t, "1.0.0.1/32", violations[0].Network)
+	assert.Equal(t, "asn", violations[0].Path)
+
+	_, statErr := os.Stat(outputPath)
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestUpdateMMDBJSONLines(t *testing.T) {
+	dir := t.TempDir()
+	datasetPath := writeTestFile(t, dir, "update.jsonl", `{"version": "v1", "schema": {"asn": "uint32"}}
+{"network": "1.1.1.1/32", "method": "replace", "data": {"asn": 13335}}
+`)
+	outputPath := filepath.Join(dir, "out.mmdb")
+
+	require.NoError(t, UpdateMMDB(CmdUpdateConfig{
+		InputDatabase:  testMMDB,
+		InputDataSet:   datasetPath,
+		OutputDatabase: outputPath,
+	}))
+
+	db, err := maxminddb.Open(outputPath)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var record map[string]interface{}
+	require.NoError(t, db.Lookup(net.ParseIP("1.1.1.1"), &record))
+	assert.Equal(t, map[string]interface{}{"asn": uint64(13335)}, record)
+}
+
+func TestUpdateMMDBRanges(t *testing.T) {
+	dir := t.TempDir()
+	datasetPath := writeTestFile(t, dir, "update.json", `{
+		"dataset": [
+			{"network": "5.0.0.1-5.0.0.6", "method": "replace", "data": {"name": "range"}},
+			{"network": "5.0.1.1", "method": "replace", "data": {"name": "single"}}
+		]
+	}`)
+	outputPath := filepath.Join(dir, "out.mmdb")
+
+	require.NoError(t, UpdateMMDB(CmdUpdateConfig{
+		InputDatabase:  testMMDB,
+		InputDataSet:   datasetPath,
+		OutputDatabase: outputPath,
+		Verbose:        true,
+	}))
+
+	db, err := maxminddb.Open(outputPath)
+	require.NoError(t, err)
+	defer db.Close()
+
+	for ip, want := range map[string]interface{}{"5.0.0.1": "range", "5.0.0.6": "range", "5.0.1.1": "single", "5.0.0.7": nil} {
+		var record map[string]interface{}
+		require.NoError(t, db.Lookup(net.ParseIP(ip), &record))
+		assert.Equal(t, want, record["name"], "lookup of %s", ip)
+	}
+}
+
 func TestUpdateMMDBInvalidInputDB(t *testing.T) {
 	dir := t.TempDir()
 	datasetPath := writeTestFile(t, dir, "update.json", `{"dataset":[{"network":"1.0.0.0/8","data":{"k":"v"}}]}`)
@@ -373,3 +572,72 @@ func TestUpdateMMDBInvalidInputDB(t *testing.T) {
 	err := UpdateMMDB(cfg)
 	assert.Error(t, err)
 }
+
+func TestUpdateMMDBStdout(t *testing.T) {
+	dir := t.TempDir()
+	datasetPath := writeTestFile(t, dir, "update.json", `{"dataset": [{"network": "1.1.1.1/32", "data": {"extra": "field"}}]}`)
+
+	oldStdout := os.Stdout
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	os.Stdout = w
+
+	updateErr := UpdateMMDB(CmdUpdateConfig{InputDatabase: testMMDB, InputDataSet: datasetPath, OutputDatabase: "-"})
+
+	w.Close()
+	os.Stdout = oldStdout
+	require.NoError(t, updateErr)
+
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+	db, err := maxminddb.FromBytes(content)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var record map[string]interface{}
+	require.NoError(t, db.Lookup(net.ParseIP("1.1.1.1"), &record))
+	assert.Equal(t, "field", record["extra"])
+
+	err = UpdateMMDB(CmdUpdateConfig{InputDatabase: "-", InputDataSet: datasetPath, OutputDatabase: filepath.Join(dir, "out.mmdb")})
+	assert.ErrorContains(t, err, "standard input")
+}
+
+func TestUpdateMMDBCompressedInput(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	content, err := os.ReadFile(testMMDB)
+	require.NoError(t, err)
+
+	compressedPath := filepath.Join(dir, "input.mmdb.gz")
+	compressedFile, err := os.Create(compressedPath)
+	require.NoError(t, err)
+	writer := gzip.NewWriter(compressedFile)
+	_, err = writer.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, compressedFile.Close())
+
+	datasetPath := writeTestFile(t, dir, "update.json.gz", "")
+	datasetFile, err := os.Create(datasetPath)
+	require.NoError(t, err)
+	datasetWriter := gzip.NewWriter(datasetFile)
+	_, err = datasetWriter.Write([]byte(`{"dataset": [{"network": "1.1.1.1/32", "data": {"extra": "field"}}]}`))
+	require.NoError(t, err)
+	require.NoError(t, datasetWriter.Close())
+	require.NoError(t, datasetFile.Close())
+
+	outputPath := filepath.Join(dir, "output.mmdb")
+	require.NoError(t, UpdateMMDB(CmdUpdateConfig{InputDatabase: compressedPath, InputDataSet: datasetPath, OutputDatabase: outputPath}))
+
+	db, err := maxminddb.Open(outputPath)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var record map[string]interface{}
+	require.NoError(t, db.Lookup(net.ParseIP("1.1.1.1"), &record))
+	assert.Equal(t, "field", record["extra"])
+
+	err = UpdateMMDB(CmdUpdateConfig{InputDatabase: compressedPath, InputDataSet: datasetPath, InPlace: true})
+	assert.ErrorContains(t, err, "cannot be updated in place")
+}
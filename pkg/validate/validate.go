@@ -0,0 +1,359 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/InfraZ/mmdb-cli/internal/files"
+	"github.com/InfraZ/mmdb-cli/pkg/dataset"
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
+)
+
+// Kinds of datasets, which differ in the fields of their entries.
+const (
+	// KindGenerate datasets hold the metadata of a new database and entries
+	// with a "record" object
+	KindGenerate = "generate"
+	// KindUpdate datasets hold entries with a "data" object and an optional
+	// "method"
+	KindUpdate = "update"
+	// KindLayer datasets are generate datasets applied over the datasets
+	// before them, with optional metadata overriding theirs and an optional
+	// "method" merging their records into the networks already inserted
+	KindLayer = "layer"
+)
+
+// UpdateMethods are the methods an update entry or a dataset layer can use.
+var UpdateMethods = []string{"remove", "replace", "top_level_merge", "deep_merge"}
+
+type CmdValidateDatasetConfig struct {
+	InputDataset string
+	Mapping      string
+	Kind         string
+}
+
+// Problem is an invalid part of a dataset, located by its JSON pointer. The
+// entries of JSON Lines and CSV datasets are located as if they were held by
+// the "dataset" array of a v1 JSON dataset.
+type Problem struct {
+	Pointer string
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Pointer, p.Message)
+}
+
+// Problems lists every problem found in a dataset.
+type Problems []Problem
+
+func (p Problems) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d problem(s) found in the dataset:", len(p))
+	for _, problem := range p {
+		sb.WriteString("\n  - ")
+		sb.WriteString(problem.String())
+	}
+	return sb.String()
+}
+
+func (p *Problems) add(pointer string, format string, args ...interface{}) {
+	*p = append(*p, Problem{Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+}
+
+// pointerTo appends the reference tokens of keys to pointer, escaped as
+// described in RFC 6901.
+func pointerTo(pointer string, keys ...string) string {
+	for _, key := range keys {
+		pointer += "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+	}
+	return pointer
+}
+
+// Metadata checks the metadata of a generate dataset, which must describe
+// the database to create.
+func Metadata(metadata interface{}) Problems {
+	var problems Problems
+	const pointer = "/metadata"
+
+	if metadata == nil {
+		problems.add(pointer, "is required")
+		return problems
+	}
+	metadataMap, ok := metadata.(map[string]interface{})
+	if !ok {
+		problems.add(pointer, "must be an object")
+		return problems
+	}
+
+	if databaseType, exists := metadataMap["DatabaseType"]; !exists || databaseType == nil {
+		problems.add(pointerTo(pointer, "DatabaseType"), "is required")
+	} else if _, ok := databaseType.(string); !ok {
+		problems.add(pointerTo(pointer, "DatabaseType"), "must be a string")
+	}
+
+	if description, exists := metadataMap["Description"]; !exists || description == nil {
+		problems.add(pointerTo(pointer, "Description"), "is required")
+	} else if descriptionMap, ok := description.(map[string]interface{}); !ok {
+		problems.add(pointerTo(pointer, "Description"), "must be an object of descriptions by language code")
+	} else {
+		for _, language := range sortedKeys(descriptionMap) {
+			if _, ok := descriptionMap[language].(string); !ok {
+				problems.add(pointerTo(pointer, "Description", language), "must be a string")
+			}
+		}
+	}
+
+	// Optional fields may be null, as written by dump for empty values
+	if ipVersion := metadataMap["IPVersion"]; ipVersion != nil {
+		if value, ok := ipVersion.(float64); !ok || (value != 4 && value != 6) {
+			problems.add(pointerTo(pointer, "IPVersion"), "must be 4 or 6")
+		}
+	}
+
+	if languages := metadataMap["Languages"]; languages != nil {
+		if languageList, ok := languages.([]interface{}); !ok {
+			problems.add(pointerTo(pointer, "Languages"), "must be an array of language codes")
+		} else {
+			for i, language := range languageList {
+				if _, ok := language.(string); !ok {
+					problems.add(pointerTo(pointer, "Languages", strconv.Itoa(i)), "must be a string")
+				}
+			}
+		}
+	}
+
+	if recordSize := metadataMap["RecordSize"]; recordSize != nil {
+		if value, ok := recordSize.(float64); !ok || (value != 24 && value != 28 && value != 32) {
+			problems.add(pointerTo(pointer, "RecordSize"), "must be 24, 28 or 32")
+		}
+	}
+
+	if buildEpoch := metadataMap["BuildEpoch"]; buildEpoch != nil {
+		if value, ok := buildEpoch.(float64); !ok || value <= 0 || value != math.Trunc(value) {
+			problems.add(pointerTo(pointer, "BuildEpoch"), "must be a positive Unix timestamp")
+		}
+	}
+
+	return problems
+}
+
+// Schema checks that every value of a dataset schema is a supported type
+// name, a nested object schema or an array holding at most one element
+// schema.
+func Schema(schema interface{}) Problems {
+	var problems Problems
+	if _, ok := schema.(map[string]interface{}); !ok {
+		problems.add("/schema", "must be an object")
+		return problems
+	}
+	checkSchema(schema, "/schema", &problems)
+	return problems
+}
+
+func checkSchema(schema interface{}, pointer string, problems *Problems) {
+	switch value := schema.(type) {
+	case string:
+		if !mmdb.IsSchemaType(value) {
+			problems.add(pointer, "unsupported type '%s'", value)
+		}
+	case map[string]interface{}:
+		for _, key := range sortedKeys(value) {
+			checkSchema(value[key], pointerTo(pointer, key), problems)
+		}
+	case []interface{}:
+		if len(value) > 1 {
+			problems.add(pointer, "array schema must hold at most one element schema, found %d", len(value))
+		}
+		if len(value) > 0 {
+			checkSchema(value[0], pointerTo(pointer, "0"), problems)
+		}
+	default:
+		problems.add(pointer, "must be a type name, an object or an array")
+	}
+}
+
+// Header checks the fields of a dataset outside of its entries.
+func Header(header map[string]interface{}, kind string) Problems {
+	var problems Problems
+
+	if version, exists := header["version"]; exists {
+		if value, ok := version.(string); !ok {
+			problems.add("/version", "must be a string")
+		} else if value != "v1" {
+			problems.add("/version", "unsupported version '%s' (supported: v1)", value)
+		}
+	}
+
+	if schema, exists := header["schema"]; exists {
+		problems = append(problems, Schema(schema)...)
+	}
+
+	if kind == KindGenerate {
+		problems = append(problems, Metadata(header["metadata"])...)
+	}
+
+	// The metadata of a layer only overrides some fields of the metadata
+	// before it, the merged metadata is checked when the database is built
+	if kind == KindLayer {
+		if metadata, exists := header["metadata"]; exists {
+			if _, ok := metadata.(map[string]interface{}); !ok {
+				problems.add("/metadata", "must be an object")
+			}
+		}
+		if method, exists := header["method"]; exists {
+			if value, ok := method.(string); !ok || !contains(UpdateMethods, value) {
+				problems.add("/method", "must be one of %s", strings.Join(UpdateMethods, ", "))
+			}
+		}
+	}
+
+	return problems
+}
+
+// Entry checks the entry at the 0-based index of the dataset.
+func Entry(entry map[string]interface{}, index int, kind string) Problems {
+	var problems Problems
+	pointer := pointerTo("/dataset", strconv.Itoa(index))
+
+	if network, exists := entry["network"]; !exists {
+		problems.add(pointerTo(pointer, "network"), "is required")
+	} else if networkLabel, ok := network.(string); !ok {
+		problems.add(pointerTo(pointer, "network"), "must be a string")
+	} else if _, err := mmdb.ParseNetwork(networkLabel); err != nil {
+		problems.add(pointerTo(pointer, "network"), "%s", err)
+	}
+
+	dataField := "record"
+	if kind == KindUpdate {
+		dataField = "data"
+
+		if method, exists := entry["method"]; exists {
+			if value, ok := method.(string); !ok || !contains(UpdateMethods, value) {
+				problems.add(pointerTo(pointer, "method"), "must be one of %s", strings.Join(UpdateMethods, ", "))
+			}
+		}
+	}
+
+	if data, exists := entry[dataField]; !exists {
+		problems.add(pointerTo(pointer, dataField), "is required")
+	} else if _, ok := data.(map[string]interface{}); !ok {
+		problems.add(pointerTo(pointer, dataField), "must be an object")
+	}
+
+	return problems
+}
+
+// Dataset reads every entry of reader and returns the number of entries
+// together with the problems of the header and the entries. Entries that
+// cannot be decoded end the validation, as the rest of the dataset cannot be
+// located.
+func Dataset(reader *dataset.Reader, kind string) (int, Problems) {
+	problems := Header(reader.Header, kind)
+
+	var entries int
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			pointer := pointerTo("/dataset", strconv.Itoa(reader.Position()-1))
+			if errors.Is(err, dataset.ErrNotObject) {
+				entries++
+				problems.add(pointer, "must be an object")
+				continue
+			}
+			problems.add(pointer, "%s", err)
+			break
+		}
+
+		problems = append(problems, Entry(entry, entries, kind)...)
+		entries++
+	}
+
+	return entries, problems
+}
+
+// ValidateDataset validates the dataset file of the configuration, reading
+// CSV files through their mapping file. It returns the number of entries of
+// a valid dataset, and Problems when the dataset is invalid.
+func ValidateDataset(cfg CmdValidateDatasetConfig) (int, error) {
+	if cfg.Kind == "" {
+		cfg.Kind = KindGenerate
+	}
+	if cfg.Kind != KindGenerate && cfg.Kind != KindUpdate && cfg.Kind != KindLayer {
+		return 0, fmt.Errorf("unsupported dataset kind '%s' (supported: %s, %s, %s)", cfg.Kind, KindGenerate, KindUpdate, KindLayer)
+	}
+
+	expectedExtensions := dataset.Extensions
+	if cfg.Kind != KindUpdate {
+		expectedExtensions = append([]string{".csv"}, dataset.Extensions...)
+	}
+	if err := files.FilesValidation([]files.FilesListValidation{
+		{FilePath: cfg.InputDataset, ExpectedExtensions: expectedExtensions, ShouldExist: true},
+	}); err != nil {
+		return 0, err
+	}
+
+	var reader *dataset.Reader
+	var err error
+	if files.CheckFileExtension(cfg.InputDataset, ".csv") {
+		if cfg.Mapping == "" {
+			return 0, fmt.Errorf("a mapping file is required for CSV datasets")
+		}
+		reader, err = dataset.OpenCSV(cfg.InputDataset, cfg.Mapping)
+	} else {
+		reader, err = dataset.Open(cfg.InputDataset)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error reading dataset: %w", err)
+	}
+	defer reader.Close()
+
+	entries, problems := Dataset(reader, cfg.Kind)
+	if len(problems) > 0 {
+		return entries, problems
+	}
+
+	return entries, nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,304 @@
+/*
+Copyright 2024 The InfraZ Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/InfraZ/mmdb-cli/pkg/dataset"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pointers(problems Problems) []string {
+	var result []string
+	for _, problem := range problems {
+		result = append(result, problem.Pointer)
+	}
+	return result
+}
+
+func TestMetadata(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		metadata interface{}
+		want     []string
+	}{
+		{
+			name: "valid metadata",
+			metadata: map[string]interface{}{
+				"DatabaseType": "Test",
+				"Description":  map[string]interface{}{"en": "Test"},
+				"IPVersion":    float64(4),
+				"Languages":    []interface{}{"en"},
+				"RecordSize":   float64(24),
+				"BuildEpoch":   float64(1700000000),
+				"NodeCount":    float64(10),
+			},
+		},
+		{
+			name: "null optional fields",
+			metadata: map[string]interface{}{
+				"DatabaseType": "Test",
+				"Description":  map[string]interface{}{"en": "Test"},
+				"Languages":    nil,
+			},
+		},
+		{name: "missing metadata", want: []string{"/metadata"}},
+		{name: "metadata not an object", metadata: "test", want: []string{"/metadata"}},
+		{
+			name:     "missing required fields",
+			metadata: map[string]interface{}{},
+			want:     []string{"/metadata/DatabaseType", "/metadata/Description"},
+		},
+		{
+			name: "every field invalid",
+			metadata: map[string]interface{}{
+				"DatabaseType": float64(1),
+				"Description":  map[string]interface{}{"de": "Test", "en": true, "a/b": nil},
+				"IPVersion":    float64(5),
+				"Languages":    []interface{}{"en", float64(1)},
+				"RecordSize":   "28",
+				"BuildEpoch":   float64(-1),
+			},
+			want: []string{
+				"/metadata/DatabaseType",
+				"/metadata/Description/a~1b",
+				"/metadata/Description/en",
+				"/metadata/IPVersion",
+				"/metadata/Languages/1",
+				"/metadata/RecordSize",
+				"/metadata/BuildEpoch",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, pointers(Metadata(tt.metadata)))
+		})
+	}
+}
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	valid := map[string]interface{}{
+		"asn":     "uint32",
+		"country": map[string]interface{}{"iso_code": "string"},
+		"tags":    []interface{}{"string"},
+		"any":     []interface{}{},
+		"nested":  []interface{}{map[string]interface{}{"value": "uint128"}},
+	}
+	assert.Empty(t, Schema(valid))
+
+	invalid := map[string]interface{}{
+		"asn":     "uint8",
+		"country": map[string]interface{}{"iso_code": float64(1)},
+		"tags":    []interface{}{"string", "bool"},
+		"nested":  []interface{}{map[string]interface{}{"value": "char"}},
+	}
+	assert.Equal(t, []string{
+		"/schema/asn",
+		"/schema/country/iso_code",
+		"/schema/nested/0/value",
+		"/schema/tags",
+	}, pointers(Schema(invalid)))
+
+	assert.Equal(t, []string{"/schema"}, pointers(Schema([]interface{}{})))
+}
+
+func TestHeader(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		header map[string]interface{}
+		kind   string
+		want   []string
+	}{
+		{name: "generate without metadata", header: map[string]interface{}{}, kind: KindGenerate, want: []string{"/metadata"}},
+		{name: "update without metadata", header: map[string]interface{}{}, kind: KindUpdate},
+		{name: "layer without metadata", header: map[string]interface{}{}, kind: KindLayer},
+		{
+			name:   "layer overriding metadata",
+			header: map[string]interface{}{"metadata": map[string]interface{}{"Description": map[string]interface{}{"en": "Layer"}}, "method": "replace"},
+			kind:   KindLayer,
+		},
+		{
+			name:   "invalid layer",
+			header: map[string]interface{}{"version": "v2", "metadata": "test", "method": "merge"},
+			kind:   KindLayer,
+			want:   []string{"/version", "/metadata", "/method"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, pointers(Header(tt.header, tt.kind)))
+		})
+	}
+}
+
+func TestEntry(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		entry map[string]interface{}
+		kind  string
+		want  []string
+	}{
+		{
+			name:  "valid generate entry",
+			entry: map[string]interface{}{"network": "1.0.0.0/24", "record": map[string]interface{}{}},
+			kind:  KindGenerate,
+		},
+		{
+			name:  "valid range",
+			entry: map[string]interface{}{"network": "1.0.0.1-1.0.0.9", "record": map[string]interface{}{}},
+			kind:  KindGenerate,
+		},
+		{
+			name:  "invalid generate entry",
+			entry: map[string]interface{}{"network": "1.0.0.0/33", "record": "x"},
+			kind:  KindGenerate,
+			want:  []string{"/dataset/3/network", "/dataset/3/record"},
+		},
+		{
+			name:  "missing fields",
+			entry: map[string]interface{}{},
+			kind:  KindGenerate,
+			want:  []string{"/dataset/3/network", "/dataset/3/record"},
+		},
+		{
+			name:  "valid update entry",
+			entry: map[string]interface{}{"network": "1.0.0.0/24", "data": map[string]interface{}{}, "method": "replace"},
+			kind:  KindUpdate,
+		},
+		{
+			name:  "valid layer entry",
+			entry: map[string]interface{}{"network": "1.0.0.0/24", "record": map[string]interface{}{}},
+			kind:  KindLayer,
+		},
+		{
+			name:  "invalid update entry",
+			entry: map[string]interface{}{"network": float64(1), "record": map[string]interface{}{}, "method": "merge"},
+			kind:  KindUpdate,
+			want:  []string{"/dataset/3/network", "/dataset/3/method", "/dataset/3/data"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, pointers(Entry(tt.entry, 3, tt.kind)))
+		})
+	}
+}
+
+func TestDataset(t *testing.T) {
+	t.Parallel()
+
+	content := `{
+		"version": "v1",
+		"metadata": {"DatabaseType": "Test", "Description": {"en": "Test"}},
+		"dataset": [
+			{"network": "1.0.0.0/24", "record": {}},
+			"not an object",
+			{"network": "invalid", "record": {}}
+		]
+	}`
+	reader, err := dataset.NewReader(strings.NewReader(content))
+	require.NoError(t, err)
+
+	entries, problems := Dataset(reader, KindGenerate)
+	assert.Equal(t, 3, entries)
+	assert.Equal(t, []string{"/dataset/1", "/dataset/2/network"}, pointers(problems))
+
+	t.Run("undecodable entry", func(t *testing.T) {
+		t.Parallel()
+		reader, err := dataset.NewLinesReader(strings.NewReader("{\"version\":\"v1\"}\n{\"network\":\"1.0.0.0/24\",\"data\":{}}\n{invalid\n{\"network\":\"x\"}\n"))
+		require.NoError(t, err)
+
+		entries, problems := Dataset(reader, KindUpdate)
+		assert.Equal(t, 1, entries)
+		assert.Equal(t, []string{"/dataset/1"}, pointers(problems))
+	})
+}
+
+func TestValidateDataset(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	validPath := filepath.Join(dir, "valid.json")
+	require.NoError(t, os.WriteFile(validPath, []byte(`{
+		"metadata": {"DatabaseType": "Test", "Description": {"en": "Test"}},
+		"dataset": [{"network": "1.0.0.0/24", "record": {"a": 1}}]
+	}`), 0644))
+
+	entries, err := ValidateDataset(CmdValidateDatasetConfig{InputDataset: validPath})
+	require.NoError(t, err)
+	assert.Equal(t, 1, entries)
+
+	// A generate dataset is not an update dataset
+	_, err = ValidateDataset(CmdValidateDatasetConfig{InputDataset: validPath, Kind: KindUpdate})
+	var problems Problems
+	require.ErrorAs(t, err, &problems)
+	assert.Equal(t, []string{"/dataset/0/data"}, pointers(problems))
+	assert.Contains(t, err.Error(), "1 problem(s) found in the dataset:\n  - /dataset/0/data: is required")
+
+	t.Run("CSV dataset", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		csvPath := filepath.Join(dir, "data.csv")
+		require.NoError(t, os.WriteFile(csvPath, []byte("network,cc\n1.0.0.0/24,AU\nnot-a-network,US\n"), 0644))
+		mappingPath := filepath.Join(dir, "mapping.json")
+		require.NoError(t, os.WriteFile(mappingPath, []byte(`{
+			"network": "network",
+			"metadata": {"DatabaseType": "Test", "Description": {"en": "Test"}},
+			"columns": {"cc": {"path": "country.iso_code"}}
+		}`), 0644))
+
+		_, err := ValidateDataset(CmdValidateDatasetConfig{InputDataset: csvPath})
+		assert.ErrorContains(t, err, "mapping file is required")
+
+		_, err = ValidateDataset(CmdValidateDatasetConfig{InputDataset: csvPath, Mapping: mappingPath})
+		var problems Problems
+		require.ErrorAs(t, err, &problems)
+		assert.Equal(t, []string{"/dataset/1/network"}, pointers(problems))
+	})
+
+	t.Run("unsupported kind", func(t *testing.T) {
+		t.Parallel()
+		_, err := ValidateDataset(CmdValidateDatasetConfig{InputDataset: validPath, Kind: "dump"})
+		assert.ErrorContains(t, err, "unsupported dataset kind")
+	})
+
+	t.Run("non-existent file", func(t *testing.T) {
+		t.Parallel()
+		_, err := ValidateDataset(CmdValidateDatasetConfig{InputDataset: filepath.Join(dir, "missing.json")})
+		assert.Error(t, err)
+	})
+}
@@ -17,7 +17,7 @@ limitations under the License.
 package verify
 
 import (
-	"github.com/oschwald/maxminddb-golang"
+	"github.com/InfraZ/mmdb-cli/pkg/mmdb"
 )
 
 type CmdVerifyConfig struct {
@@ -26,10 +26,11 @@ type CmdVerifyConfig struct {
 
 func VerifyMMDB(cfg CmdVerifyConfig) (bool, error) {
 
-	db, err := maxminddb.Open(cfg.InputFile)
+	db, err := mmdb.OpenDatabase(cfg.InputFile)
 	if err != nil {
 		return false, err
 	}
+	defer db.Close()
 
 	err = db.Verify()
 	if err != nil {